@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the configuration files searched for, in order
+var configFileNames = []string{".repopsy.yaml", ".repopsy.yml", ".repopsy.json"}
+
+// configIgnoredKeys are flags that cannot be set from a configuration file.
+// Commands run on the analyst's machine are among them, so that a file
+// shipped with an untrusted repository cannot run anything.
+var configIgnoredKeys = map[string]bool{
+	"config":    true,
+	"exec":      true,
+	"exec-jobs": true,
+	"h":         true,
+	"help":      true,
+	"version":   true,
+}
+
+// findConfigFile returns the first configuration file found in the current
+// directory, or "" if there is none. The analyzed repository is not trusted:
+// a file in its root, even when that is the current directory, is returned
+// as repoFile instead and only read when named with --config.
+func findConfigFile(repoPath string) (path, repoFile string) {
+	if repoFile = firstConfigFile(repoPath); repoFile != "" {
+		cwd, cwdErr := os.Stat(".")
+		repo, repoErr := os.Stat(repoPath)
+		if cwdErr != nil || repoErr != nil || os.SameFile(cwd, repo) {
+			return "", repoFile
+		}
+	}
+	return firstConfigFile("."), repoFile
+}
+
+// firstConfigFile returns the first of configFileNames in dir, or ""
+func firstConfigFile(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML or JSON configuration file whose keys are
+// long flag names, e.g. {"workers": 8, "include": ["*.go"]}
+func loadConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]any)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		// Numbers are kept as written: as float64, 1000000 would be
+		// formatted as 1e+06, which integer flags reject
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyConfig sets flags from configuration values unless they were given on
+// the command line (under any alias). Unknown keys produce a warning on warn.
+func applyConfig(fs *flag.FlagSet, values map[string]any, warn io.Writer) error {
+	// Aliases such as -o and --output share the same Value
+	explicit := make(map[flag.Value]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Value] = true })
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		f := fs.Lookup(key)
+		if f == nil {
+			fmt.Fprintf(warn, "Warning: ignoring unknown config key %q\n", key)
+			continue
+		}
+		if configIgnoredKeys[key] {
+			fmt.Fprintf(warn, "Warning: ignoring config key %q, which can only be given on the command line\n", key)
+			continue
+		}
+		if explicit[f.Value] {
+			continue
+		}
+
+		// Lists set repeatable flags once per element
+		items, ok := values[key].([]any)
+		if !ok {
+			items = []any{values[key]}
+		}
+		for _, item := range items {
+			if err := fs.Set(key, fmt.Sprint(item)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for config key %q: %w", key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: ".repopsy.yaml",
+			content: `workers: 8
+branch-pattern: release/*
+commit-timeout: 2m
+html: true
+include: ["*.go", "*.md"]
+max-file-size: 1000000
+output: from-config
+colour: always
+`,
+		},
+		{
+			name:    "json",
+			file:    ".repopsy.json",
+			content: `{"workers": 8, "branch-pattern": "release/*", "commit-timeout": "2m", "html": true, "include": ["*.go", "*.md"], "max-file-size": 1000000, "output": "from-config", "colour": "always"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(workDir, tt.file), []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			t.Chdir(workDir)
+
+			var (
+				workers int
+				pattern string
+				output  string
+				timeout time.Duration
+				html    bool
+				include stringList
+				maxSize int64
+			)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.IntVar(&workers, "workers", 4, "")
+			fs.StringVar(&pattern, "branch-pattern", "", "")
+			fs.StringVar(&output, "o", "", "")
+			fs.StringVar(&output, "output", "", "")
+			fs.DurationVar(&timeout, "commit-timeout", 0, "")
+			fs.BoolVar(&html, "html", false, "")
+			fs.Var(&include, "include", "")
+			fs.Int64Var(&maxSize, "max-file-size", 0, "")
+
+			// A flag given on the command line under its short alias wins
+			if err := fs.Parse([]string{"-o", "from-cli"}); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			path, _ := findConfigFile(t.TempDir())
+			if path == "" {
+				t.Fatal("expected config file in the current directory to be found")
+			}
+			values, err := loadConfigFile(path)
+			if err != nil {
+				t.Fatalf("loadConfigFile failed: %v", err)
+			}
+
+			var warn bytes.Buffer
+			if err := applyConfig(fs, values, &warn); err != nil {
+				t.Fatalf("applyConfig failed: %v", err)
+			}
+
+			if workers != 8 || pattern != "release/*" || timeout != 2*time.Minute || !html {
+				t.Errorf("config values not applied: workers=%d pattern=%q timeout=%s html=%v", workers, pattern, timeout, html)
+			}
+			if maxSize != 1000000 {
+				t.Errorf("expected large integer to be applied, got %d", maxSize)
+			}
+			if include.String() != "*.go,*.md" {
+				t.Errorf("expected list to set repeatable flag, got %q", include.String())
+			}
+			if output != "from-cli" {
+				t.Errorf("expected command-line value to win, got %q", output)
+			}
+			if !strings.Contains(warn.String(), `"colour"`) {
+				t.Errorf("expected warning for unknown key, got %q", warn.String())
+			}
+		})
+	}
+}
+
+func TestFindConfigFileInRepository(t *testing.T) {
+	repoDir := t.TempDir()
+	repoConfig := filepath.Join(repoDir, ".repopsy.yaml")
+	if err := os.WriteFile(repoConfig, []byte("output: /tmp/elsewhere\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	// A file in the repository root is reported but not used, whether the
+	// repository is analyzed from elsewhere or from its own root
+	t.Chdir(t.TempDir())
+	if path, repoFile := findConfigFile(repoDir); path != "" || repoFile != repoConfig {
+		t.Errorf("expected only the repository file %s to be reported, got path=%q repoFile=%q", repoConfig, path, repoFile)
+	}
+	t.Chdir(repoDir)
+	if path, repoFile := findConfigFile("."); path != "" || repoFile == "" {
+		t.Errorf("expected the repository file to be reported from its root, got path=%q repoFile=%q", path, repoFile)
+	}
+
+	// A file in the current directory is still used next to it
+	workDir := t.TempDir()
+	workConfig := filepath.Join(workDir, ".repopsy.json")
+	if err := os.WriteFile(workConfig, []byte(`{"workers": 2}`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Chdir(workDir)
+	if path, repoFile := findConfigFile(repoDir); path != ".repopsy.json" || repoFile != repoConfig {
+		t.Errorf("expected the current directory file to be used, got path=%q repoFile=%q", path, repoFile)
+	}
+}
+
+func TestApplyConfigIgnoresExec(t *testing.T) {
+	var (
+		execCommand string
+		execJobs    int
+	)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&execCommand, "exec", "", "")
+	fs.IntVar(&execJobs, "exec-jobs", 1, "")
+
+	// A configuration file, possibly shipped with the analyzed repository,
+	// must not run commands
+	values := map[string]any{"exec": "touch PWNED", "exec-jobs": 4}
+	var warn bytes.Buffer
+	if err := applyConfig(fs, values, &warn); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if execCommand != "" || execJobs != 1 {
+		t.Errorf("expected exec flags to be ignored, got exec=%q exec-jobs=%d", execCommand, execJobs)
+	}
+	for _, key := range []string{`"exec"`, `"exec-jobs"`} {
+		if !strings.Contains(warn.String(), key) {
+			t.Errorf("expected warning for %s, got %q", key, warn.String())
+		}
+	}
+}
@@ -4,25 +4,171 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/andpalmier/repopsy/internal/app"
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/progress"
+	"github.com/mattn/go-isatty"
 )
 
 // CLI flags
 var (
-	outputDir   string
-	workers     int
-	limit       int
-	branch      string
-	verbose     bool
-	showVersion bool
-	showHelp    bool
+	outputDir     string
+	outTemplate   string
+	workers       int
+	branchWorkers int
+	niceMode      bool
+	maxGitProcs   int
+	jobDelay      time.Duration
+	limit         int
+	commitRev     string
+	commitsFile   string
+	fromStdin     bool
+	sortByDate    bool
+	mergeBase     string
+	toStdout      bool
+	listOnly      bool
+	branch        string
+	branchPattern string
+	branchExclude string
+	branchOrder   string
+	refsList      string
+	pickBranches  bool
+	firstParent   bool
+	newestFirst   bool
+	reflog        bool
+	tags          bool
+	tagPattern    string
+	semverSort    bool
+	skipEmpty     bool
+	useMailmap    bool
+	verbose       bool
+	quiet         bool
+	noColor       bool
+	cleanup       bool
+	commitTimeout time.Duration
+	gitTimeout    time.Duration
+	watch         bool
+	watchInterval time.Duration
+	progressMode  string
+	dateSource    string
+	htmlReport    bool
+	bundle        string
+	bundleRemove  bool
+	sizesReport   bool
+	treeMetrics   bool
+	includeDiff   bool
+	maxDiffLines  int
+	checksums     bool
+	verifyDir     string
+	patches       bool
+	review        bool
+	diffStat      bool
+	baseline      string
+	rawObjects    bool
+	execCommand   string
+	execJobs      int
+	noMetadata    bool
+	xattrMeta     bool
+	cas           bool
+	flatten       bool
+	allowedKeys   string
+	failUntrusted bool
+	failOnAny     bool
+	allowPartial  bool
+	csvPath       string
+	churnReport   bool
+	authorsReport bool
+	ignoreRevs    string
+	dupTrees      bool
+	graph         bool
+	compare       bool
+	folderTmpl    string
+	metadataTmpl  string
+	grepPatterns  stringList
+	grepIgnore    bool
+	grepAllMatch  bool
+	pickaxe       string
+	pickaxeRegex  string
+	includeGlobs  stringList
+	excludeGlobs  stringList
+	filterFile    string
+	maxFileSize   byteSize
+	keepEmpty     bool
+	deltaOnly     bool
+	inventory     bool
+	fileTimes     bool
+	fileTimesMax  int
+	warnBinary    byteSize
+	lfs           bool
+	submodules    bool
+	reproducible  bool
+	worktree      bool
+	flatSymlinks  bool
+	noPerms       bool
+	exportIgnore  bool
+	noExtTar      bool
+	configPath    string
+	logLevel      string
+	logJSON       bool
+	showVersion   bool
+	showHelp      bool
 )
 
+// stringList is a flag.Value collecting repeated string flags
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// byteSize is a flag.Value parsing sizes such as 512, 100K, 10M or 1.5GiB,
+// using binary (1024-based) units
+type byteSize int64
+
+var byteUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	num := strings.TrimRight(s, "KMGT")
+	unit, ok := byteUnits[s[len(num):]]
+	if !ok {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*b = byteSize(n * unit)
+	return nil
+}
+
 // Version information (set by main)
 var (
 	appVersion string
@@ -38,7 +184,7 @@ A forensic tool for analyzing git repository history (Repository Autopsy) by ext
 each commit's state into a separate folder for comparison and analysis.
 
 Usage:
-  repopsy [flags] <repository-path>
+  repopsy [flags] <repository-path>...
 
 Examples:
   # Extract all commits from all branches
@@ -50,9 +196,48 @@ Examples:
   # Extract from a specific branch only
   repopsy -b main /path/to/repo
 
+  # Extract only release branches, skipping release candidates
+  repopsy --branch-pattern 'release/*' --exclude-branch-pattern '*-rc*' .
+
+  # Extract only commits mentioning fixes or CVEs
+  repopsy --grep 'fix|CVE-' --grep-i .
+
+  # Extract only Go files, skipping vendored code
+  repopsy --include '*.go' --exclude vendor/ .
+
+  # Preview which commits would be extracted
+  repopsy --list -b main --grep fix .
+
+  # Extract several repositories into audit/<repo-name>/
+  repopsy -o audit /srv/api /srv/web /srv/infra.git
+
+  # Recover states lost to resets and rebases from the reflog
+  repopsy --reflog .
+
+  # Extract every release, in version order
+  repopsy --tags --tag-pattern 'v*' --semver-sort .
+
+  # Extract a single commit
+  repopsy --commit HEAD~3 /path/to/repo
+
+  # Stream a single commit's tree as a tar
+  repopsy --commit v1.2.0 --stdout /path/to/repo | tar -t
+
+  # Debug a run with JSON logs of every git command
+  repopsy --log-level debug --log-json --progress json . 2> repopsy.log
+
+  # Reuse flags from a config file (keys are long flag names)
+  repopsy --config audit.yaml .
+
   # Extract with verbose output
   repopsy -v .
 
+  # Extract and write an HTML index report
+  repopsy --html .
+
+  # Name folders with a sequence number and subject slug
+  repopsy --folder-template '{{printf "%04d" .Index}}_{{slug .Commit.Subject}}' .
+
 Flags:
 `
 )
@@ -61,19 +246,148 @@ Flags:
 func init() {
 	flag.StringVar(&outputDir, "o", "", "Output directory (default: ./<repo-name>-exploded)")
 	flag.StringVar(&outputDir, "output", "", "Output directory (default: ./<repo-name>-exploded)")
+	flag.StringVar(&outTemplate, "out-template", "", "Go template computing the output directory from .Repo, .Branch, .Date, .Time and .Remote (e.g. /archive/{{.Repo}}/{{.Date}})")
 
 	flag.IntVar(&workers, "w", runtime.NumCPU(), "Number of parallel workers")
 	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of parallel workers")
 
+	flag.IntVar(&branchWorkers, "branch-workers", config.DefaultConcurrentBranches, "Number of branches extracted concurrently (all-branches mode)")
+	flag.BoolVar(&niceMode, "nice", false, fmt.Sprintf("Reduce system load: at most %d git processes at once and a %s pause between commits, unless set explicitly", config.NiceGitProcs, config.NiceJobDelay))
+	flag.IntVar(&maxGitProcs, "max-git-procs", 0, "Maximum number of git processes running at once across all workers (0 = no cap)")
+	flag.DurationVar(&jobDelay, "job-delay", 0, "Pause each worker takes between commits, e.g. 200ms")
+
 	flag.IntVar(&limit, "n", 0, "Maximum number of commits to extract (0 = all)")
 	flag.IntVar(&limit, "limit", 0, "Maximum number of commits to extract (0 = all)")
 
+	flag.StringVar(&commitRev, "commit", "", "Extract only this commit (hash, tag or revision such as HEAD~3)")
+	flag.StringVar(&commitsFile, "commits-file", "", "Extract only the commits listed in this file, one hash per line (# for comments)")
+	flag.BoolVar(&fromStdin, "from-stdin", false, "Extract only the commits piped to stdin from git log, as hashes (--format=%H, --oneline) or full log lines")
+	flag.BoolVar(&sortByDate, "sort-by-date", false, "Extract the --commits-file or --from-stdin commits oldest first instead of in the listed order")
+	flag.StringVar(&mergeBase, "merge-base", "", "Extract the merge base and tips of two comma-separated branches, with the diffs between them")
+	flag.BoolVar(&toStdout, "stdout", false, "Stream the selected commit's tree as a tar to stdout")
+	flag.BoolVar(&listOnly, "list", false, "Print the selected commits as a table to stdout without extracting")
+
 	flag.StringVar(&branch, "b", "", "Branch to extract from (default: all branches)")
 	flag.StringVar(&branch, "branch", "", "Branch to extract from (default: all branches)")
 
+	flag.StringVar(&branchPattern, "branch-pattern", "", "Only extract branches matching these comma-separated globs, e.g. 'release/*' (all-branches mode)")
+	flag.StringVar(&refsList, "refs", "", "Extract the commits reachable from any of these comma-separated branches or tags, each once, into one folder sequence")
+	flag.StringVar(&branchExclude, "exclude-branch-pattern", "", "Skip branches matching these comma-separated globs (all-branches mode)")
+	flag.StringVar(&branchOrder, "branch-order", "", "Order of branches in all-branches mode: alpha, date (tip date, oldest first) or topo (ancestors first)")
+	flag.BoolVar(&pickBranches, "interactive", false, "List branches with their commit counts and prompt for which to extract (all-branches mode)")
+
+	flag.BoolVar(&firstParent, "first-parent", false, "Follow only the first parent of merge commits")
+	flag.BoolVar(&reflog, "reflog", false, "Extract the commits recorded in the reflog of HEAD (or of -b), including unreachable ones")
+	flag.BoolVar(&tags, "tags", false, "Extract the commit of each tag, in tag name order, into a folder prefixed with the tag")
+	flag.StringVar(&tagPattern, "tag-pattern", "", "Only extract tags matching this glob, e.g. 'v*' (with --tags)")
+	flag.BoolVar(&semverSort, "semver-sort", false, "Order tags by semantic version, skipping tags that are not one (with --tags)")
+	flag.BoolVar(&newestFirst, "newest-first", false, "Process and number commits from the newest (index 0) instead of the oldest")
+	flag.StringVar(&dateSource, "date-source", "", "Date that names folders and orders commits: commit or author (default: author date, git's order)")
+
+	flag.BoolVar(&skipEmpty, "skip-empty", false, "Skip commits that change nothing relative to their parent")
+
+	flag.BoolVar(&useMailmap, "mailmap", false, "Canonicalize author/committer identities through .mailmap")
+
 	flag.BoolVar(&verbose, "v", false, "Show detailed output per commit")
 	flag.BoolVar(&verbose, "verbose", false, "Show detailed output per commit")
 
+	flag.BoolVar(&quiet, "q", false, "Suppress banner and progress, print only a one-line summary")
+	flag.BoolVar(&quiet, "quiet", false, "Suppress banner and progress, print only a one-line summary")
+	flag.BoolVar(&noColor, "no-color", false, "Disable colored output (also set by the NO_COLOR environment variable)")
+
+	flag.Var(&includeGlobs, "include", "Only extract files matching this glob (repeatable)")
+	flag.Var(&excludeGlobs, "exclude", "Skip files matching this glob (repeatable)")
+	flag.StringVar(&filterFile, "filter-file", "", "File of globs, one per line (# for comments), of files to skip in every commit")
+	flag.Var(&maxFileSize, "max-file-size", "Skip files larger than this size, e.g. 500K, 10M or 1G (default: no limit)")
+	flag.BoolVar(&deltaOnly, "delta-only", false, "Extract only the files each commit added or modified, with their full content, instead of the whole tree")
+	flag.BoolVar(&inventory, "inventory", false, "Gather the metadata of every commit (message, stats, signature, parents) into inventory.csv without extracting any tree")
+	flag.BoolVar(&fileTimes, "set-file-times", false, "Set the modification time of each extracted file to the author date of the last commit that changed it")
+	flag.IntVar(&fileTimesMax, "file-times-max-files", config.DefaultFileTimesMaxFiles, "Leave the extraction time on commits with more files than this with --set-file-times, as dating them walks the history")
+	flag.BoolVar(&keepEmpty, "keep-empty-dirs", false, "Recreate directories of the commit's tree left empty because all their files exceeded --max-file-size")
+	flag.Var(&warnBinary, "warn-binary-bytes", "Warn about commits adding or modifying more than this size of binary files, e.g. 5M (default: no check)")
+	flag.BoolVar(&worktree, "include-worktree", false, "Also copy the uncommitted working tree, including untracked files not ignored, into working_tree/")
+	flag.BoolVar(&submodules, "submodules", false, "Extract each submodule's commit into its path when it is available locally")
+	flag.BoolVar(&lfs, "lfs", false, "Replace Git LFS pointer files with their content using git-lfs, or count them if it is not installed")
+
+	flag.BoolVar(&flatSymlinks, "flatten-symlinks", false, "Write symlinks as regular files containing the link target")
+	flag.BoolVar(&reproducible, "reproducible", false, "Set file times to the commit's author date and normalize modes, for identical output across runs")
+	flag.BoolVar(&noPerms, "no-preserve-perms", false, "Apply the process umask instead of git's file modes")
+	flag.BoolVar(&exportIgnore, "respect-export-ignore", true, "Leave out paths marked export-ignore in .gitattributes (use =false to extract them)")
+	flag.BoolVar(&noExtTar, "no-external-tar", false, "Unpack commit trees with the built-in tar reader instead of tar (automatic when tar is not in PATH)")
+
+	flag.StringVar(&allowedKeys, "allowed-keys", "", "File of GPG key IDs accepted as signers; marks each commit TRUSTED, UNTRUSTED or UNSIGNED")
+	flag.BoolVar(&failUntrusted, "fail-on-untrusted", false, "Exit non-zero if any commit is not signed by an allowed key")
+	flag.BoolVar(&failOnAny, "fail-on-any", false, "Exit with status 1 instead of 2 when some commits failed to extract")
+	flag.BoolVar(&allowPartial, "allow-partial", false, "Exit with status 0 when some commits failed to extract but others succeeded")
+
+	flag.Var(&grepPatterns, "grep", "Only extract commits whose message matches this regex (repeatable)")
+	flag.BoolVar(&grepIgnore, "grep-i", false, "Match --grep case-insensitively")
+	flag.BoolVar(&grepAllMatch, "all-match", false, "Require every --grep pattern to match instead of any")
+
+	flag.StringVar(&pickaxe, "pickaxe", "", "Only extract commits that add or remove this string (git log -S)")
+	flag.StringVar(&pickaxeRegex, "pickaxe-regex", "", "Only extract commits whose diff adds or removes a line matching this regex (git log -G)")
+
+	// Partial folders are removed on Ctrl-C by default when running interactively
+	interactive := isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+	flag.BoolVar(&cleanup, "cleanup-on-interrupt", interactive, "Remove partially extracted folders when interrupted (default: on for interactive terminals)")
+
+	flag.DurationVar(&commitTimeout, "commit-timeout", 0, "Abort a single commit's extraction after this duration, e.g. 2m (0 = no limit)")
+	flag.DurationVar(&gitTimeout, "git-timeout", 0, "Kill any single git command running longer than this duration, e.g. 30s (0 = no limit)")
+
+	flag.BoolVar(&watch, "watch", false, "After extracting, keep extracting new commits of the branch (default: HEAD) into the output directory until interrupted")
+	flag.DurationVar(&watchInterval, "watch-interval", config.DefaultWatchInterval, "Interval between checks for new commits with --watch")
+
+	flag.StringVar(&progressMode, "progress", progress.ModeBar, "Progress output: bar (stderr) or json (NDJSON on stdout)")
+
+	flag.BoolVar(&htmlReport, "html", false, "Write an index.html report to the output directory")
+	flag.StringVar(&bundle, "bundle", "", "Archive the finished output directory as <output>.<format>: tar, tar.zst or zip")
+	flag.BoolVar(&bundleRemove, "bundle-remove", false, "Delete the output directory once it is bundled (requires --bundle)")
+
+	flag.BoolVar(&sizesReport, "sizes", false, "Write a sizes.txt report of file sizes into each commit folder")
+
+	flag.BoolVar(&noMetadata, "no-metadata", false, "Extract only the tree of each commit, skipping COMMIT_INFO.txt and its git calls")
+
+	flag.BoolVar(&cas, "cas", false, "Store each distinct file once under objects/ and make commit folders trees of symlinks into it")
+
+	flag.BoolVar(&xattrMeta, "xattr-metadata", false, "Also store key commit fields as user.repopsy.* extended attributes of each folder (with --no-metadata: instead of COMMIT_INFO.txt)")
+
+	flag.BoolVar(&treeMetrics, "metrics", false, "Add file count and tree depth to COMMIT_INFO.txt")
+	flag.BoolVar(&includeDiff, "include-diff-in-metadata", false, "Append each commit's diff to COMMIT_INFO.txt in a DIFF section")
+	flag.IntVar(&maxDiffLines, "max-diff-lines", config.DefaultMaxDiffLines, "Lines of each diff kept with --include-diff-in-metadata (-1 = no limit)")
+
+	flag.BoolVar(&checksums, "checksums", false, "Write a sha256sum-compatible SHA256SUMS manifest into each commit folder")
+	flag.StringVar(&verifyDir, "verify", "", "Check the commit folders under this output directory against their SHA256SUMS manifests instead of extracting")
+
+	flag.BoolVar(&patches, "patches", false, "Also write each commit as patches/<seq>.patch in git format-patch form")
+	flag.BoolVar(&review, "review", false, "Write only a NNNN-shorthash-subject.patch series of the branch (default HEAD) with an INDEX.md, for sequential review")
+
+	flag.BoolVar(&diffStat, "diff-stat", false, "Write the git show --stat summary of each commit as diffstat.txt into its folder")
+	flag.StringVar(&baseline, "baseline", "", "Write the diff from this ref to each commit as vs_baseline.diff into its folder")
+	flag.StringVar(&execCommand, "exec", "", "Shell command run in each commit folder after extraction, {dir} being replaced by its path; exit code and output go to exec.log")
+	flag.IntVar(&execJobs, "exec-jobs", 1, "Maximum number of --exec commands running at once")
+	flag.BoolVar(&rawObjects, "raw", false, "Write the raw commit object (raw/object.txt) and tree listing with modes and object IDs (raw/tree.txt) into each commit folder")
+
+	flag.BoolVar(&flatten, "flatten", false, "Write one <branch>__<folder>.tar per commit directly into the output directory")
+
+	flag.StringVar(&csvPath, "csv", "", "Write a CSV summary of all extracted commits to this file")
+
+	flag.BoolVar(&churnReport, "churn", false, "Write a daily lines-of-code churn.csv series to the output directory")
+	flag.BoolVar(&authorsReport, "authors", false, "Write per-author commit and line totals to authors.csv and authors.txt in the output directory")
+	flag.StringVar(&ignoreRevs, "ignore-revs-file", "", "Leave the commits listed in this file, e.g. .git-blame-ignore-revs, out of --churn and --authors statistics")
+	flag.BoolVar(&dupTrees, "duplicate-trees", false, "Write duplicate_trees.txt to the output directory, grouping distinct commits with identical trees")
+
+	flag.BoolVar(&compare, "compare", false, "Write a diffs/NNNN-to-NNNN.diff file between each pair of consecutive extracted commits")
+
+	flag.BoolVar(&graph, "graph", false, "Print an ASCII graph of the extracted commits and their folders to stdout after extraction")
+
+	flag.StringVar(&folderTmpl, "folder-template", "", "Go template for commit folder names (fields: .Index, .Commit; funcs: slug, date)")
+	flag.StringVar(&metadataTmpl, "metadata-template", "", "File with a Go template replacing the COMMIT_INFO.txt layout (fields of the commit; func: formatGPGStatus)")
+
+	flag.StringVar(&logLevel, "log-level", "", "Write structured logs to stderr at this level: debug, info, warn or error (default: off)")
+	flag.BoolVar(&logJSON, "log-json", false, "Write logs as JSON lines instead of text")
+
+	flag.StringVar(&configPath, "config", "", "Read flag defaults from this YAML or JSON file (default: .repopsy.yaml/.repopsy.json in the current directory; one in the analyzed repository is only read when named here)")
+
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
 	flag.BoolVar(&showHelp, "h", false, "Show help message")
 	flag.BoolVar(&showHelp, "help", false, "Show help message")
@@ -85,6 +399,24 @@ func init() {
 	}
 }
 
+// newLogger creates the structured logger for the given level, or returns
+// nil when level is empty and logging is disabled
+func newLogger(level string, asJSON bool, w io.Writer) (*slog.Logger, error) {
+	if level == "" {
+		return nil, nil
+	}
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	if asJSON {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
+
 // Execute runs the CLI application and returns an exit code
 func Execute(version, commit, date string) int {
 	appVersion = version
@@ -105,7 +437,7 @@ func Execute(version, commit, date string) int {
 		return 0
 	}
 
-	// Get repository path from positional arguments
+	// Get repository paths from positional arguments
 	args := flag.Args()
 	if len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: repository path is required")
@@ -115,14 +447,141 @@ func Execute(version, commit, date string) int {
 	}
 	repoPath := args[0]
 
+	// Flags given on the command line take precedence over the config file,
+	// which is looked up in the current directory. One shipped with the
+	// repository is only used when named with --config.
+	if configPath == "" {
+		var repoConfig string
+		configPath, repoConfig = findConfigFile(repoPath)
+		if repoConfig != "" {
+			fmt.Fprintf(os.Stderr, "Note: ignoring %s from the repository, use --config %s to apply it\n", repoConfig, repoConfig)
+		}
+		if configPath != "" {
+			fmt.Fprintf(os.Stderr, "Note: using config file %s\n", configPath)
+		}
+	}
+	if configPath != "" {
+		values, err := loadConfigFile(configPath)
+		if err == nil {
+			err = applyConfig(flag.CommandLine, values, os.Stderr)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	// Prompting makes no sense when the output is piped or redirected
+	if pickBranches && !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		fmt.Fprintln(os.Stderr, "Note: stdout is not a terminal, ignoring --interactive")
+		pickBranches = false
+	}
+
+	logger, err := newLogger(logLevel, logJSON, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
 	// Run application
 	cfg := app.Config{
-		RepoPath:  repoPath,
-		OutputDir: outputDir,
-		Workers:   workers,
-		Limit:     limit,
-		Branch:    branch,
-		Verbose:   verbose,
+		RepoPath:           repoPath,
+		OutputDir:          outputDir,
+		OutTemplate:        outTemplate,
+		Workers:            workers,
+		BranchWorkers:      branchWorkers,
+		Nice:               niceMode,
+		MaxGitProcs:        maxGitProcs,
+		JobDelay:           jobDelay,
+		Limit:              limit,
+		Commit:             commitRev,
+		CommitsFile:        commitsFile,
+		FromStdin:          fromStdin,
+		SortByDate:         sortByDate,
+		MergeBase:          mergeBase,
+		Stdout:             toStdout,
+		List:               listOnly,
+		FirstParent:        firstParent,
+		NewestFirst:        newestFirst,
+		DateSource:         dateSource,
+		Reflog:             reflog,
+		Tags:               tags,
+		TagPattern:         tagPattern,
+		SemverSort:         semverSort,
+		SkipEmpty:          skipEmpty,
+		Mailmap:            useMailmap,
+		Branch:             branch,
+		BranchPattern:      branchPattern,
+		ExcludeBranches:    branchExclude,
+		BranchOrder:        branchOrder,
+		Refs:               refsList,
+		Interactive:        pickBranches,
+		Verbose:            verbose,
+		Quiet:              quiet,
+		NoColor:            noColor,
+		CleanupOnInterrupt: cleanup,
+		CommitTimeout:      commitTimeout,
+		GitTimeout:         gitTimeout,
+		Watch:              watch,
+		WatchInterval:      watchInterval,
+		Progress:           progressMode,
+		Sizes:              sizesReport,
+		Metrics:            treeMetrics,
+		IncludeDiff:        includeDiff,
+		MaxDiffLines:       maxDiffLines,
+		Checksums:          checksums,
+		Verify:             verifyDir,
+		Patches:            patches,
+		Review:             review,
+		DiffStat:           diffStat,
+		Baseline:           baseline,
+		Raw:                rawObjects,
+		Exec:               execCommand,
+		ExecJobs:           execJobs,
+		NoMetadata:         noMetadata,
+		XattrMetadata:      xattrMeta,
+		CAS:                cas,
+		Flatten:            flatten,
+		AllowedKeys:        allowedKeys,
+		FailOnUntrusted:    failUntrusted,
+		FailOnAny:          failOnAny,
+		AllowPartial:       allowPartial,
+		HTML:               htmlReport,
+		Bundle:             bundle,
+		BundleRemove:       bundleRemove,
+		CSVPath:            csvPath,
+		Churn:              churnReport,
+		Authors:            authorsReport,
+		IgnoreRevsFile:     ignoreRevs,
+		DuplicateTrees:     dupTrees,
+		Graph:              graph,
+		Compare:            compare,
+		FolderTemplate:     folderTmpl,
+		MetadataTemplate:   metadataTmpl,
+		Include:            includeGlobs,
+		Exclude:            excludeGlobs,
+		FilterFile:         filterFile,
+		MaxFileSize:        int64(maxFileSize),
+		KeepEmptyDirs:      keepEmpty,
+		DeltaOnly:          deltaOnly,
+		Inventory:          inventory,
+		SetFileTimes:       fileTimes,
+		FileTimesMaxFiles:  fileTimesMax,
+		WarnBinaryBytes:    int64(warnBinary),
+		LFS:                lfs,
+		Submodules:         submodules,
+		Reproducible:       reproducible,
+		IncludeWorktree:    worktree,
+		FlattenSymlinks:    flatSymlinks,
+		NoPreservePerms:    noPerms,
+		NoExportIgnore:     !exportIgnore,
+		NoExternalTar:      noExtTar,
+		Grep:               grepPatterns,
+		GrepAllMatch:       grepAllMatch,
+		Pickaxe:            pickaxe,
+		PickaxeRegex:       pickaxeRegex,
+		GrepIgnoreCase:     grepIgnore,
+		Logger:             logger,
 	}
 
 	// Set up context with cancellation for graceful shutdown
@@ -138,12 +597,11 @@ func Execute(version, commit, date string) int {
 		cancel()
 	}()
 
-	if err := app.Run(ctx, cfg); err != nil {
+	err = app.RunRepositories(ctx, cfg, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return 1
 	}
-
-	return 0
+	return app.ExitCode(err)
 }
 
 // printVersion displays version information
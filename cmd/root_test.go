@@ -0,0 +1,30 @@
+package cmd
+
+import "testing"
+
+func TestByteSizeSet(t *testing.T) {
+	for in, want := range map[string]int64{
+		"512":    512,
+		"100K":   100 << 10,
+		"10M":    10 << 20,
+		"10mb":   10 << 20,
+		"1.5GiB": 3 << 29,
+		"1e+06":  1000000,
+	} {
+		var b byteSize
+		if err := b.Set(in); err != nil {
+			t.Errorf("Set(%q) failed: %v", in, err)
+			continue
+		}
+		if int64(b) != want {
+			t.Errorf("Set(%q) = %d, want %d", in, b, want)
+		}
+	}
+
+	for _, in := range []string{"", "M", "-1K", "10X", "ten"} {
+		var b byteSize
+		if err := b.Set(in); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", in)
+		}
+	}
+}
@@ -1,162 +1,1526 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
+	"github.com/andpalmier/repopsy/internal/colors"
+	"github.com/andpalmier/repopsy/internal/config"
 	"github.com/andpalmier/repopsy/internal/extractor"
 	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/andpalmier/repopsy/internal/progress"
+	"github.com/andpalmier/repopsy/internal/report"
 	"github.com/fatih/color"
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrNoCommits is returned for a repository without any commit, such as a
+// freshly initialized one. The command line reports it and exits cleanly.
+var ErrNoCommits = errors.New("repository has no commits")
+
+// Exit codes of the command line
+const (
+	ExitOK      = 0 // Every selected commit was extracted
+	ExitFatal   = 1 // Setup failed, no commit could be extracted, or --fail-on-any
+	ExitPartial = 2 // Some commits failed to extract while others succeeded
+)
+
+// PartialFailureError is returned by Run when some commits failed to
+// extract while others succeeded, unless FailOnAny or AllowPartial is set
+type PartialFailureError struct {
+	Err error
+}
+
+func (e *PartialFailureError) Error() string { return e.Err.Error() }
+
+func (e *PartialFailureError) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit code for the error of Run or RunRepositories:
+// ExitPartial when every failure is partial, ExitFatal for any other error
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case isPartialFailure(err):
+		return ExitPartial
+	}
+	return ExitFatal
+}
+
+// isPartialFailure reports whether err, or each error joined in it, is a
+// PartialFailureError
+func isPartialFailure(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !isPartialFailure(e) {
+				return false
+			}
+		}
+		return true
+	}
+	var partial *PartialFailureError
+	return errors.As(err, &partial)
+}
+
+// Orders of the branches in all-branches mode
+const (
+	BranchOrderAlpha = "alpha" // By name, as listed by git (default)
+	BranchOrderDate  = "date"  // By tip date, oldest first
+	BranchOrderTopo  = "topo"  // Ancestors before descendants
 )
 
 // Config holds the application configuration
 type Config struct {
-	RepoPath  string
-	OutputDir string
-	Workers   int
-	Limit     int
-	Branch    string // If empty, extract all branches
-	Verbose   bool
+	RepoPath           string
+	OutputDir          string
+	OutTemplate        string // Go template rendering the output directory (fields: .Repo, .Branch, .Date, .Time, .Remote)
+	Workers            int
+	BranchWorkers      int // Branches processed concurrently in all-branches mode
+	Limit              int
+	Commit             string // Single commit to extract (bypasses branch listing)
+	CommitsFile        string // File listing the commits to extract, one hash per line (bypasses branch listing)
+	FromStdin          bool   // Extract the commits piped to stdin from git log, as hashes or full log lines (bypasses branch listing)
+	SortByDate         bool   // Extract the listed commits by date instead of in the listed order
+	MergeBase          string // Two comma-separated branches whose merge base and tips are extracted and diffed
+	Stdout             bool   // Stream the selected commit's tree as a tar to stdout
+	List               bool   // Print the selected commits to stdout without extracting
+	FirstParent        bool   // Follow only the first parent of merges
+	NewestFirst        bool   // Number commits from the newest (index 0) instead of the oldest
+	DateSource         string // git.DateAuthor or git.DateCommit: date naming folders and ordering commits (default: author date, git's order)
+	Reflog             bool   // Extract the commits in the reflog of Branch (default HEAD), including unreachable ones
+	Tags               bool   // Extract the commit of each tag, in tag name order, instead of a history
+	TagPattern         string // Glob selecting the tags of tags mode (default: all)
+	SemverSort         bool   // Order tags by semantic version, leaving out tags that are not one
+	Mailmap            bool   // Canonicalize identities through .mailmap
+	Branch             string // If empty, extract all branches
+	BranchPattern      string // Comma-separated globs selecting branches in all-branches mode
+	ExcludeBranches    string // Comma-separated globs of branches to skip in all-branches mode
+	BranchOrder        string // BranchOrderAlpha, BranchOrderDate or BranchOrderTopo: order of branches in all-branches mode
+	Interactive        bool   // Prompt for the branches to extract in all-branches mode
+	Verbose            bool
+	Quiet              bool          // Suppress banner, progress and informational output
+	NoColor            bool          // Disable colored output, as does the NO_COLOR environment variable
+	CleanupOnInterrupt bool          // Remove partially written folders when interrupted
+	CommitTimeout      time.Duration // Abort a single commit's extraction after this long (0 = none)
+	GitTimeout         time.Duration // Kill any single git command running longer than this (0 = none)
+	Watch              bool          // After extracting, keep extracting new commits of Branch (default HEAD) until interrupted
+	WatchInterval      time.Duration // Interval between checks for new commits (0 = config.DefaultWatchInterval)
+	Progress           string        // Progress output mode: "bar" (default) or "json"
+	Sizes              bool          // Write a sizes.txt report into each commit folder
+	Metrics            bool          // Add file count and tree depth to the metadata
+	IncludeDiff        bool          // Append each commit's diff to the metadata
+	MaxDiffLines       int           // Lines of the metadata diff kept (0 = config.DefaultMaxDiffLines, -1 = no limit)
+	Checksums          bool          // Write a SHA256SUMS manifest into each commit folder
+	Verify             string        // Check the SHA256SUMS manifests under this output directory instead of extracting
+	Patches            bool          // Write each commit as a format-patch file under patches/
+	Review             bool          // Write only a numbered patch series with an INDEX.md, instead of trees (default branch: HEAD)
+	DiffStat           bool          // Write a diffstat.txt change summary into each commit folder
+	Baseline           string        // Ref each commit is diffed against into vs_baseline.diff in its folder
+	Raw                bool          // Write the raw commit object and recursive tree listing under raw/ in each commit folder
+	Exec               string        // Shell command run in each commit folder after extraction, {dir} being its path
+	ExecJobs           int           // Exec commands running at once (default 1)
+	Nice               bool          // Reduce system load: cap git subprocesses and pause between commits, with gentle defaults
+	MaxGitProcs        int           // Git subprocesses running at once across all workers (0 = no cap)
+	JobDelay           time.Duration // Pause each worker takes between commits
+	NoMetadata         bool          // Extract only trees, without COMMIT_INFO.txt or its git calls
+	XattrMetadata      bool          // Also store key commit fields as extended attributes of each folder
+	CAS                bool          // Store each distinct file once under objects/ and symlink commit folders to it
+	Flatten            bool          // Write one branch__folder.tar per commit directly into the output directory
+	AllowedKeys        string        // File of GPG key IDs accepted as signers (empty = no trust check)
+	FailOnUntrusted    bool          // Fail the run when a commit is not signed by an allowed key
+	FailOnAny          bool          // Treat a run in which some commits failed as fatal (ExitFatal)
+	AllowPartial       bool          // Succeed when some commits failed while others were extracted
+	HTML               bool          // Write an index.html report to the output directory
+	Bundle             string        // Archive the finished output directory as <out>.<format>: tar, tar.zst or zip
+	BundleRemove       bool          // Delete the output directory once it is bundled
+	CSVPath            string        // If set, write a CSV summary of all commits to this file
+	Churn              bool          // Write a daily churn.csv series to the output directory
+	Authors            bool          // Write per-author totals to authors.csv and authors.txt in the output directory
+	IgnoreRevsFile     string        // File of commits, like .git-blame-ignore-revs, left out of the churn and author statistics
+	DuplicateTrees     bool          // Write duplicate_trees.txt, grouping distinct commits with identical trees
+	Graph              bool          // Print an ASCII graph of the extracted commits to stdout
+	Compare            bool          // Write diffs between consecutive commits into diffs/
+	SkipEmpty          bool          // Skip commits whose tree equals their parent's
+	FolderTemplate     string        // Template for commit folder names (empty = default)
+	MetadataTemplate   string        // File holding a template replacing the COMMIT_INFO.txt layout
+	Include            []string      // Only extract files matching these globs
+	FlattenSymlinks    bool          // Write symlinks as regular files containing the target
+	NoPreservePerms    bool          // Let the process umask apply to extracted files
+	NoExportIgnore     bool          // Also extract paths marked export-ignore in .gitattributes
+	NoExternalTar      bool          // Unpack commit trees with the built-in tar reader even when tar is installed
+	Exclude            []string      // Skip files matching these globs
+	FilterFile         string        // File of globs, one per line, of files to skip in every commit
+	MaxFileSize        int64         // Skip files larger than this many bytes (0 = no limit)
+	KeepEmptyDirs      bool          // Recreate tree directories emptied by MaxFileSize
+	DeltaOnly          bool          // Extract only the files each commit added or modified
+	Inventory          bool          // Gather every commit's metadata into inventory.csv without extracting trees
+	SetFileTimes       bool          // Date each extracted file by the last commit that changed it
+	FileTimesMaxFiles  int           // Trees with more files keep the extraction time (0 = config.DefaultFileTimesMaxFiles)
+	WarnBinaryBytes    int64         // Flag commits adding or modifying more binary bytes than this (0 = no check)
+	LFS                bool          // Detect Git LFS pointers and materialize them with git-lfs
+	Submodules         bool          // Extract locally available submodule commits into their paths
+	Reproducible       bool          // Set mtimes to the author date and normalize modes
+	IncludeWorktree    bool          // Also snapshot the uncommitted working tree into working_tree/
+	Grep               []string      // Only extract commits whose message matches one of these regexes
+	GrepAllMatch       bool          // Require every Grep regex to match
+	GrepIgnoreCase     bool          // Match Grep case-insensitively
+	Pickaxe            string        // Only extract commits changing the number of occurrences of this string
+	PickaxeRegex       string        // Only extract commits adding or removing a line matching this regex
+	Refs               string        // Comma-separated refs whose reachable commits are extracted once, into one folder sequence
+
+	// Logger receives structured logs of the run (nil = no logging)
+	Logger *slog.Logger
+
+	// silent disables all terminal output, set by Extract for library use
+	silent bool
+
+	// allowlist is loaded from AllowedKeys before extraction starts
+	allowlist git.KeyAllowlist
+
+	// metadataTmpl is loaded from MetadataTemplate before extraction starts
+	metadataTmpl *template.Template
+
+	// filterGlobs are loaded from FilterFile before extraction starts and
+	// excluded along with Exclude
+	filterGlobs []string
+
+	// ignoreRevs are loaded from IgnoreRevsFile before extraction starts
+	ignoreRevs []string
+
+	// baselineHash is the commit Baseline resolves to, looked up once so
+	// that every commit is diffed against the same one
+	baselineHash string
+
+	// gitProcs is shared by all extractors of the run to enforce MaxGitProcs
+	gitProcs *semaphore.Weighted
+
+	// firstIndex numbers the first commit extracted, continuing an earlier
+	// extraction in watch mode
+	firstIndex int
+}
+
+// listOptions returns the commit listing options for the given branch
+func (cfg Config) listOptions(branch string) git.ListOptions {
+	return git.ListOptions{
+		Branch:         branch,
+		Refs:           splitPatterns(cfg.Refs),
+		Limit:          cfg.Limit,
+		Reverse:        !cfg.NewestFirst,
+		FirstParent:    cfg.FirstParent,
+		UseMailmap:     cfg.Mailmap,
+		GrepPatterns:   cfg.Grep,
+		GrepAllMatch:   cfg.GrepAllMatch,
+		GrepIgnoreCase: cfg.GrepIgnoreCase,
+		PickaxeString:  cfg.Pickaxe,
+		PickaxeRegex:   cfg.PickaxeRegex,
+		DateOrder:      cfg.DateSource,
+	}
+}
+
+// extractorConfig returns the extractor configuration for a branch output directory
+func (cfg Config) extractorConfig(outDir, branch string) extractor.Config {
+	extCfg := extractor.Config{
+		OutputDir:      outDir,
+		Branch:         branch,
+		Workers:        cfg.Workers,
+		Verbose:        cfg.Verbose,
+		Sizes:          cfg.Sizes,
+		Metrics:        cfg.Metrics,
+		IncludeDiff:    cfg.IncludeDiff,
+		MaxDiffLines:   cfg.MaxDiffLines,
+		Checksums:      cfg.Checksums,
+		Patches:        cfg.Patches,
+		DiffStat:       cfg.DiffStat,
+		Raw:            cfg.Raw,
+		Exec:           cfg.Exec,
+		ExecJobs:       cfg.ExecJobs,
+		NoMetadata:     cfg.NoMetadata,
+		Xattrs:         cfg.XattrMetadata,
+		FolderTemplate: cfg.FolderTemplate,
+		IncludeGlobs:   cfg.Include,
+		ExcludeGlobs:   slices.Concat(cfg.Exclude, cfg.filterGlobs),
+		MaxFileSize:    cfg.MaxFileSize,
+		KeepEmptyDirs:  cfg.KeepEmptyDirs,
+		DeltaOnly:      cfg.DeltaOnly,
+		Inventory:      cfg.Inventory,
+		FileTimes:      cfg.SetFileTimes,
+		LFS:            cfg.LFS,
+		Submodules:     cfg.Submodules,
+		Reproducible:   cfg.Reproducible,
+
+		CleanupOnInterrupt: cfg.CleanupOnInterrupt,
+		PerCommitTimeout:   cfg.CommitTimeout,
+		AllowedKeys:        cfg.allowlist,
+		MetadataTemplate:   cfg.metadataTmpl,
+		GitProcs:           cfg.gitProcs,
+		JobDelay:           cfg.JobDelay,
+		FirstIndex:         cfg.firstIndex,
+		Baseline:           cfg.baselineHash,
+		SkipEmpty:          cfg.SkipEmpty,
+		WarnBinaryBytes:    cfg.WarnBinaryBytes,
+		FileTimesMaxFiles:  cfg.FileTimesMaxFiles,
+		Logger:             cfg.Logger,
+	}
+	if extCfg.FileTimesMaxFiles == 0 {
+		extCfg.FileTimesMaxFiles = config.DefaultFileTimesMaxFiles
+	}
+	if cfg.FolderTemplate == "" {
+		extCfg.FolderTemplate = config.DefaultFolderTemplate
+		if cfg.DateSource == git.DateCommit {
+			extCfg.FolderTemplate = config.CommitDateFolderTemplate
+		}
+		if cfg.Reflog {
+			extCfg.FolderTemplate = config.ReflogFolderPrefix + extCfg.FolderTemplate
+		}
+		if cfg.Tags {
+			extCfg.FolderTemplate = config.TagFolderPrefix + extCfg.FolderTemplate
+		}
+		if cfg.MergeBase != "" {
+			extCfg.FolderTemplate = config.MergeBaseFolderPrefix + extCfg.FolderTemplate
+		}
+	}
+	if cfg.CAS {
+		extCfg.CASDir = filepath.Join(outDir, config.CASDirName)
+	}
+	if cfg.Flatten {
+		extCfg.Flatten = true
+		if branch != "" {
+			extCfg.NamePrefix = sanitizeBranchName(branch) + "__"
+		}
+	}
+	return extCfg
+}
+
+// branchOutputDir returns the directory a branch is extracted into in
+// all-branches mode; flattened archives all share the output directory
+func (cfg Config) branchOutputDir(outDir, branch string) string {
+	if cfg.Flatten {
+		return outDir
+	}
+	return filepath.Join(outDir, sanitizeBranchName(branch))
+}
+
+// stderr receives human-readable output; tests replace it to capture output
+var stderr io.Writer = os.Stderr
+
+// logger returns the configured logger, discarding logs when none is set
+func (cfg Config) logger() *slog.Logger {
+	if cfg.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return cfg.Logger
+}
+
+// out returns the writer for informational output, discarding it when quiet
+func (cfg Config) out() io.Writer {
+	if cfg.Quiet || cfg.silent {
+		return io.Discard
+	}
+	return stderr
+}
+
+// newProgress creates progress reporters; tests replace it to observe progress
+var newProgress = progress.New
+
+// progressWriter returns the progress output writer, nil selecting the mode default
+func (cfg Config) progressWriter() io.Writer {
+	if cfg.Progress == progress.ModeJSON {
+		return nil
+	}
+	return stderr
+}
+
+// newReporter creates the progress reporter for the configured mode
+func (cfg Config) newReporter(total int) progress.Reporter {
+	if cfg.silent {
+		return newProgress(progress.Config{Total: total, Quiet: true})
+	}
+	return newProgress(progress.Config{
+		Total:      total,
+		FirstIndex: cfg.firstIndex,
+		Verbose:    cfg.Verbose,
+		Quiet:      cfg.Quiet,
+		Mode:       cfg.Progress,
+		Writer:     cfg.progressWriter(),
+	})
+}
+
+// Run executes the repopsy application logic, printing progress and a
+// summary to the terminal
+func Run(ctx context.Context, cfg Config) error {
+	_, err := run(ctx, cfg)
+	return err
+}
+
+// run implements Run, also returning the extraction report (nil in stream
+// and list modes)
+func run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	colors.Configure(cfg.NoColor)
+
+	// Verification reads an earlier extraction; the repository is not needed
+	if cfg.Verify != "" {
+		return nil, runVerify(cfg, os.Stdout)
+	}
+
+	if err := git.CheckTools(); err != nil {
+		return nil, err
+	}
+
+	// Stream and list modes write to stdout, no folders are created
+	if cfg.Stdout || cfg.List {
+		repo, err := cfg.openRepository()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = repo.Close() }()
+		if err := requireCommits(ctx, repo); err != nil {
+			return nil, cfg.reportNoCommits(err)
+		}
+
+		if cfg.List {
+			return nil, runList(ctx, repo, cfg, os.Stdout)
+		}
+		return nil, runStdout(ctx, repo, cfg, os.Stdout)
+	}
+
+	// Watch mode follows a single branch, HEAD unless one is given
+	if cfg.Watch && cfg.Branch == "" {
+		cfg.Branch = "HEAD"
+	}
+
+	rep, err := extract(ctx, cfg)
+	if errors.Is(err, ErrNoCommits) {
+		return nil, cfg.reportNoCommits(err)
+	}
+	err = cfg.partialFailure(rep, err)
+	if rep != nil {
+		printSummary(rep, cfg)
+		if cfg.Graph && len(rep.Results) > 0 {
+			if gerr := report.WriteGraph(os.Stdout, rep.OutputDir, rep.Results); gerr != nil {
+				return rep, errors.Join(err, gerr)
+			}
+		}
+	}
+	if cfg.Watch && rep != nil && ctx.Err() == nil {
+		err = errors.Join(err, runWatch(ctx, cfg, rep))
+	}
+	return rep, err
+}
+
+// partialFailure applies the partial failure policy to the error of a run
+// in which some commits failed to extract while others succeeded
+func (cfg Config) partialFailure(rep *Report, err error) error {
+	if rep == nil || rep.Succeeded == 0 || !errors.Is(err, extractor.ErrCommitsFailed) || cfg.FailOnAny {
+		return err
+	}
+	if cfg.AllowPartial {
+		cfg.logger().Warn("commits failed, allowed by --allow-partial", "failed", rep.Failed, "error", err)
+		return nil
+	}
+	return &PartialFailureError{Err: err}
+}
+
+// requireCommits returns ErrNoCommits for a repository without commits, on
+// which every mode would otherwise fail with a cryptic git error
+func requireCommits(ctx context.Context, repo *git.Repository) error {
+	has, err := repo.HasCommits(ctx)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNoCommits
+	}
+	return nil
+}
+
+// reportNoCommits prints ErrNoCommits as a message and clears it, so that
+// the command exits cleanly; other errors are returned unchanged
+func (cfg Config) reportNoCommits(err error) error {
+	if !errors.Is(err, ErrNoCommits) {
+		return err
+	}
+	fmt.Fprintln(cfg.out(), "Nothing to extract: repository has no commits")
+	return nil
+}
+
+// Extract runs the extraction described by cfg without any terminal output
+// and returns a structured report. When some commits fail, both the report
+// and an error describing the failures are returned. A repository without
+// commits yields ErrNoCommits.
+func Extract(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Stdout || cfg.List {
+		return nil, fmt.Errorf("stdout streaming and listing are not supported by Extract")
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if err := git.CheckTools(); err != nil {
+		return nil, err
+	}
+	cfg.silent = true
+	return extract(ctx, cfg)
+}
+
+// validate checks the configuration for conflicting options
+func (cfg Config) validate() error {
+	if err := progress.ValidateMode(cfg.Progress); err != nil {
+		return err
+	}
+	if cfg.CommitTimeout < 0 {
+		return fmt.Errorf("--commit-timeout must not be negative")
+	}
+	if cfg.GitTimeout < 0 {
+		return fmt.Errorf("--git-timeout must not be negative")
+	}
+	if cfg.MaxFileSize < 0 {
+		return fmt.Errorf("--max-file-size must not be negative")
+	}
+	if cfg.WarnBinaryBytes < 0 {
+		return fmt.Errorf("--warn-binary-bytes must not be negative")
+	}
+	if cfg.Bundle != "" && !slices.Contains(bundleFormats, cfg.Bundle) {
+		return fmt.Errorf("unknown --bundle format %q (expected %s)", cfg.Bundle, strings.Join(bundleFormats, ", "))
+	}
+	if cfg.BundleRemove && cfg.Bundle == "" {
+		return fmt.Errorf("--bundle-remove requires --bundle")
+	}
+	if cfg.Quiet && cfg.Verbose {
+		return fmt.Errorf("--quiet and --verbose cannot be used together")
+	}
+	if cfg.Flatten && (cfg.Sizes || cfg.Checksums || cfg.DiffStat || cfg.Raw || cfg.FlattenSymlinks || cfg.LFS || cfg.Submodules || cfg.KeepEmptyDirs || cfg.Baseline != "") {
+		return fmt.Errorf("--flatten cannot be combined with --sizes, --checksums, --diff-stat, --raw, --flatten-symlinks, --lfs, --submodules, --keep-empty-dirs or --baseline")
+	}
+	if cfg.Exec != "" && cfg.Flatten {
+		// Commits are written straight into archives, there is no folder to run in
+		return fmt.Errorf("--exec cannot be used with --flatten")
+	}
+	if cfg.OutTemplate != "" && cfg.OutputDir != "" {
+		return fmt.Errorf("--out-template and --output cannot be used together")
+	}
+	if cfg.Verify != "" && (cfg.List || cfg.Stdout || cfg.Watch) {
+		return fmt.Errorf("--verify cannot be used with --list, --stdout or --watch")
+	}
+	if cfg.CAS && (cfg.Flatten || cfg.Checksums) {
+		return fmt.Errorf("--cas cannot be combined with --flatten or --checksums")
+	}
+	if cfg.Reproducible && cfg.NoPreservePerms {
+		return fmt.Errorf("--reproducible cannot be used with --no-preserve-perms")
+	}
+	if cfg.NoMetadata && cfg.MetadataTemplate != "" {
+		return fmt.Errorf("--no-metadata and --metadata-template cannot be used together")
+	}
+	if cfg.IgnoreRevsFile != "" && !cfg.Churn && !cfg.Authors {
+		return fmt.Errorf("--ignore-revs-file requires --churn or --authors")
+	}
+	if cfg.NoMetadata && (cfg.Metrics || cfg.AllowedKeys != "" || cfg.Churn || cfg.Authors || cfg.IncludeDiff) {
+		// These are computed along with the metadata
+		return fmt.Errorf("--no-metadata cannot be combined with --metrics, --allowed-keys, --churn, --authors or --include-diff-in-metadata")
+	}
+	if cfg.MaxDiffLines < -1 {
+		return fmt.Errorf("--max-diff-lines must be -1 (no limit) or more")
+	}
+	if cfg.FailOnAny && cfg.AllowPartial {
+		return fmt.Errorf("--fail-on-any and --allow-partial cannot be used together")
+	}
+	if cfg.FailOnUntrusted && cfg.AllowedKeys == "" {
+		return fmt.Errorf("--fail-on-untrusted requires --allowed-keys")
+	}
+	if cfg.GrepAllMatch && len(cfg.Grep) == 0 {
+		return fmt.Errorf("--all-match requires --grep")
+	}
+	if cfg.KeepEmptyDirs && cfg.MaxFileSize == 0 {
+		return fmt.Errorf("--keep-empty-dirs requires --max-file-size")
+	}
+	for _, pattern := range append(splitPatterns(cfg.BranchPattern), splitPatterns(cfg.ExcludeBranches)...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+		}
+	}
+	if cfg.Review && (cfg.Commit != "" || cfg.Reflog || cfg.Interactive || cfg.NewestFirst || cfg.Patches || cfg.Flatten || cfg.CAS || cfg.Exec != "") {
+		// The series covers one history, numbered oldest first, and holds no trees
+		return fmt.Errorf("--review cannot be used with --commit, --reflog, --interactive, --newest-first, --patches, --flatten, --cas or --exec")
+	}
+	if cfg.Patches && cfg.NewestFirst {
+		// Patches are numbered by index and must stay in git am order
+		return fmt.Errorf("--patches cannot be used with --newest-first")
+	}
+	if cfg.Refs != "" && (cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog || cfg.BranchPattern != "" || cfg.ExcludeBranches != "" || cfg.Interactive) {
+		return fmt.Errorf("--refs cannot be used with --branch, --commit, --reflog, branch patterns or --interactive")
+	}
+	if cfg.Interactive && (cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog) {
+		return fmt.Errorf("--interactive selects among all branches and cannot be used with --branch, --commit or --reflog")
+	}
+	if cfg.Pickaxe != "" && cfg.PickaxeRegex != "" {
+		return fmt.Errorf("--pickaxe and --pickaxe-regex cannot be used together")
+	}
+	if (cfg.Pickaxe != "" || cfg.PickaxeRegex != "") && (cfg.Commit != "" || cfg.Reflog) {
+		return fmt.Errorf("--pickaxe and --pickaxe-regex cannot be used with --commit or --reflog")
+	}
+	if cfg.Reflog && cfg.Commit != "" {
+		return fmt.Errorf("--reflog and --commit cannot be used together")
+	}
+	if cfg.Watch && (cfg.Commit != "" || cfg.Reflog || cfg.Tags || cfg.Review || cfg.Refs != "" || cfg.Interactive || cfg.BranchPattern != "" || cfg.ExcludeBranches != "" || cfg.NewestFirst || cfg.Bundle != "") {
+		// New commits are appended to the numbered sequence of a single history
+		return fmt.Errorf("--watch follows a single branch and cannot be used with --commit, --reflog, --tags, --review, --refs, --interactive, branch patterns, --newest-first or --bundle")
+	}
+	if cfg.WatchInterval < 0 {
+		return fmt.Errorf("--watch-interval must not be negative")
+	}
+	if (cfg.TagPattern != "" || cfg.SemverSort) && !cfg.Tags {
+		return fmt.Errorf("--tag-pattern and --semver-sort require --tags")
+	}
+	if cfg.Tags && (cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog || cfg.Refs != "" || cfg.Review || cfg.Interactive || cfg.BranchPattern != "" || cfg.ExcludeBranches != "") {
+		return fmt.Errorf("--tags cannot be used with --branch, --commit, --reflog, --refs, --review, --interactive or branch patterns")
+	}
+	if cfg.SortByDate && !cfg.listsCommits() {
+		return fmt.Errorf("--sort-by-date requires --commits-file or --from-stdin")
+	}
+	if cfg.CommitsFile != "" && cfg.FromStdin {
+		return fmt.Errorf("--commits-file and --from-stdin cannot be used together")
+	}
+	if cfg.listsCommits() && (cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog || cfg.Tags || cfg.Refs != "" || cfg.Review || cfg.Interactive || cfg.Watch || cfg.BranchPattern != "" || cfg.ExcludeBranches != "") {
+		return fmt.Errorf("--commits-file and --from-stdin cannot be used with --branch, --commit, --reflog, --tags, --refs, --review, --interactive, --watch or branch patterns")
+	}
+	if cfg.MergeBase != "" {
+		if len(splitPatterns(cfg.MergeBase)) != 2 {
+			return fmt.Errorf("--merge-base takes two comma-separated branches, got %q", cfg.MergeBase)
+		}
+		if cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog || cfg.Tags || cfg.Refs != "" || cfg.Review || cfg.listsCommits() || cfg.Interactive || cfg.Watch || cfg.BranchPattern != "" || cfg.ExcludeBranches != "" {
+			return fmt.Errorf("--merge-base cannot be used with --branch, --commit, --reflog, --tags, --refs, --review, --commits-file, --from-stdin, --interactive, --watch or branch patterns")
+		}
+	}
+	if cfg.MaxGitProcs < 0 || cfg.JobDelay < 0 {
+		return fmt.Errorf("--max-git-procs and --job-delay cannot be negative")
+	}
+	switch cfg.BranchOrder {
+	case "", BranchOrderAlpha:
+	case BranchOrderDate, BranchOrderTopo:
+		if cfg.Branch != "" || cfg.Commit != "" || cfg.Reflog || cfg.Tags || cfg.Refs != "" || cfg.Review || cfg.listsCommits() || cfg.MergeBase != "" {
+			return fmt.Errorf("--branch-order applies to all-branches mode and cannot be used with --branch, --commit, --reflog, --tags, --refs, --review, --commits-file, --from-stdin or --merge-base")
+		}
+	default:
+		return fmt.Errorf("invalid --branch-order %q: use %s, %s or %s", cfg.BranchOrder, BranchOrderAlpha, BranchOrderDate, BranchOrderTopo)
+	}
+	if cfg.DateSource != "" && cfg.DateSource != git.DateAuthor && cfg.DateSource != git.DateCommit {
+		return fmt.Errorf("invalid --date-source %q: use %s or %s", cfg.DateSource, git.DateCommit, git.DateAuthor)
+	}
+	if cfg.Graph && (cfg.List || cfg.Stdout || cfg.Progress == progress.ModeJSON) {
+		// All of these already write to stdout
+		return fmt.Errorf("--graph cannot be used with --list, --stdout or --progress=json")
+	}
+	if cfg.List && cfg.Stdout {
+		return fmt.Errorf("--list and --stdout cannot be used together")
+	}
+	if cfg.Stdout && cfg.Commit == "" {
+		return fmt.Errorf("--stdout requires exactly one commit selected with --commit")
+	}
+	if cfg.Inventory && (cfg.Stdout || cfg.List || cfg.Watch || cfg.Review || cfg.NoMetadata || cfg.Flatten || cfg.Sizes || cfg.Checksums || cfg.DiffStat || cfg.Raw || cfg.Patches || cfg.Exec != "" || cfg.CAS || cfg.LFS || cfg.Submodules || cfg.KeepEmptyDirs || cfg.DeltaOnly || cfg.Baseline != "" || cfg.XattrMetadata || cfg.IncludeWorktree || cfg.HTML || cfg.Compare || cfg.Reproducible || len(cfg.Include) > 0 || len(cfg.Exclude) > 0 || cfg.MaxFileSize > 0 || cfg.FilterFile != "" || cfg.FolderTemplate != "" || cfg.FlattenSymlinks || cfg.NoPreservePerms) {
+		// Each of these writes to or reads from the commit folders
+		return fmt.Errorf("--inventory writes no commit folders and cannot be combined with --stdout, --list, --watch, --review, --no-metadata, --flatten, --sizes, --checksums, --diff-stat, --raw, --patches, --exec, --cas, --lfs, --submodules, --keep-empty-dirs, --delta-only, --baseline, --xattr-metadata, --include-worktree, --html, --compare, --reproducible, --include, --exclude, --max-file-size, --filter-file, --folder-template, --flatten-symlinks or --no-preserve-perms")
+	}
+	if cfg.SetFileTimes && (cfg.Reproducible || cfg.Flatten || cfg.CAS || cfg.Inventory || cfg.Stdout) {
+		return fmt.Errorf("--set-file-times cannot be combined with --reproducible, --flatten, --cas, --inventory or --stdout")
+	}
+	if cfg.FileTimesMaxFiles < 0 {
+		return fmt.Errorf("--file-times-max-files must not be negative")
+	}
+	if cfg.DeltaOnly && cfg.Stdout {
+		// The tar stream always holds the whole tree
+		return fmt.Errorf("--delta-only cannot be used with --stdout")
+	}
+	return nil
+}
+
+// openRepository opens the configured repository and applies extraction options
+func (cfg Config) openRepository() (*git.Repository, error) {
+	repo, err := git.Open(cfg.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	repo.PreserveSymlinks = !cfg.FlattenSymlinks
+	repo.PreservePerms = !cfg.NoPreservePerms
+	repo.RespectExportIgnore = !cfg.NoExportIgnore
+	if cfg.NoExternalTar {
+		repo.ExternalTar = false
+	}
+	repo.Logger = cfg.Logger
+	repo.Timeout = cfg.GitTimeout
+	return repo, nil
+}
+
+// load reads the files named by the configuration and fills in the
+// settings derived from it, before extraction starts
+func (cfg *Config) load() error {
+	var err error
+	if cfg.AllowedKeys != "" {
+		if cfg.allowlist, err = git.LoadKeyAllowlist(cfg.AllowedKeys); err != nil {
+			return err
+		}
+	}
+
+	if cfg.MetadataTemplate != "" {
+		if cfg.metadataTmpl, err = git.LoadMetadataTemplate(cfg.MetadataTemplate); err != nil {
+			return err
+		}
+	}
+
+	if cfg.IgnoreRevsFile != "" {
+		if cfg.ignoreRevs, err = git.LoadIgnoreRevsFile(cfg.IgnoreRevsFile); err != nil {
+			return err
+		}
+	}
+
+	if cfg.FilterFile != "" {
+		if cfg.filterGlobs, err = git.LoadFilterFile(cfg.FilterFile); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Nice {
+		if cfg.MaxGitProcs == 0 {
+			cfg.MaxGitProcs = config.NiceGitProcs
+		}
+		if cfg.JobDelay == 0 {
+			cfg.JobDelay = config.NiceJobDelay
+		}
+	}
+	if cfg.MaxGitProcs > 0 {
+		cfg.gitProcs = semaphore.NewWeighted(int64(cfg.MaxGitProcs))
+	}
+	return nil
+}
+
+// resolveBaseline looks up the commit named by Baseline, if any
+func (cfg *Config) resolveBaseline(ctx context.Context, repo *git.Repository) error {
+	if cfg.Baseline == "" {
+		return nil
+	}
+	commit, err := repo.ResolveCommit(ctx, cfg.Baseline)
+	if err != nil {
+		return fmt.Errorf("invalid --baseline: %w", err)
+	}
+	cfg.baselineHash = commit.Hash
+	return nil
+}
+
+// extract performs the extraction and builds the run report. A nil report
+// means nothing was extracted because setup failed.
+func extract(ctx context.Context, cfg Config) (*Report, error) {
+	repo, err := cfg.openRepository()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = repo.Close() }()
+
+	if cfg.IncludeWorktree && repo.IsBare(ctx) {
+		return nil, fmt.Errorf("--include-worktree cannot be used with a bare repository")
+	}
+	if err := requireCommits(ctx, repo); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.load(); err != nil {
+		return nil, err
+	}
+
+	if err := verifyRefs(ctx, repo, splitPatterns(cfg.Refs)); err != nil {
+		return nil, err
+	}
+	if err := cfg.resolveBaseline(ctx, repo); err != nil {
+		return nil, err
+	}
+
+	// Determine output directory
+	outDir := cfg.OutputDir
+	switch {
+	case cfg.OutTemplate != "":
+		if outDir, err = renderOutputDir(ctx, repo, cfg); err != nil {
+			return nil, err
+		}
+	case outDir == "":
+		baseName := filepath.Base(repo.Path)
+		if repo.IsBare(ctx) {
+			// Bare repositories are conventionally named foo.git
+			baseName = strings.TrimSuffix(baseName, ".git")
+		}
+		outDir = baseName + config.OutputSuffix
+	}
+
+	// Resolve to absolute path
+	outDir, err = filepath.Abs(outDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve output path: %w", err)
+	}
+
+	// Check if output directory already exists
+	if info, err := os.Stat(outDir); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("output directory already exists: %s", outDir)
+	}
+	if cfg.Bundle != "" {
+		if _, err := os.Stat(outDir + "." + cfg.Bundle); err == nil {
+			return nil, fmt.Errorf("bundle already exists: %s.%s", outDir, cfg.Bundle)
+		}
+	}
+
+	// Print header
+	printHeader(repo, outDir, cfg)
+
+	logger := cfg.logger()
+	logger.Info("run started", "repository", repo.Path, "output", outDir)
+	start := time.Now()
+
+	var results []extractor.Result
+	switch {
+	case cfg.Commit != "":
+		// A single commit bypasses branch listing entirely
+		results, err = runSingleCommit(ctx, repo, outDir, cfg)
+	case cfg.Reflog:
+		results, err = runReflog(ctx, repo, outDir, cfg)
+	case cfg.Tags:
+		results, err = runTags(ctx, repo, outDir, cfg)
+	case cfg.listsCommits():
+		results, err = runCommitsFile(ctx, repo, outDir, cfg)
+	case cfg.MergeBase != "":
+		results, err = runMergeBase(ctx, repo, outDir, cfg)
+	case cfg.Review:
+		results, err = runReview(ctx, repo, outDir, cfg)
+	case cfg.Branch != "" || cfg.Refs != "":
+		results, err = runSingleBranch(ctx, repo, outDir, cfg)
+	default:
+		results, err = runAllBranches(ctx, repo, outDir, cfg)
+	}
+	if results == nil && err != nil {
+		return nil, err
+	}
+
+	var worktreeFiles int
+	if cfg.IncludeWorktree && ctx.Err() == nil {
+		var wtErr error
+		worktreeFiles, wtErr = repo.SnapshotWorktree(ctx, filepath.Join(outDir, config.WorktreeDirName), outDir)
+		if wtErr != nil && err == nil {
+			err = fmt.Errorf("failed to snapshot working tree: %w", wtErr)
+		}
+	}
+
+	if reportErr := writeReports(results, outDir, cfg); reportErr != nil && err == nil {
+		err = reportErr
+	}
+
+	if cfg.Compare && ctx.Err() == nil {
+		if diffErr := writeCompareDiffs(ctx, repo, results, outDir, cfg); diffErr != nil && err == nil {
+			err = fmt.Errorf("failed to write comparison diffs: %w", diffErr)
+		}
+	}
+
+	var duplicateTrees int
+	if cfg.DuplicateTrees && ctx.Err() == nil {
+		var dupErr error
+		if duplicateTrees, dupErr = writeDuplicateTrees(ctx, repo, results, outDir); dupErr != nil && err == nil {
+			err = fmt.Errorf("failed to write duplicate trees report: %w", dupErr)
+		}
+	}
+
+	rep := newReport(repo.Path, outDir, results)
+	rep.WorktreeFiles = worktreeFiles
+	rep.DuplicateTrees = duplicateTrees
+	rep.statsResults = cfg.statsResults(results)
+	if summaryErr := writeRunSummary(ctx, repo, rep, start, cfg); summaryErr != nil && err == nil {
+		err = summaryErr
+	}
+	logger.Info("run finished", "succeeded", rep.Succeeded, "failed", rep.Failed,
+		"empty", rep.Empty, "duration", time.Since(start))
+	if err == nil && cfg.FailOnUntrusted && rep.Untrusted > 0 {
+		err = fmt.Errorf("%d commits not signed by an allowed key", rep.Untrusted)
+	}
+
+	// The bundle goes last so that it holds every report
+	if cfg.Bundle != "" && ctx.Err() == nil {
+		if _, statErr := os.Stat(outDir); statErr == nil {
+			var bundleErr error
+			if rep.Bundle, bundleErr = writeBundle(outDir, cfg.Bundle, cfg.BundleRemove); bundleErr != nil && err == nil {
+				err = bundleErr
+			}
+		}
+	}
+	rep.Elapsed = time.Since(start)
+	return rep, err
+}
+
+// runAllBranches extracts commits from all branches into separate subdirectories.
+// Up to cfg.BranchWorkers branches are processed concurrently, sharing the
+// cfg.Workers budget between their commit worker pools.
+func runAllBranches(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+
+	branches, err := selectBranches(ctx, repo, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Display warning about time and memory
+	fmt.Fprintf(cfg.out(), "%s Extracting from %d branches - this may take some time and memory!\n\n", yellow("⚠"), len(branches))
+
+	branchWorkers := branchConcurrency(cfg.BranchWorkers, len(branches))
+	commitWorkers := max(1, cfg.Workers/branchWorkers)
+
+	// Serialize branch headers so concurrent branches don't interleave output
+	var outputMu sync.Mutex
+
+	// Count commits for every branch; they are streamed during extraction
+	branchCounts := make([]int, len(branches))
+	forEachBranch(ctx, len(branches), branchWorkers, func(i int) {
+		count, err := repo.CountCommits(ctx, cfg.listOptions(branches[i]))
+
+		header := fmt.Sprintf("Branch [%d/%d]: %s\n", i+1, len(branches), branches[i])
+		switch {
+		case err != nil:
+			header += fmt.Sprintf("  ⚠ Failed to list commits: %v\n", err)
+		case count == 0:
+			header += "  (no commits)\n"
+		default:
+			header += fmt.Sprintf("  Found %d commits\n", count)
+		}
+
+		outputMu.Lock()
+		fmt.Fprint(cfg.out(), header)
+		outputMu.Unlock()
+
+		branchCounts[i] = count
+	})
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var total int
+	for _, count := range branchCounts {
+		total += count
+	}
+	if total == 0 {
+		return []extractor.Result{}, nil
+	}
+	fmt.Fprintln(cfg.out(), "")
+
+	// Share a single progress bar across all branches, sized to the grand total
+	reporter := cfg.newReporter(total)
+	reporter.Start()
+
+	branchResults := make([][]extractor.Result, len(branches))
+	branchErrs := make([]error, len(branches))
+	forEachBranch(ctx, len(branches), branchWorkers, func(i int) {
+		if branchCounts[i] == 0 {
+			return
+		}
+
+		// Create extractor for the branch-specific output directory and run
+		extCfg := cfg.extractorConfig(cfg.branchOutputDir(outDir, branches[i]), branches[i])
+		extCfg.Workers = commitWorkers
+		extCfg.ExecJobs = max(1, cfg.ExecJobs/branchWorkers)
+		extCfg.Reporter = reporter
+		if cfg.CAS {
+			// Branches share one store so that they deduplicate too
+			extCfg.CASDir = filepath.Join(outDir, config.CASDirName)
+		}
+		ext, err := extractor.New(repo, extCfg)
+		if err != nil {
+			branchErrs[i] = err
+			return
+		}
+
+		branchResults[i], branchErrs[i] = streamCommits(ctx, repo, ext, cfg.listOptions(branches[i]), branchCounts[i])
+	})
+
+	reporter.Finish()
+
+	// Aggregate in branch order so reports are deterministic
+	allResults := make([]extractor.Result, 0, total)
+	var extractionErr error
+	for i := range branches {
+		allResults = append(allResults, branchResults[i]...)
+		if branchErrs[i] != nil && extractionErr == nil {
+			extractionErr = branchErrs[i]
+		}
+	}
+
+	return allResults, extractionErr
+}
+
+// branchConcurrency returns the number of branches to process concurrently
+func branchConcurrency(requested, branches int) int {
+	if requested <= 0 {
+		requested = config.DefaultConcurrentBranches
+	}
+	return max(1, min(requested, branches, config.MaxConcurrentBranches))
+}
+
+// forEachBranch calls fn for each branch index using up to workers goroutines.
+// No new branches are started once ctx is cancelled.
+func forEachBranch(ctx context.Context, n, workers int, fn func(i int)) {
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+send:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break send
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// runStdout writes the tar archive of the selected commit to w.
+// Commit details go to stderr so the stream stays clean for piping.
+func runStdout(ctx context.Context, repo *git.Repository, cfg Config, w io.Writer) error {
+	commit, err := repo.ResolveCommit(ctx, cfg.Commit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cfg.out(), "Streaming %s as tar to stdout\n", commit)
+
+	if err := repo.ArchiveToWriter(ctx, commit.Hash, w); err != nil {
+		return fmt.Errorf("failed to stream commit: %w", err)
+	}
+	return nil
 }
 
-// Run executes the repopsy application logic
-func Run(ctx context.Context, cfg Config) error {
-	// Open repository
-	repo, err := git.Open(cfg.RepoPath)
+// selectBranches lists the branches to extract in all-branches mode,
+// applying the branch patterns
+func selectBranches(ctx context.Context, repo *git.Repository, cfg Config) ([]string, error) {
+	branches, err := repo.ListBranches(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to open repository: %w", err)
+		return nil, fmt.Errorf("failed to list branches: %w", err)
 	}
 
-	// Determine output directory
-	outDir := cfg.OutputDir
-	if outDir == "" {
-		baseName := filepath.Base(repo.Path)
-		outDir = baseName + "-exploded"
+	// A detached HEAD on no branch, as left by CI checkouts of a commit, is
+	// extracted as the HEAD branch rather than missed
+	head, err := repo.DetachedHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if head != "" {
+		onBranch, err := repo.OnBranch(ctx, head)
+		if err != nil {
+			return nil, err
+		}
+		if !onBranch {
+			fmt.Fprintf(cfg.out(), "HEAD is detached at %.7s, on no branch: extracting it as HEAD\n", head)
+			branches = append(branches, "HEAD")
+		}
 	}
 
-	// Resolve to absolute path
-	outDir, err = filepath.Abs(outDir)
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("no branches found")
+	}
+
+	if cfg.BranchPattern != "" || cfg.ExcludeBranches != "" {
+		found := len(branches)
+		branches = filterBranches(branches, splitPatterns(cfg.BranchPattern), splitPatterns(cfg.ExcludeBranches))
+		if len(branches) == 0 {
+			return nil, fmt.Errorf("none of the %d branches match the branch patterns (--branch-pattern %q, --exclude-branch-pattern %q)",
+				found, cfg.BranchPattern, cfg.ExcludeBranches)
+		}
+	}
+	if branches, err = orderBranches(ctx, repo, cfg, branches); err != nil {
+		return nil, err
+	}
+	if cfg.Interactive {
+		if branches, err = promptBranches(ctx, repo, cfg, branches, stdin, stderr); err != nil {
+			return nil, err
+		}
+	}
+	cfg.logger().Debug("branches selected", "branches", branches)
+	return branches, nil
+}
+
+// runList writes a table of the commits that would be extracted to w
+// without creating any files
+func runList(ctx context.Context, repo *git.Repository, cfg Config, w io.Writer) error {
+	var results []extractor.Result
+	appendCommits := func(branch string, commits []git.Commit) {
+		for i, c := range commits {
+			results = append(results, extractor.Result{Commit: c, Branch: branch, Index: i})
+		}
+	}
+
+	switch {
+	case cfg.Commit != "":
+		commit, err := repo.ResolveCommit(ctx, cfg.Commit)
+		if err != nil {
+			return err
+		}
+		appendCommits(cfg.Branch, []git.Commit{commit})
+	case cfg.Reflog:
+		commits, err := listReflog(ctx, repo, cfg)
+		if err != nil {
+			return err
+		}
+		appendCommits(cfg.Branch, commits)
+	case cfg.Tags:
+		commits, err := listTags(ctx, repo, cfg)
+		if err != nil {
+			return err
+		}
+		appendCommits("", commits)
+	case cfg.listsCommits():
+		commits, _, err := listCommitsFile(ctx, repo, cfg)
+		if err != nil {
+			return err
+		}
+		appendCommits("", commits)
+	case cfg.MergeBase != "":
+		commits, err := listMergeBase(ctx, repo, cfg)
+		if err != nil {
+			return err
+		}
+		appendCommits("", commits)
+	default:
+		if err := verifyRefs(ctx, repo, splitPatterns(cfg.Refs)); err != nil {
+			return err
+		}
+		branches := []string{cfg.Branch}
+		if cfg.Branch == "" && cfg.Refs == "" {
+			var err error
+			if branches, err = selectBranches(ctx, repo, cfg); err != nil {
+				return err
+			}
+		}
+		for _, branch := range branches {
+			commits, err := repo.ListCommits(ctx, cfg.listOptions(branch))
+			if err != nil {
+				return fmt.Errorf("failed to list commits for branch %s: %w", branch, err)
+			}
+			appendCommits(branch, commits)
+		}
+	}
+
+	return report.WriteTable(w, results)
+}
+
+// verifyRefs checks that every ref names a commit
+func verifyRefs(ctx context.Context, repo *git.Repository, refs []string) error {
+	for _, ref := range refs {
+		if _, err := repo.ResolveCommit(ctx, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSingleCommit extracts the single commit selected by cfg.Commit
+func runSingleCommit(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commit, err := repo.ResolveCommit(ctx, cfg.Commit)
 	if err != nil {
-		return fmt.Errorf("failed to resolve output path: %w", err)
+		return nil, err
 	}
 
-	// Check if output directory already exists
-	if info, err := os.Stat(outDir); err == nil && info.IsDir() {
-		return fmt.Errorf("output directory already exists: %s", outDir)
+	fmt.Fprintf(cfg.out(), "Extracting commit %s\n\n", commit)
+
+	return extractCommits(ctx, repo, outDir, cfg, []git.Commit{commit})
+}
+
+// listReflog returns the distinct commits of the reflog of cfg.Branch (HEAD
+// when empty), limited to the most recent cfg.Limit and ordered like a branch
+func listReflog(ctx context.Context, repo *git.Repository, cfg Config) ([]git.Commit, error) {
+	commits, err := repo.ListReflog(ctx, cfg.Branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reflog: %w", err)
 	}
+	if cfg.Limit > 0 && len(commits) > cfg.Limit {
+		commits = commits[:cfg.Limit]
+	}
+	// The reflog lists the most recent entry first
+	if !cfg.NewestFirst {
+		slices.Reverse(commits)
+	}
+	return commits, nil
+}
 
-	// Print header
-	printHeader(repo, outDir, cfg)
+// runReflog extracts every distinct commit recorded in the reflog
+func runReflog(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commits, err := listReflog(ctx, repo, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// If branch specified, extract single branch; otherwise extract all branches
-	if cfg.Branch != "" {
-		return runSingleBranch(ctx, repo, outDir, cfg)
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no reflog entries found")
 	}
-	return runAllBranches(ctx, repo, outDir, cfg)
+
+	fmt.Fprintf(cfg.out(), "Found %d distinct commits in the reflog to extract\n\n", len(commits))
+
+	return extractCommits(ctx, repo, outDir, cfg, commits)
 }
 
-// runAllBranches extracts commits from all branches into separate subdirectories
-func runAllBranches(ctx context.Context, repo *git.Repository, outDir string, cfg Config) error {
-	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+// listTags returns the commits of the tags matching cfg.TagPattern, in tag
+// name or, with cfg.SemverSort, version order, limited to the last cfg.Limit
+func listTags(ctx context.Context, repo *git.Repository, cfg Config) ([]git.Commit, error) {
+	commits, err := repo.ListTags(ctx, cfg.TagPattern)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SemverSort {
+		commits = git.SortSemver(commits)
+	}
+	if cfg.Limit > 0 && len(commits) > cfg.Limit {
+		commits = commits[len(commits)-cfg.Limit:]
+	}
+	if cfg.NewestFirst {
+		slices.Reverse(commits)
+	}
+	return commits, nil
+}
 
-	// List all branches
-	branches, err := repo.ListBranches(ctx)
+// runTags extracts the commit of every selected tag
+func runTags(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commits, err := listTags(ctx, repo, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to list branches: %w", err)
+		return nil, err
 	}
 
-	if len(branches) == 0 {
-		return fmt.Errorf("no branches found")
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no tags found")
 	}
 
-	// Display warning about time and memory
-	fmt.Fprintf(os.Stderr, "%s Extracting from %d branches - this may take some time and memory!\n\n", yellow("⚠"), len(branches))
+	fmt.Fprintf(cfg.out(), "Found %d tags to extract\n\n", len(commits))
 
-	var allResults []extractor.Result
-	var extractionErr error
+	return extractCommits(ctx, repo, outDir, cfg, commits)
+}
+
+// listCommitsFile resolves the commits listed in cfg.CommitsFile, or piped
+// to stdin with cfg.FromStdin, in the listed order or by date with
+// cfg.SortByDate, and returns the lines naming no commit apart. A commit
+// listed twice is extracted once.
+func listCommitsFile(ctx context.Context, repo *git.Repository, cfg Config) ([]git.Commit, []string, error) {
+	listed, unresolved, err := readListedCommits(ctx, repo, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for i, branch := range branches {
-		if ctx.Err() != nil {
-			return ctx.Err()
+	var commits []git.Commit
+	seen := make(map[string]bool)
+	for _, commit := range listed {
+		if !seen[commit.Hash] {
+			seen[commit.Hash] = true
+			commits = append(commits, commit)
 		}
+	}
 
-		// Create branch-specific output directory
-		branchDir := filepath.Join(outDir, sanitizeBranchName(branch))
+	if cfg.SortByDate {
+		slices.SortStableFunc(commits, func(a, b git.Commit) int { return cfg.date(a).Compare(cfg.date(b)) })
+	}
+	return commits, unresolved, nil
+}
 
-		fmt.Fprintf(os.Stderr, "Branch [%d/%d]: %s\n", i+1, len(branches), branch)
+// readListedCommits returns the commits piped to stdin with cfg.FromStdin,
+// or else listed in cfg.CommitsFile, along with the lines naming no commit
+func readListedCommits(ctx context.Context, repo *git.Repository, cfg Config) ([]git.Commit, []string, error) {
+	if cfg.FromStdin {
+		return repo.ReadPipedCommits(ctx, stdin)
+	}
 
-		// List commits for this branch
-		commits, err := repo.ListCommits(ctx, git.ListOptions{
-			Branch:  branch,
-			Limit:   cfg.Limit,
-			Reverse: true,
-		})
+	refs, err := git.LoadCommitsFile(cfg.CommitsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var commits []git.Commit
+	var unresolved []string
+	for _, ref := range refs {
+		commit, err := repo.ResolveCommit(ctx, ref)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "  ⚠ Failed to list commits: %v\n", err)
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			unresolved = append(unresolved, ref)
 			continue
 		}
+		commits = append(commits, commit)
+	}
+	return commits, unresolved, nil
+}
 
-		if len(commits) == 0 {
-			fmt.Fprintf(os.Stderr, "  (no commits)\n")
-			continue
-		}
+// commitsSource names where the listed commits come from in messages
+func (cfg Config) commitsSource() string {
+	if cfg.FromStdin {
+		return "stdin"
+	}
+	return cfg.CommitsFile
+}
 
-		fmt.Fprintf(os.Stderr, "  Found %d commits\n", len(commits))
+// listsCommits reports whether the commits to extract are listed by the
+// user, in cfg.CommitsFile or on stdin
+func (cfg Config) listsCommits() bool {
+	return cfg.CommitsFile != "" || cfg.FromStdin
+}
 
-		// Create extractor and run
-		ext := extractor.New(repo, extractor.Config{
-			OutputDir: branchDir,
-			Workers:   cfg.Workers,
-			Verbose:   cfg.Verbose,
-		})
+// runCommitsFile extracts the commits listed in cfg.CommitsFile or piped to
+// stdin, reporting the lines that name no commit
+func runCommitsFile(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commits, unresolved, err := listCommitsFile(ctx, repo, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		results, err := ext.Run(ctx, commits)
-		allResults = append(allResults, results...)
-		if err != nil && extractionErr == nil {
-			extractionErr = err
-		}
+	if len(unresolved) > 0 {
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		cfg.logger().Warn("unresolved commits", "refs", unresolved)
+		fmt.Fprintf(cfg.out(), "%s %d commits in %s could not be resolved: %s\n",
+			yellow("⚠"), len(unresolved), cfg.commitsSource(), strings.Join(unresolved, ", "))
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits found in %s", cfg.commitsSource())
 	}
 
-	// Print summary
-	printSummary(allResults, outDir, cfg)
+	fmt.Fprintf(cfg.out(), "Found %d commits to extract\n\n", len(commits))
 
-	return extractionErr
+	return extractCommits(ctx, repo, outDir, cfg, commits)
 }
 
 // runSingleBranch extracts commits from a single branch
-func runSingleBranch(ctx context.Context, repo *git.Repository, outDir string, cfg Config) error {
-	// List commits
-	commits, err := repo.ListCommits(ctx, git.ListOptions{
-		Branch:  cfg.Branch,
-		Limit:   cfg.Limit,
-		Reverse: true,
-	})
+func runSingleBranch(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	// Count commits; they are streamed from git log during extraction
+	opts := cfg.listOptions(cfg.Branch)
+	total, err := repo.CountCommits(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to list commits: %w", err)
+		return nil, fmt.Errorf("failed to list commits: %w", err)
 	}
 
-	if len(commits) == 0 {
-		return fmt.Errorf("no commits found")
+	if total == 0 {
+		return nil, fmt.Errorf("no commits found")
+	}
+
+	fmt.Fprintf(cfg.out(), "Found %d commits to extract\n\n", total)
+
+	reporter := cfg.newReporter(total)
+	extCfg := cfg.extractorConfig(outDir, cfg.Branch)
+	extCfg.Reporter = reporter
+	ext, err := extractor.New(repo, extCfg)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Fprintf(os.Stderr, "Found %d commits to extract\n\n", len(commits))
+	reporter.Start()
+	results, err := streamCommits(ctx, repo, ext, opts, total)
+	reporter.Finish()
+
+	return results, err
+}
+
+// streamCommits extracts the commits selected by opts with ext, streaming
+// them from git log so that long histories are never held in memory
+func streamCommits(ctx context.Context, repo *git.Repository, ext *extractor.Extractor, opts git.ListOptions, total int) ([]extractor.Result, error) {
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
+	stream, errc := repo.ListCommitsChan(listCtx, opts)
+	results, err := ext.RunStream(ctx, stream, total)
+
+	// Stop git log if the extraction was interrupted before the end
+	cancel()
+	if listErr := <-errc; listErr != nil && err == nil && ctx.Err() == nil {
+		err = fmt.Errorf("failed to list commits: %w", listErr)
+	}
+	return results, err
+}
+
+// extractCommits extracts commits of cfg.Branch into outDir
+func extractCommits(ctx context.Context, repo *git.Repository, outDir string, cfg Config, commits []git.Commit) ([]extractor.Result, error) {
 	// Create extractor and run
-	ext := extractor.New(repo, extractor.Config{
-		OutputDir: outDir,
-		Workers:   cfg.Workers,
-		Verbose:   cfg.Verbose,
-	})
+	reporter := cfg.newReporter(len(commits))
+	extCfg := cfg.extractorConfig(outDir, cfg.Branch)
+	extCfg.Reporter = reporter
+	ext, err := extractor.New(repo, extCfg)
+	if err != nil {
+		return nil, err
+	}
 
+	reporter.Start()
 	results, err := ext.Run(ctx, commits)
+	reporter.Finish()
 
-	// Print summary
-	printSummary(results, outDir, cfg)
+	return results, err
+}
 
-	return err
+// writeReports writes the optional run reports requested in the configuration
+func writeReports(results []extractor.Result, outDir string, cfg Config) error {
+	// The inventory creates the output directory, which no commit folder did
+	if cfg.Inventory {
+		if err := report.WriteInventoryFile(outDir, results); err != nil {
+			return fmt.Errorf("failed to write inventory: %w", err)
+		}
+	}
+	if cfg.HTML && len(results) > 0 {
+		if err := report.WriteHTML(outDir, results); err != nil {
+			return fmt.Errorf("failed to write HTML report: %w", err)
+		}
+	}
+	if cfg.CSVPath != "" {
+		if err := report.WriteCSVFile(cfg.CSVPath, results); err != nil {
+			return fmt.Errorf("failed to write CSV summary: %w", err)
+		}
+	}
+	stats := cfg.statsResults(results)
+	if cfg.Churn && len(results) > 0 {
+		if err := report.WriteChurnFile(outDir, report.Churn(stats)); err != nil {
+			return fmt.Errorf("failed to write churn series: %w", err)
+		}
+	}
+	if cfg.Authors && len(results) > 0 {
+		if err := report.WriteAuthorsFiles(outDir, report.Authors(stats)); err != nil {
+			return fmt.Errorf("failed to write author statistics: %w", err)
+		}
+	}
+	return nil
+}
+
+// statsResults returns the results counted in the churn and author
+// statistics: all of them but the commits listed in IgnoreRevsFile
+func (cfg Config) statsResults(results []extractor.Result) []extractor.Result {
+	if len(cfg.ignoreRevs) == 0 {
+		return results
+	}
+	return slices.DeleteFunc(slices.Clone(results), func(r extractor.Result) bool {
+		return slices.ContainsFunc(cfg.ignoreRevs, func(rev string) bool {
+			return strings.HasPrefix(r.Commit.Hash, rev)
+		})
+	})
+}
+
+// writeRunSummary writes summary.json into the output directory, unless
+// nothing was extracted there
+func writeRunSummary(ctx context.Context, repo *git.Repository, rep *Report, start time.Time, cfg Config) error {
+	if _, err := os.Stat(rep.OutputDir); err != nil {
+		return nil
+	}
+
+	summary := report.NewSummary(rep.Results)
+	summary.Repository = rep.RepoPath
+	summary.OutputDir = rep.OutputDir
+	summary.Workers = cfg.Workers
+	summary.StartedAt = start
+	// A missing remote is not worth failing the run over
+	summary.Remote, _ = repo.RemoteURL(ctx)
+
+	var err error
+	if summary.BytesWritten, err = report.DirSize(rep.OutputDir); err != nil {
+		return err
+	}
+	summary.ElapsedSeconds = time.Since(start).Seconds()
+	return report.WriteSummary(rep.OutputDir, summary)
+}
+
+// splitPatterns splits a comma-separated list of globs, dropping empty entries
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// orderBranches sorts branches as cfg.BranchOrder asks. Date order compares
+// the cfg.DateSource date of each tip; topological order puts branches with
+// fewer reachable commits first, so a branch comes after every branch whose
+// tip is one of its ancestors. Ties keep git's name order.
+func orderBranches(ctx context.Context, repo *git.Repository, cfg Config, branches []string) ([]string, error) {
+	var key func(branch string) (int64, error)
+	switch cfg.BranchOrder {
+	case BranchOrderDate:
+		key = func(branch string) (int64, error) {
+			tip, err := repo.ResolveCommit(ctx, branch)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read the tip of branch %s: %w", branch, err)
+			}
+			return cfg.date(tip).UnixNano(), nil
+		}
+	case BranchOrderTopo:
+		key = func(branch string) (int64, error) {
+			count, err := repo.CommitCount(ctx, branch)
+			if err != nil {
+				return 0, fmt.Errorf("failed to count commits of branch %s: %w", branch, err)
+			}
+			return int64(count), nil
+		}
+	default:
+		return branches, nil
+	}
+
+	keys := make(map[string]int64, len(branches))
+	for _, branch := range branches {
+		k, err := key(branch)
+		if err != nil {
+			return nil, err
+		}
+		keys[branch] = k
+	}
+	ordered := slices.Clone(branches)
+	slices.SortStableFunc(ordered, func(a, b string) int { return cmp.Compare(keys[a], keys[b]) })
+	return ordered, nil
+}
+
+// date returns the cfg.DateSource date of a commit
+func (cfg Config) date(c git.Commit) time.Time {
+	if cfg.DateSource == git.DateCommit {
+		return c.CommitDate
+	}
+	return c.AuthorDate
+}
+
+// filterBranches keeps the branches matching any include glob (all branches
+// when there are none) and no exclude glob. Globs use path.Match syntax, so
+// * does not cross a / in the branch name.
+func filterBranches(branches, include, exclude []string) []string {
+	matchAny := func(patterns []string, branch string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, branch); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var kept []string
+	for _, b := range branches {
+		if len(include) > 0 && !matchAny(include, b) {
+			continue
+		}
+		if matchAny(exclude, b) {
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return kept
 }
 
 // sanitizeBranchName converts a branch name to a safe directory name
@@ -166,57 +1530,288 @@ func sanitizeBranchName(branch string) string {
 
 // printHeader displays the startup banner with configuration
 func printHeader(repo *git.Repository, outDir string, cfg Config) {
+	if cfg.Quiet {
+		return
+	}
+
 	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
 	magenta := color.New(color.FgMagenta, color.Bold).SprintFunc()
 
-	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintln(os.Stderr, cyan("┌─────────────────────────────────────────┐"))
-	fmt.Fprintln(os.Stderr, cyan("│                 repopsy                 │"))
-	fmt.Fprintln(os.Stderr, cyan("│ Repository Autopsy tool by @andpalmier  │"))
-	fmt.Fprintln(os.Stderr, cyan("└─────────────────────────────────────────┘"))
-	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(cfg.out(), "")
+	fmt.Fprintln(cfg.out(), cyan("┌─────────────────────────────────────────┐"))
+	fmt.Fprintln(cfg.out(), cyan("│                 repopsy                 │"))
+	fmt.Fprintln(cfg.out(), cyan("│ Repository Autopsy tool by @andpalmier  │"))
+	fmt.Fprintln(cfg.out(), cyan("└─────────────────────────────────────────┘"))
+	fmt.Fprintln(cfg.out(), "")
 
-	fmt.Fprintf(os.Stderr, "Repository:  %s\n", magenta(repo.Path))
-	if cfg.Branch != "" {
-		fmt.Fprintf(os.Stderr, "Branch:      %s\n", cfg.Branch)
+	fmt.Fprintf(cfg.out(), "Repository:  %s\n", magenta(repo.Path))
+	if repo.IsLinkedWorktree() {
+		fmt.Fprintf(cfg.out(), "Worktree:    linked (objects in %s)\n", repo.CommonDir)
+	}
+	if cfg.Reflog {
+		ref := cfg.Branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		fmt.Fprintf(cfg.out(), "Reflog:      %s\n", ref)
+	} else if cfg.Tags {
+		pattern := cfg.TagPattern
+		if pattern == "" {
+			pattern = "*"
+		}
+		order := "name"
+		if cfg.SemverSort {
+			order = "semantic version"
+		}
+		fmt.Fprintf(cfg.out(), "Tags:        %s (by %s)\n", pattern, order)
+	} else if cfg.listsCommits() {
+		fmt.Fprintf(cfg.out(), "Commits:     %s\n", cfg.commitsSource())
+	} else if cfg.MergeBase != "" {
+		fmt.Fprintf(cfg.out(), "Merge base:  %s\n", strings.Join(splitPatterns(cfg.MergeBase), " and "))
+	} else if cfg.Branch != "" {
+		fmt.Fprintf(cfg.out(), "Branch:      %s\n", cfg.Branch)
+	} else if cfg.Refs != "" {
+		fmt.Fprintf(cfg.out(), "Refs:        %s\n", cfg.Refs)
+	} else if cfg.Review {
+		fmt.Fprintf(cfg.out(), "Branch:      HEAD\n")
+	} else if cfg.BranchPattern != "" || cfg.ExcludeBranches != "" {
+		fmt.Fprintf(cfg.out(), "Branches:    matching %q, excluding %q\n", cfg.BranchPattern, cfg.ExcludeBranches)
 	} else {
-		fmt.Fprintf(os.Stderr, "Branches:    all\n")
+		fmt.Fprintf(cfg.out(), "Branches:    all\n")
+	}
+	fmt.Fprintf(cfg.out(), "Output:      %s\n", outDir)
+	fmt.Fprintf(cfg.out(), "Workers:     %d\n", cfg.Workers)
+	if cfg.Branch == "" && cfg.Refs == "" && !cfg.Review && !cfg.Tags && !cfg.listsCommits() && cfg.MergeBase == "" {
+		fmt.Fprintf(cfg.out(), "Branch pool: %d\n", branchConcurrency(cfg.BranchWorkers, config.MaxConcurrentBranches))
+		if cfg.BranchOrder != "" {
+			fmt.Fprintf(cfg.out(), "Order:       %s\n", cfg.BranchOrder)
+		}
 	}
-	fmt.Fprintf(os.Stderr, "Output:      %s\n", outDir)
-	fmt.Fprintf(os.Stderr, "Workers:     %d\n", cfg.Workers)
 	if cfg.Limit > 0 {
-		fmt.Fprintf(os.Stderr, "Limit:       %d commits\n", cfg.Limit)
+		fmt.Fprintf(cfg.out(), "Limit:       %d commits\n", cfg.Limit)
+	}
+	if cfg.CommitTimeout > 0 {
+		fmt.Fprintf(cfg.out(), "Timeout:     %s per commit\n", cfg.CommitTimeout)
+	}
+	if cfg.FirstParent {
+		fmt.Fprintf(cfg.out(), "History:     first-parent only\n")
+	}
+	if cfg.DateSource != "" {
+		fmt.Fprintf(cfg.out(), "Dates:       %s date\n", cfg.DateSource)
+	}
+	if cfg.Exec != "" {
+		fmt.Fprintf(cfg.out(), "Exec:        %s\n", cfg.Exec)
+	}
+	if cfg.MaxGitProcs > 0 {
+		fmt.Fprintf(cfg.out(), "Git procs:   at most %d at once\n", cfg.MaxGitProcs)
 	}
-	fmt.Fprintln(os.Stderr, "")
+	if cfg.JobDelay > 0 {
+		fmt.Fprintf(cfg.out(), "Job delay:   %s between commits\n", cfg.JobDelay)
+	}
+	if len(cfg.Grep) > 0 {
+		fmt.Fprintf(cfg.out(), "Grep:        %s\n", strings.Join(cfg.Grep, ", "))
+	}
+	if cfg.Pickaxe != "" {
+		fmt.Fprintf(cfg.out(), "Pickaxe:     %s\n", cfg.Pickaxe)
+	}
+	if cfg.PickaxeRegex != "" {
+		fmt.Fprintf(cfg.out(), "Pickaxe:     /%s/\n", cfg.PickaxeRegex)
+	}
+	fmt.Fprintln(cfg.out(), "")
 }
 
 // printSummary displays the extraction results
-func printSummary(results []extractor.Result, outDir string, cfg Config) {
-	fmt.Fprintln(os.Stderr, "")
+func printSummary(rep *Report, cfg Config) {
+	results, outDir := rep.Results, rep.OutputDir
+	successes, failures := rep.Succeeded, rep.Failed
+	// Only the bundle is left of a removed output directory
+	if rep.Bundle != "" && cfg.BundleRemove {
+		outDir = rep.Bundle
+	}
 
-	// Count successes and failures
-	var successes, failures int
 	var failedCommits []string
 	for _, r := range results {
 		if r.Error != nil {
-			failures++
 			failedCommits = append(failedCommits, fmt.Sprintf("  - %s: %v", r.Commit.ShortHash, r.Error))
+		}
+	}
+
+	// Quiet mode only reports a single line
+	if cfg.Quiet {
+		if failures > 0 {
+			fmt.Fprintf(stderr, "%d succeeded, %d failed: %s\n", successes, failures, outDir)
+		} else if cfg.Inventory {
+			// No tree was extracted, only metadata
+			fmt.Fprintf(stderr, "%d collected: %s\n", successes, outDir)
 		} else {
-			successes++
+			fmt.Fprintf(stderr, "%d extracted: %s\n", successes, outDir)
 		}
+		return
+	}
+
+	fmt.Fprintln(cfg.out(), "")
+
+	// All-branches mode breaks the totals down per branch
+	if len(rep.Branches) > 1 {
+		printBranchSummaries(cfg.out(), rep)
+		fmt.Fprintln(cfg.out(), "")
 	}
 
 	if failures > 0 {
 		red := color.New(color.FgRed, color.Bold).SprintFunc()
-		fmt.Fprintf(os.Stderr, "%s Completed with errors: %d succeeded, %d failed\n", red("⚠"), successes, failures)
+		fmt.Fprintf(cfg.out(), "%s Completed with errors: %d succeeded, %d failed\n", red("⚠"), successes, failures)
 		if cfg.Verbose && len(failedCommits) > 0 {
-			fmt.Fprintln(os.Stderr, "Failed commits:")
+			fmt.Fprintln(cfg.out(), "Failed commits:")
 			for _, fc := range failedCommits {
-				fmt.Fprintln(os.Stderr, fc)
+				fmt.Fprintln(cfg.out(), fc)
+			}
+		}
+	}
+
+	if rep.Elapsed > 0 && len(results) > 0 {
+		line := fmt.Sprintf("Elapsed: %s, %.1f commits/s", rep.Elapsed.Round(time.Millisecond),
+			float64(len(results))/rep.Elapsed.Seconds())
+		if avg, metadata := commitTimes(results); avg > 0 {
+			line += fmt.Sprintf(" (%s per commit, %.0f%% of it on metadata)", avg.Round(100*time.Microsecond), 100*metadata)
+		}
+		fmt.Fprintln(cfg.out(), line)
+	}
+
+	if cfg.FirstParent {
+		fmt.Fprintln(cfg.out(), "History mode: first-parent (merged side branches were not extracted)")
+	}
+
+	if rep.Empty > 0 {
+		if cfg.SkipEmpty {
+			fmt.Fprintf(cfg.out(), "Empty commits: %d (skipped)\n", rep.Empty)
+		} else {
+			fmt.Fprintf(cfg.out(), "Empty commits: %d (use --skip-empty to omit them)\n", rep.Empty)
+		}
+	}
+
+	if rep.UnresolvedSubmodules > 0 {
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		fmt.Fprintf(cfg.out(), "%s %d submodules were not available locally and were left empty\n", yellow("⚠"), rep.UnresolvedSubmodules)
+	}
+
+	if rep.LFSPointers > 0 {
+		if git.LFSInstalled() {
+			fmt.Fprintf(cfg.out(), "LFS files: %d of %d materialized\n", rep.LFSFetched, rep.LFSPointers)
+		} else {
+			fmt.Fprintf(cfg.out(), "LFS pointers: %d (install git-lfs to materialize them)\n", rep.LFSPointers)
+		}
+	}
+
+	if cfg.CAS {
+		fmt.Fprintf(cfg.out(), "Object store: %d distinct files in %s/\n", rep.CASObjects, config.CASDirName)
+	}
+
+	if cfg.Exec != "" {
+		if rep.ExecFailed > 0 {
+			yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+			fmt.Fprintf(cfg.out(), "%s Exec: %d commits exited non-zero (see exec.log in their folders)\n", yellow("⚠"), rep.ExecFailed)
+		} else {
+			fmt.Fprintln(cfg.out(), "Exec: succeeded in every commit")
+		}
+	}
+
+	if rep.FileTimesSkipped > 0 {
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		limit := cfg.FileTimesMaxFiles
+		if limit == 0 {
+			limit = config.DefaultFileTimesMaxFiles
+		}
+		fmt.Fprintf(cfg.out(), "%s File times were not set in %d commits with more than %d files (see --file-times-max-files)\n", yellow("⚠"), rep.FileTimesSkipped, limit)
+	}
+
+	if rep.XattrsSkipped > 0 {
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		fmt.Fprintf(cfg.out(), "%s Extended attributes are not supported here; %d commits were extracted without them\n", yellow("⚠"), rep.XattrsSkipped)
+	}
+
+	if cfg.Inventory {
+		fmt.Fprintf(cfg.out(), "Inventory: metadata of %d commits in %s (no trees extracted)\n", len(rep.Results), report.InventoryFileName)
+	}
+
+	if cfg.DuplicateTrees {
+		fmt.Fprintf(cfg.out(), "Duplicate trees: %d shared by several commits (see %s)\n", rep.DuplicateTrees, report.DuplicateTreesFileName)
+	}
+
+	if cfg.IncludeWorktree {
+		fmt.Fprintf(cfg.out(), "Working tree: %d files in %s/\n", rep.WorktreeFiles, config.WorktreeDirName)
+	}
+
+	if rep.SkippedLarge > 0 {
+		fmt.Fprintf(cfg.out(), "Large files skipped: %d (over %d bytes)\n", rep.SkippedLarge, cfg.MaxFileSize)
+	}
+
+	if rep.LargeBinaries > 0 {
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		fmt.Fprintf(cfg.out(), "%s %d commits add over %d bytes of binary files:\n", yellow("⚠"), rep.LargeBinaries, cfg.WarnBinaryBytes)
+		for _, r := range results {
+			if r.Error == nil && r.BinaryBytes > 0 {
+				fmt.Fprintf(cfg.out(), "  %s (%d bytes)\n", r.Commit, r.BinaryBytes)
 			}
 		}
 	}
 
+	if cfg.AllowedKeys != "" {
+		if rep.Untrusted > 0 {
+			yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+			fmt.Fprintf(cfg.out(), "%s %d commits not signed by an allowed key (see Trust in COMMIT_INFO.txt)\n", yellow("⚠"), rep.Untrusted)
+		} else {
+			fmt.Fprintln(cfg.out(), "All extracted commits are signed by allowed keys")
+		}
+	}
+
+	if cfg.Churn {
+		if busiest, ok := report.BusiestDay(report.Churn(rep.statsResults)); ok {
+			fmt.Fprintf(cfg.out(), "Busiest day: %s (+%d/-%d lines in %d commits)\n",
+				busiest.Date, busiest.Insertions, busiest.Deletions, busiest.Commits)
+		}
+	}
+	if ignored := len(results) - len(rep.statsResults); cfg.IgnoreRevsFile != "" && ignored > 0 {
+		fmt.Fprintf(cfg.out(), "Statistics leave out %d commits listed in %s\n", ignored, cfg.IgnoreRevsFile)
+	}
+
+	if cfg.Sizes {
+		if largest, ok := largestFile(results); ok {
+			fmt.Fprintf(cfg.out(), "Largest file: %s (%d bytes) in %s\n",
+				largest.LargestFile.Path, largest.LargestFile.Size, largest.Commit.ShortHash)
+		}
+	}
+
+	if rep.Bundle != "" && !cfg.BundleRemove {
+		fmt.Fprintf(cfg.out(), "Bundle: %s\n", rep.Bundle)
+	}
+
 	green := color.New(color.FgGreen, color.Bold).SprintFunc()
-	fmt.Fprintf(os.Stderr, "\n%s Output: %s\n", green("➜"), outDir)
+	fmt.Fprintf(cfg.out(), "\n%s Output: %s\n", green("➜"), outDir)
+}
+
+// printBranchSummaries writes a table of per-branch counts followed by the totals
+func printBranchSummaries(w io.Writer, rep *Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BRANCH\tCOMMITS\tSUCCEEDED\tFAILED")
+	for _, b := range rep.Branches {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", b.Branch, b.Commits, b.Succeeded, b.Failed)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\n", len(rep.Results), rep.Succeeded, rep.Failed)
+	_ = tw.Flush()
+}
+
+// largestFile returns the result containing the largest file across all commits
+func largestFile(results []extractor.Result) (extractor.Result, bool) {
+	var largest extractor.Result
+	found := false
+	for _, r := range results {
+		if r.Error != nil || r.LargestFile.Path == "" {
+			continue
+		}
+		if !found || r.LargestFile.Size > largest.LargestFile.Size {
+			largest = r
+			found = true
+		}
+	}
+	return largest, found
 }
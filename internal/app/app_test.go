@@ -0,0 +1,2351 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/andpalmier/repopsy/internal/progress"
+	"github.com/andpalmier/repopsy/internal/report"
+	"github.com/fatih/color"
+	"github.com/klauspost/compress/zstd"
+)
+
+// setupMultiBranchRepo creates a repository with several small branches
+func setupMultiBranchRepo(tb testing.TB, branches, commitsPerBranch int) *git.Repository {
+	dir := tb.TempDir()
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			tb.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	run("init", "-b", "main")
+	run("config", "user.name", "Test User")
+	run("config", "user.email", "test@example.com")
+
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("base"), 0644); err != nil {
+		tb.Fatalf("failed to write README: %v", err)
+	}
+	run("add", ".")
+	run("commit", "-m", "Initial commit")
+
+	for b := 0; b < branches; b++ {
+		name := fmt.Sprintf("feature/%d", b)
+		run("checkout", "-q", "-b", name, "main")
+		for c := 0; c < commitsPerBranch; c++ {
+			file := filepath.Join(dir, fmt.Sprintf("b%d_c%d.txt", b, c))
+			if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+				tb.Fatalf("failed to write file: %v", err)
+			}
+			run("add", ".")
+			run("commit", "-q", "-m", fmt.Sprintf("%s commit %d", name, c))
+		}
+	}
+
+	repo, err := git.Open(dir)
+	if err != nil {
+		tb.Fatalf("failed to open repo: %v", err)
+	}
+	return repo
+}
+
+func TestRunAllBranches(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 3, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	_, err := runAllBranches(context.Background(), repo, outDir, Config{Workers: 4, BranchWorkers: 2})
+	if err != nil {
+		t.Fatalf("runAllBranches failed: %v", err)
+	}
+
+	// main has 1 commit, each feature branch has 1 + 2 commits
+	for branch, want := range map[string]int{"main": 1, "feature_0": 3, "feature_1": 3, "feature_2": 3} {
+		entries, err := os.ReadDir(filepath.Join(outDir, branch))
+		if err != nil {
+			t.Fatalf("failed to read branch dir %s: %v", branch, err)
+		}
+		if len(entries) != want {
+			t.Errorf("branch %s: expected %d commit folders, got %d", branch, want, len(entries))
+		}
+	}
+}
+
+func BenchmarkRunAllBranches(b *testing.B) {
+	repo := setupMultiBranchRepo(b, 8, 3)
+
+	for _, branchWorkers := range []int{1, 4} {
+		name := "sequential"
+		if branchWorkers > 1 {
+			name = fmt.Sprintf("parallel-%d", branchWorkers)
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				outDir := filepath.Join(b.TempDir(), "out")
+				cfg := Config{Workers: 4, BranchWorkers: branchWorkers}
+				if _, err := runAllBranches(context.Background(), repo, outDir, cfg); err != nil {
+					b.Fatalf("runAllBranches failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// recordingReporter is a progress.Reporter that records its total and events
+type recordingReporter struct {
+	mu     sync.Mutex
+	total  int
+	events []progress.Event
+}
+
+func (r *recordingReporter) Start()         {}
+func (r *recordingReporter) Finish()        {}
+func (r *recordingReporter) Error(_ string) {}
+
+func (r *recordingReporter) Increment(event progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func TestRunAllBranchesProgressTotal(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 3, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	var reporters []*recordingReporter
+	newProgress = func(cfg progress.Config) progress.Reporter {
+		r := &recordingReporter{total: cfg.Total}
+		reporters = append(reporters, r)
+		return r
+	}
+	t.Cleanup(func() { newProgress = progress.New })
+
+	if _, err := runAllBranches(context.Background(), repo, outDir, Config{Workers: 4, BranchWorkers: 2}); err != nil {
+		t.Fatalf("runAllBranches failed: %v", err)
+	}
+
+	if len(reporters) != 1 {
+		t.Fatalf("expected a single reporter spanning all branches, got %d", len(reporters))
+	}
+
+	// main has 1 commit, each of the 3 feature branches has 1 + 2
+	const want = 1 + 3*3
+	r := reporters[0]
+	if r.total != want {
+		t.Errorf("expected reporter total %d, got %d", want, r.total)
+	}
+	if len(r.events) != want {
+		t.Errorf("expected %d progress events, got %d", want, len(r.events))
+	}
+	for _, ev := range r.events {
+		if ev.Branch == "" {
+			t.Errorf("expected branch label on event for %s", ev.Hash)
+		}
+	}
+}
+
+func TestRunQuiet(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 1)
+
+	var buf bytes.Buffer
+	stderr = &buf
+	t.Cleanup(func() { stderr = os.Stderr })
+
+	cfg := Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Workers:   2,
+		Quiet:     true,
+	}
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "Repository Autopsy") {
+		t.Errorf("expected banner to be suppressed, got:\n%s", output)
+	}
+	if lines := strings.Count(strings.TrimSpace(output), "\n") + 1; lines != 1 {
+		t.Errorf("expected a one-line summary, got %d lines:\n%s", lines, output)
+	}
+	if !strings.HasPrefix(output, "3 extracted") {
+		t.Errorf("unexpected summary: %q", output)
+	}
+
+	// An inventory extracts no tree, only metadata
+	buf.Reset()
+	inventory := cfg
+	inventory.OutputDir = filepath.Join(t.TempDir(), "inventory")
+	inventory.Inventory = true
+	if err := Run(context.Background(), inventory); err != nil {
+		t.Fatalf("Run with --inventory failed: %v", err)
+	}
+	if output := buf.String(); !strings.HasPrefix(output, "3 collected") {
+		t.Errorf("unexpected inventory summary: %q", output)
+	}
+
+	cfg.Verbose = true
+	if err := Run(context.Background(), cfg); err == nil {
+		t.Error("expected error combining --quiet and --verbose, got nil")
+	}
+}
+
+func TestRunEmptyRepository(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q", dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\nOutput: %s", err, out)
+	}
+
+	var buf bytes.Buffer
+	stderr = &buf
+	t.Cleanup(func() { stderr = os.Stderr })
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	cfg := Config{RepoPath: dir, OutputDir: outDir, NoColor: true}
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("expected an empty repository to exit cleanly, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "repository has no commits") {
+		t.Errorf("expected the empty repository to be reported, got:\n%s", buf.String())
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Errorf("expected no output directory, got %v", err)
+	}
+
+	cfg.List = true
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Errorf("expected listing an empty repository to exit cleanly, got %v", err)
+	}
+
+	cfg.List = false
+	if _, err := Extract(context.Background(), cfg); !errors.Is(err, ErrNoCommits) {
+		t.Errorf("expected ErrNoCommits from Extract, got %v", err)
+	}
+}
+
+func TestExtractDetachedHead(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cmd := exec.Command("git", "checkout", "-q", "--detach")
+	cmd.Dir = repo.Path
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git checkout failed: %v\nOutput: %s", err, out)
+	}
+
+	// Detached on a branch commit, HEAD adds nothing to the branches
+	cfg := Config{RepoPath: repo.Path, OutputDir: filepath.Join(t.TempDir(), "out")}
+	rep, err := Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(rep.Results) != 1 || rep.Results[0].Branch != "main" {
+		t.Fatalf("expected only the main branch to be extracted, got %+v", rep.Results)
+	}
+
+	// A commit on no branch is only reachable from HEAD
+	commitFile(t, repo.Path, "detached.txt", "Commit on detached HEAD")
+	cfg.OutputDir = filepath.Join(t.TempDir(), "out")
+	rep, err = Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	perBranch := make(map[string]int)
+	for _, r := range rep.Results {
+		perBranch[r.Branch]++
+	}
+	if perBranch["main"] != 1 || perBranch["HEAD"] != 2 {
+		t.Errorf("expected 1 commit on main and 2 on the detached HEAD, got %v", perBranch)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "HEAD")); err != nil {
+		t.Errorf("expected a HEAD folder: %v", err)
+	}
+}
+
+func TestRunNoColor(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+
+	var buf bytes.Buffer
+	stderr = &buf
+	noColor := color.NoColor
+	t.Cleanup(func() {
+		stderr = os.Stderr
+		color.NoColor = noColor
+	})
+
+	run := func() string {
+		buf.Reset()
+		cfg := Config{RepoPath: repo.Path, OutputDir: filepath.Join(t.TempDir(), "out")}
+		if err := Run(context.Background(), cfg); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	// Pretend to write to a terminal, where colors are on by default
+	color.NoColor = false
+	if output := run(); !strings.Contains(output, "\x1b[") {
+		t.Fatalf("expected colored output without NO_COLOR, got:\n%s", output)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if output := run(); strings.Contains(output, "\x1b[") {
+		t.Errorf("expected no ANSI escape codes with NO_COLOR, got:\n%q", output)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 1)
+
+	var buf bytes.Buffer
+	stderr = &buf
+	t.Cleanup(func() { stderr = os.Stderr })
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Workers:   2,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no terminal output, got:\n%s", buf.String())
+	}
+	if rep.OutputDir != outDir {
+		t.Errorf("expected output dir %s, got %s", outDir, rep.OutputDir)
+	}
+
+	// main has 1 commit, each of the 2 feature branches has 1 + 1
+	const want = 1 + 2*2
+	if len(rep.Results) != want || rep.Succeeded != want || rep.Failed != 0 {
+		t.Errorf("expected %d successful results, got %d results (%d ok, %d failed)",
+			want, len(rep.Results), rep.Succeeded, rep.Failed)
+	}
+	for _, r := range rep.Results {
+		if _, err := os.Stat(filepath.Join(r.OutputPath, "COMMIT_INFO.txt")); err != nil {
+			t.Errorf("expected metadata for %s: %v", r.Commit.ShortHash, err)
+		}
+	}
+
+	if _, err := Extract(context.Background(), Config{RepoPath: repo.Path, Commit: "HEAD", Stdout: true}); err == nil {
+		t.Error("expected error for stdout mode, got nil")
+	}
+}
+
+func TestRunAllBranchesFlatten(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 1)
+
+	// feature_0 sanitizes to the same prefix as feature/0 and holds the same commits
+	cmd := exec.Command("git", "branch", "feature_0", "feature/0")
+	cmd.Dir = repo.Path
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git branch failed: %v\nOutput: %s", err, out)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, err := runAllBranches(context.Background(), repo, outDir, Config{Workers: 4, BranchWorkers: 2, Flatten: true}); err != nil {
+		t.Fatalf("runAllBranches failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("failed to read output dir: %v", err)
+	}
+
+	archives := make(map[string]int)
+	for _, e := range entries {
+		if e.IsDir() {
+			t.Errorf("expected a flat output directory, found folder %s", e.Name())
+			continue
+		}
+		name := e.Name()
+		if !strings.HasSuffix(name, ".tar") {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "__")
+		if !ok {
+			t.Errorf("expected branch prefix in archive name %s", name)
+			continue
+		}
+		archives[prefix]++
+		if _, err := os.Stat(filepath.Join(outDir, strings.TrimSuffix(name, ".tar")+".txt")); err != nil {
+			t.Errorf("expected metadata next to %s: %v", name, err)
+		}
+	}
+
+	// main has 1 commit, each feature branch 1 + 1; feature/0 and feature_0 share a prefix
+	for prefix, want := range map[string]int{"main": 1, "feature_0": 4, "feature_1": 2} {
+		if archives[prefix] != want {
+			t.Errorf("prefix %s: expected %d archives, got %d", prefix, want, archives[prefix])
+		}
+	}
+}
+
+func TestRunAllBranchesPattern(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 3, 1)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	cfg := Config{Workers: 2, BranchPattern: "feature/*, main", ExcludeBranches: "feature/1"}
+	if _, err := runAllBranches(context.Background(), repo, outDir, cfg); err != nil {
+		t.Fatalf("runAllBranches failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("failed to read output dir: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if want := "feature_0,feature_2,main"; strings.Join(got, ",") != want {
+		t.Errorf("expected branch dirs %s, got %v", want, got)
+	}
+
+	cfg = Config{Workers: 2, BranchPattern: "release/*"}
+	_, err = runAllBranches(context.Background(), repo, filepath.Join(t.TempDir(), "none"), cfg)
+	if err == nil || !strings.Contains(err.Error(), "match") {
+		t.Errorf("expected a no-match error, got %v", err)
+	}
+}
+
+func TestExtractSkipEmpty(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cmd := exec.Command("git", "commit", "-q", "--allow-empty", "-m", "Empty commit")
+	cmd.Dir = repo.Path
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\nOutput: %s", err, out)
+	}
+
+	for _, skip := range []bool{false, true} {
+		outDir := filepath.Join(t.TempDir(), "out")
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:  repo.Path,
+			OutputDir: outDir,
+			Branch:    "main",
+			Workers:   2,
+			SkipEmpty: skip,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if rep.Empty != 1 {
+			t.Errorf("skip=%v: expected 1 empty commit, got %d", skip, rep.Empty)
+		}
+
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatalf("failed to read output dir: %v", err)
+		}
+		var folders int
+		for _, e := range entries {
+			if e.IsDir() {
+				folders++
+			}
+		}
+		want := 2
+		if skip {
+			want = 1
+		}
+		if folders != want {
+			t.Errorf("skip=%v: expected %d commit folders, got %d", skip, want, folders)
+		}
+	}
+}
+
+func TestExtractReview(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 3)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Branch:    "feature/0",
+		Review:    true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	var patches []string
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("failed to read output directory: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			t.Errorf("expected no commit folders, got %s", e.Name())
+		}
+		if strings.HasSuffix(e.Name(), ".patch") {
+			patches = append(patches, e.Name())
+		}
+	}
+
+	// Directory order is name order, which must be the commit order
+	if len(patches) != len(rep.Results) {
+		t.Fatalf("expected %d patches, got %v", len(rep.Results), patches)
+	}
+	for i, r := range rep.Results {
+		want := fmt.Sprintf("%04d-%s-", i+1, r.Commit.ShortHash)
+		if !strings.HasPrefix(patches[i], want) {
+			t.Errorf("expected patch %d to start with %s, got %s", i, want, patches[i])
+		}
+	}
+	if want := fmt.Sprintf("0004-%s-feature-0-commit-2.patch", rep.Results[3].Commit.ShortHash); patches[3] != want {
+		t.Errorf("expected %s, got %s", want, patches[3])
+	}
+
+	index, err := os.ReadFile(filepath.Join(outDir, "INDEX.md"))
+	if err != nil {
+		t.Fatalf("failed to read INDEX.md: %v", err)
+	}
+	for _, name := range patches {
+		if !strings.Contains(string(index), "("+name+")") {
+			t.Errorf("expected INDEX.md to link %s, got:\n%s", name, index)
+		}
+	}
+}
+
+func TestExtractRefs(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Refs:      "feature/0, feature/1",
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	// Both branches start from the initial commit, which is extracted once
+	if len(rep.Results) != 5 {
+		t.Fatalf("expected 5 commits, got %d", len(rep.Results))
+	}
+	seen := make(map[string]bool)
+	var initial int
+	for _, r := range rep.Results {
+		if seen[r.Commit.Hash] {
+			t.Errorf("commit %s extracted twice", r.Commit.ShortHash)
+		}
+		seen[r.Commit.Hash] = true
+		if r.Commit.Subject == "Initial commit" {
+			initial++
+		}
+		if filepath.Dir(r.OutputPath) != outDir {
+			t.Errorf("expected %s directly in the output directory, got %s", r.Commit.ShortHash, r.OutputPath)
+		}
+	}
+	if initial != 1 {
+		t.Errorf("expected the shared initial commit once, got %d", initial)
+	}
+
+	_, err = Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Refs:      "feature/0,missing",
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected error for an unknown ref, got %v", err)
+	}
+}
+
+func TestExtractWarnBinaryBytes(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	// NUL bytes make git treat the file as binary
+	if err := os.WriteFile(filepath.Join(repo.Path, "data.bin"), make([]byte, 8192), 0644); err != nil {
+		t.Fatalf("failed to write data.bin: %v", err)
+	}
+	for _, args := range [][]string{
+		{"add", "."},
+		{"commit", "-q", "-m", "Add dataset"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	extract := func(threshold int64) *Report {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:        repo.Path,
+			OutputDir:       filepath.Join(t.TempDir(), "out"),
+			Branch:          "main",
+			WarnBinaryBytes: threshold,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		return rep
+	}
+
+	rep := extract(4096)
+	if rep.LargeBinaries != 1 {
+		t.Fatalf("expected 1 commit over the threshold, got %d", rep.LargeBinaries)
+	}
+	for _, r := range rep.Results {
+		info, err := os.ReadFile(filepath.Join(r.OutputPath, "COMMIT_INFO.txt"))
+		if err != nil {
+			t.Fatalf("failed to read metadata: %v", err)
+		}
+		flagged := r.Commit.Subject == "Add dataset"
+		if flagged && r.BinaryBytes != 8192 {
+			t.Errorf("expected 8192 binary bytes, got %d", r.BinaryBytes)
+		}
+		if !flagged && r.BinaryBytes != 0 {
+			t.Errorf("%s: unexpected binary bytes %d", r.Commit.Subject, r.BinaryBytes)
+		}
+		if strings.Contains(string(info), "WARNING:") != flagged {
+			t.Errorf("%s: expected warning in metadata to be %v, got:\n%s", r.Commit.Subject, flagged, info)
+		}
+	}
+
+	if rep := extract(8192); rep.LargeBinaries != 0 {
+		t.Errorf("expected no commit over the threshold, got %d", rep.LargeBinaries)
+	}
+}
+
+func TestExtractDuplicateTrees(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	if err := os.WriteFile(filepath.Join(repo.Path, "feature.txt"), []byte("feature"), 0644); err != nil {
+		t.Fatalf("failed to write feature.txt: %v", err)
+	}
+	// Reverting the only change brings the tree back to the initial one
+	for _, args := range [][]string{
+		{"add", "."},
+		{"commit", "-q", "-m", "Add feature"},
+		{"revert", "--no-edit", "HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:       repo.Path,
+		OutputDir:      outDir,
+		Branch:         "main",
+		DuplicateTrees: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if rep.DuplicateTrees != 1 {
+		t.Errorf("expected 1 duplicate tree, got %d", rep.DuplicateTrees)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, report.DuplicateTreesFileName))
+	if err != nil {
+		t.Fatalf("failed to read duplicate trees report: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "tree ") || !strings.HasSuffix(lines[0], "(2 commits)") {
+		t.Fatalf("expected one group of two commits, got:\n%s", data)
+	}
+	if !strings.HasSuffix(lines[1], "Initial commit") || !strings.HasSuffix(lines[2], `Revert "Add feature"`) {
+		t.Errorf("expected the initial and revert commits to be grouped, got:\n%s", data)
+	}
+}
+
+func TestExtractSummary(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Workers:   2,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, report.SummaryFileName))
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+	var summary report.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("invalid summary.json: %v", err)
+	}
+
+	if summary.Commits != len(rep.Results) || summary.Succeeded != rep.Succeeded || summary.Failed != rep.Failed {
+		t.Errorf("summary counts %+v do not match the report (%d results, %d succeeded, %d failed)",
+			summary, len(rep.Results), rep.Succeeded, rep.Failed)
+	}
+	if len(summary.Branches) != 2 || summary.Workers != 2 || summary.BytesWritten <= 0 {
+		t.Errorf("unexpected run details: %+v", summary)
+	}
+	if summary.Repository != repo.Path || summary.OutputDir != outDir {
+		t.Errorf("unexpected paths: repository %q, output %q", summary.Repository, summary.OutputDir)
+	}
+}
+
+func TestExtractCAS(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 1)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Branch:    "feature/0",
+		Workers:   2,
+		CAS:       true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(rep.Results) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(rep.Results))
+	}
+
+	// Both commits contain the unchanged README
+	var readmeObjects int
+	err = filepath.WalkDir(filepath.Join(outDir, "objects"), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if data, err := os.ReadFile(path); err == nil && string(data) == "base" {
+			readmeObjects++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to walk object store: %v", err)
+	}
+	if readmeObjects != 1 {
+		t.Errorf("expected one object for the shared README, got %d", readmeObjects)
+	}
+	if rep.CASObjects != 2 {
+		t.Errorf("expected 2 distinct objects, got %d", rep.CASObjects)
+	}
+
+	for _, r := range rep.Results {
+		readme := filepath.Join(r.OutputPath, "README")
+		if info, err := os.Lstat(readme); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("%s: expected README to be a symlink, got %v, %v", r.Commit.ShortHash, info, err)
+		}
+		if data, err := os.ReadFile(readme); err != nil || string(data) != "base" {
+			t.Errorf("%s: README resolves to %q, %v", r.Commit.ShortHash, data, err)
+		}
+		if info, err := os.Lstat(filepath.Join(r.OutputPath, "COMMIT_INFO.txt")); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("%s: expected COMMIT_INFO.txt to stay a regular file", r.Commit.ShortHash)
+		}
+	}
+}
+
+func TestRunList(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 2)
+
+	var buf bytes.Buffer
+	if err := runList(context.Background(), repo, Config{BranchPattern: "feature/*"}, &buf); err != nil {
+		t.Fatalf("runList failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// Header plus 1 + 2 commits on each of the 2 feature branches
+	if want := 1 + 2*3; len(lines) != want {
+		t.Fatalf("expected %d lines, got %d:\n%s", want, len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "BRANCH") {
+		t.Errorf("expected header line, got %q", lines[0])
+	}
+	if !strings.Contains(buf.String(), "feature/1 commit 1") {
+		t.Errorf("expected commit subjects in listing:\n%s", buf.String())
+	}
+}
+
+func TestExtractMaxFileSize(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	big := bytes.Repeat([]byte("x"), 1<<20)
+	if err := os.WriteFile(filepath.Join(repo.Path, "big.bin"), big, 0644); err != nil {
+		t.Fatalf("failed to write big.bin: %v", err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Add big file"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:    repo.Path,
+		OutputDir:   outDir,
+		Branch:      "main",
+		Workers:     2,
+		MaxFileSize: 100 << 10,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if rep.SkippedLarge != 1 {
+		t.Errorf("expected 1 skipped large file, got %d", rep.SkippedLarge)
+	}
+
+	for _, r := range rep.Results {
+		if _, err := os.Stat(filepath.Join(r.OutputPath, "README")); err != nil {
+			t.Errorf("%s: expected README to be extracted: %v", r.Commit.Subject, err)
+		}
+		if _, err := os.Stat(filepath.Join(r.OutputPath, "big.bin")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected big.bin to be skipped, got %v", r.Commit.Subject, err)
+		}
+	}
+}
+
+func TestExtractNewestFirst(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+
+	for _, newestFirst := range []bool{false, true} {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:    repo.Path,
+			OutputDir:   filepath.Join(t.TempDir(), "out"),
+			Branch:      "feature/0",
+			Workers:     2,
+			NewestFirst: newestFirst,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		want := "Initial commit"
+		if newestFirst {
+			want = "feature/0 commit 1"
+		}
+		for _, r := range rep.Results {
+			if r.Index == 0 && r.Commit.Subject != want {
+				t.Errorf("newestFirst=%v: expected index 0 to be %q, got %q", newestFirst, want, r.Commit.Subject)
+			}
+		}
+	}
+}
+
+func TestExtractCompare(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 3)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Branch:    "feature/0",
+		Workers:   2,
+		Compare:   true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(outDir, "diffs"))
+	if err != nil {
+		t.Fatalf("failed to read diffs directory: %v", err)
+	}
+	if len(entries) != len(rep.Results)-1 {
+		t.Fatalf("expected %d diffs for %d commits, got %d", len(rep.Results)-1, len(rep.Results), len(entries))
+	}
+
+	diff, err := os.ReadFile(filepath.Join(outDir, "diffs", "0000-to-0001.diff"))
+	if err != nil {
+		t.Fatalf("failed to read first diff: %v", err)
+	}
+	if !strings.HasPrefix(string(diff), "diff --git") {
+		t.Errorf("expected a git diff, got %q", diff)
+	}
+}
+
+func TestExtractRaw(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Branch:    "feature/0",
+		Workers:   2,
+		Raw:       true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, r := range rep.Results {
+		object, err := os.ReadFile(filepath.Join(r.OutputPath, "raw", "object.txt"))
+		if err != nil {
+			t.Fatalf("failed to read raw object: %v", err)
+		}
+		if !strings.HasPrefix(string(object), "tree ") {
+			t.Errorf("%s: expected a raw commit object, got %q", r.Commit.ShortHash, object)
+		}
+
+		tree, err := os.ReadFile(filepath.Join(r.OutputPath, "raw", "tree.txt"))
+		if err != nil {
+			t.Fatalf("failed to read raw tree: %v", err)
+		}
+		// b0_c0.txt is committed on top of the initial commit
+		names := []string{"README", "b0_c0.txt"}
+		if r.Commit.Subject == "Initial commit" {
+			names = names[:1]
+		}
+		for _, name := range names {
+			cmd := exec.Command("git", "rev-parse", r.Commit.Hash+":"+name)
+			cmd.Dir = repo.Path
+			blob, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("git rev-parse failed: %v", err)
+			}
+			line := "100644 blob " + strings.TrimSpace(string(blob)) + "\t" + name
+			if !strings.Contains(string(tree), line) {
+				t.Errorf("%s: expected tree listing to contain %q, got:\n%s", r.Commit.ShortHash, line, tree)
+			}
+		}
+	}
+}
+
+func TestExtractFilterFile(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	for _, name := range []string{"node_modules/pkg/index.js", "web/node_modules/lib.js", "src/main.go"} {
+		file := filepath.Join(repo.Path, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	commitFile(t, repo.Path, "generated.pb.go", "Add sources and dependencies")
+	commitFile(t, repo.Path, "notes.txt", "Add notes")
+
+	filterFile := filepath.Join(t.TempDir(), "filters")
+	filters := "# Dependencies\n\nnode_modules/\n  *.pb.go  \n"
+	if err := os.WriteFile(filterFile, []byte(filters), 0644); err != nil {
+		t.Fatalf("failed to write filter file: %v", err)
+	}
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:   repo.Path,
+		OutputDir:  filepath.Join(t.TempDir(), "out"),
+		Branch:     "main",
+		FilterFile: filterFile,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(rep.Results) != 3 {
+		t.Fatalf("expected 3 commits, got %d", len(rep.Results))
+	}
+	for _, r := range rep.Results {
+		err := filepath.WalkDir(r.OutputPath, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Name() == "node_modules" || strings.HasSuffix(d.Name(), ".pb.go") {
+				t.Errorf("%s: filtered path %s was extracted", r.Commit.Subject, path)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to walk %s: %v", r.OutputPath, err)
+		}
+		if r.Commit.Subject == "Add notes" {
+			if _, err := os.Stat(filepath.Join(r.OutputPath, "src", "main.go")); err != nil {
+				t.Errorf("expected unfiltered files to be extracted: %v", err)
+			}
+		}
+	}
+}
+
+func TestExtractKeepEmptyDirs(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	for name, size := range map[string]int{
+		"assets/images/logo.bin":      4096,
+		"node_modules/left/index.js":  10,
+		"node_modules/left/huge.json": 4096,
+	} {
+		file := filepath.Join(repo.Path, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, bytes.Repeat([]byte{'x'}, size), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	commitFile(t, repo.Path, "main.go", "Add assets")
+
+	for _, keep := range []bool{false, true} {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:      repo.Path,
+			OutputDir:     filepath.Join(t.TempDir(), "out"),
+			Branch:        "main",
+			MaxFileSize:   1024,
+			Exclude:       []string{"node_modules/"},
+			KeepEmptyDirs: keep,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		last := rep.Results[len(rep.Results)-1].OutputPath
+		if _, err := os.Stat(filepath.Join(last, "assets", "images", "logo.bin")); !os.IsNotExist(err) {
+			t.Errorf("keep=%v: expected the large file to be skipped, got %v", keep, err)
+		}
+		info, err := os.Stat(filepath.Join(last, "assets", "images"))
+		switch {
+		case keep && (err != nil || !info.IsDir()):
+			t.Errorf("expected the directory emptied by the size limit to be recreated: %v", err)
+		case !keep && !os.IsNotExist(err):
+			t.Errorf("expected the emptied directory to be absent without --keep-empty-dirs, got %v", err)
+		}
+		// Excluded directories stay out, even when they hold large files
+		if _, err := os.Stat(filepath.Join(last, "node_modules")); !os.IsNotExist(err) {
+			t.Errorf("keep=%v: expected the excluded directory to stay out, got %v", keep, err)
+		}
+	}
+
+	// With --delta-only, large files a commit did not touch are neither
+	// counted nor have their directories recreated
+	commitFile(t, repo.Path, "later.txt", "Add later")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:      repo.Path,
+		OutputDir:     filepath.Join(t.TempDir(), "delta"),
+		Branch:        "main",
+		MaxFileSize:   1024,
+		Exclude:       []string{"node_modules/"},
+		KeepEmptyDirs: true,
+		DeltaOnly:     true,
+	})
+	if err != nil {
+		t.Fatalf("Extract with --delta-only failed: %v", err)
+	}
+	assets, later := rep.Results[len(rep.Results)-2], rep.Results[len(rep.Results)-1]
+	if assets.SkippedLarge != 1 {
+		t.Errorf("expected the commit adding the large file to skip it, got %d", assets.SkippedLarge)
+	}
+	if info, err := os.Stat(filepath.Join(assets.OutputPath, "assets", "images")); err != nil || !info.IsDir() {
+		t.Errorf("expected the directory of the added large file to be recreated: %v", err)
+	}
+	if later.SkippedLarge != 0 {
+		t.Errorf("expected no skipped files for a commit not touching them, got %d", later.SkippedLarge)
+	}
+	if _, err := os.Stat(filepath.Join(later.OutputPath, "assets")); !os.IsNotExist(err) {
+		t.Errorf("expected untouched directories to stay out with --delta-only, got %v", err)
+	}
+
+	if _, err := Extract(context.Background(), Config{RepoPath: repo.Path, KeepEmptyDirs: true}); err == nil || !strings.Contains(err.Error(), "requires --max-file-size") {
+		t.Errorf("expected --keep-empty-dirs without --max-file-size to be rejected, got %v", err)
+	}
+}
+
+func TestExtractBaseline(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cmd := exec.Command("git", "tag", "release")
+	cmd.Dir = repo.Path
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git tag failed: %v\nOutput: %s", err, out)
+	}
+	commitFile(t, repo.Path, "one.txt", "Add one")
+	commitFile(t, repo.Path, "two.txt", "Add two")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "main",
+		Baseline:  "release",
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(rep.Results) != 3 {
+		t.Fatalf("expected 3 commits, got %d", len(rep.Results))
+	}
+
+	var previous int
+	for i, r := range rep.Results {
+		diff, err := os.ReadFile(filepath.Join(r.OutputPath, "vs_baseline.diff"))
+		if err != nil {
+			t.Fatalf("%s: failed to read baseline diff: %v", r.Commit.Subject, err)
+		}
+		if i > 0 && len(diff) <= previous {
+			t.Errorf("%s: expected the baseline diff to grow past %d bytes, got %d", r.Commit.Subject, previous, len(diff))
+		}
+		previous = len(diff)
+	}
+	last, err := os.ReadFile(filepath.Join(rep.Results[2].OutputPath, "vs_baseline.diff"))
+	if err != nil {
+		t.Fatalf("failed to read baseline diff: %v", err)
+	}
+	if !strings.Contains(string(last), "one.txt") || !strings.Contains(string(last), "two.txt") {
+		t.Errorf("expected the last diff to hold both commits' changes, got:\n%s", last)
+	}
+
+	_, err = Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "main",
+		Baseline:  "no-such-ref",
+	})
+	if err == nil {
+		t.Error("expected an unknown baseline to be rejected")
+	}
+}
+
+func TestExtractCommitsFile(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	commitFile(t, repo.Path, "one.txt", "Add one")
+	commitFile(t, repo.Path, "two.txt", "Add two")
+	var hashes []string
+	for _, rev := range []string{"HEAD", "HEAD~2"} {
+		cmd := exec.Command("git", "rev-parse", rev)
+		cmd.Dir = repo.Path
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git rev-parse %s failed: %v", rev, err)
+		}
+		hashes = append(hashes, strings.TrimSpace(string(out)))
+	}
+
+	// Newest first, with a comment and a hash that does not exist
+	list := "# suspects\n" + hashes[0] + "\n\ndeadbeefdeadbeefdeadbeefdeadbeefdeadbeef\n" + hashes[1] + "\n"
+	commitsFile := filepath.Join(t.TempDir(), "commits.txt")
+	if err := os.WriteFile(commitsFile, []byte(list), 0644); err != nil {
+		t.Fatalf("failed to write commits file: %v", err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:       repo.Path,
+		OutputDir:      outDir,
+		CommitsFile:    commitsFile,
+		FolderTemplate: `{{printf "%02d" .Index}}_{{.Commit.Subject}}`,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("failed to read output directory: %v", err)
+	}
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, entry.Name())
+		}
+	}
+	want := []string{"00_Add two", "01_Initial commit"}
+	if !slices.Equal(folders, want) {
+		t.Errorf("expected folders %v in file order, got %v", want, folders)
+	}
+	if len(rep.Results) != 2 {
+		t.Errorf("expected the unresolved hash to be skipped, got %d results", len(rep.Results))
+	}
+}
+
+func TestExtractIncludeDiff(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	var lines strings.Builder
+	// Longer than the default cap of config.DefaultMaxDiffLines
+	for i := range 1200 {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path, "notes.txt"), []byte(lines.String()), 0644); err != nil {
+		t.Fatalf("failed to write notes: %v", err)
+	}
+	commitFile(t, repo.Path, "other.txt", "Add notes")
+
+	for _, maxLines := range []int{-1, 0, 10} {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:     repo.Path,
+			OutputDir:    filepath.Join(t.TempDir(), "out"),
+			Branch:       "main",
+			IncludeDiff:  true,
+			MaxDiffLines: maxLines,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		info, err := os.ReadFile(filepath.Join(rep.Results[1].OutputPath, "COMMIT_INFO.txt"))
+		if err != nil {
+			t.Fatalf("failed to read metadata: %v", err)
+		}
+		_, diff, ok := strings.Cut(string(info), "\nDIFF\n----\n")
+		if !ok {
+			t.Fatalf("max %d: expected a DIFF section, got:\n%s", maxLines, info)
+		}
+		if !strings.Contains(diff, "diff --git a/notes.txt b/notes.txt") {
+			t.Errorf("max %d: expected the diff to cover notes.txt, got:\n%s", maxLines, diff)
+		}
+
+		truncated := strings.HasSuffix(diff, "[diff truncated]\n")
+		switch maxLines {
+		case -1:
+			if truncated || !strings.Contains(diff, "+line 1199\n") || !strings.Contains(diff, "other.txt") {
+				t.Errorf("expected the whole diff without a limit, got %d lines", strings.Count(diff, "\n"))
+			}
+		case 0:
+			if n := strings.Count(diff, "\n"); !truncated || n != config.DefaultMaxDiffLines+1 {
+				t.Errorf("expected the diff cut at the default cap, got %d lines", n-1)
+			}
+		default:
+			if n := strings.Count(diff, "\n"); !truncated || n != maxLines+1 {
+				t.Errorf("expected %d diff lines and a truncation note, got %d:\n%s", maxLines, n-1, diff)
+			}
+		}
+	}
+}
+
+func TestExtractTags(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	for _, args := range [][]string{
+		{"tag", "v1.10.0"},
+		{"commit", "-q", "--allow-empty", "-m", "Second"},
+		{"tag", "-a", "-m", "Release", "v1.2.0"},
+		{"commit", "-q", "--allow-empty", "-m", "Third"},
+		{"tag", "v1.9.0"},
+		{"tag", "latest"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:   repo.Path,
+		OutputDir:  filepath.Join(t.TempDir(), "out"),
+		Tags:       true,
+		TagPattern: "v*",
+		SemverSort: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	want := []string{"v1.2.0", "v1.9.0", "v1.10.0"}
+	if len(rep.Results) != len(want) {
+		t.Fatalf("expected %d tags, got %d", len(want), len(rep.Results))
+	}
+	for _, r := range rep.Results {
+		tag := want[r.Index]
+		if r.Commit.Tag != tag {
+			t.Errorf("index %d: expected tag %s, got %s", r.Index, tag, r.Commit.Tag)
+		}
+		if !strings.HasPrefix(filepath.Base(r.OutputPath), tag+"_") {
+			t.Errorf("expected folder prefixed with %s, got %s", tag, r.OutputPath)
+		}
+		info, err := os.ReadFile(filepath.Join(r.OutputPath, "COMMIT_INFO.txt"))
+		if err != nil {
+			t.Fatalf("failed to read metadata: %v", err)
+		}
+		if !strings.Contains(string(info), "Tag:            "+tag+"\n") {
+			t.Errorf("expected metadata to record tag %s, got:\n%s", tag, info)
+		}
+	}
+}
+
+func TestExtractBundle(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+
+	for _, format := range []string{BundleTar, BundleTarZst, BundleZip} {
+		t.Run(format, func(t *testing.T) {
+			outDir := filepath.Join(t.TempDir(), "out")
+			remove := format == BundleZip
+			rep, err := Extract(context.Background(), Config{
+				RepoPath:     repo.Path,
+				OutputDir:    outDir,
+				Branch:       "feature/0",
+				Bundle:       format,
+				BundleRemove: remove,
+			})
+			if err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if rep.Bundle != outDir+"."+format {
+				t.Fatalf("expected bundle %s.%s, got %q", outDir, format, rep.Bundle)
+			}
+			if _, err := os.Stat(outDir); remove != errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected output directory removed to be %v, got stat error %v", remove, err)
+			}
+
+			entries := bundleEntries(t, rep.Bundle, format)
+			for _, r := range rep.Results {
+				name := "out/" + filepath.Base(r.OutputPath) + "/COMMIT_INFO.txt"
+				if !entries[name] {
+					t.Errorf("expected bundle to contain %s, got %v", name, entries)
+				}
+			}
+		})
+	}
+}
+
+// bundleEntries lists the names in a bundle of the given format
+func bundleEntries(t *testing.T, path, format string) map[string]bool {
+	t.Helper()
+	entries := make(map[string]bool)
+
+	if format == BundleZip {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("failed to open zip: %v", err)
+		}
+		defer func() { _ = zr.Close() }()
+		for _, f := range zr.File {
+			entries[f.Name] = true
+		}
+		return entries
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open bundle: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	var r io.Reader = f
+	if format == BundleTarZst {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to open zstd stream: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar: %v", err)
+		}
+		entries[hdr.Name] = true
+	}
+	return entries
+}
+
+func TestExtractExec(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "feature/0",
+		Workers:   2,
+		Exec:      "ls {dir}",
+		ExecJobs:  2,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, r := range rep.Results {
+		if r.Exec == nil || r.Exec.ExitCode != 0 || !strings.Contains(r.Exec.Output, "README") {
+			t.Errorf("%s: expected a successful ls listing README, got %+v", r.Commit.ShortHash, r.Exec)
+		}
+		log, err := os.ReadFile(filepath.Join(r.OutputPath, "exec.log"))
+		if err != nil {
+			t.Fatalf("failed to read exec.log: %v", err)
+		}
+		if !strings.Contains(string(log), "exit code: 0") || !strings.Contains(string(log), "COMMIT_INFO.txt") {
+			t.Errorf("%s: unexpected exec.log:\n%s", r.Commit.ShortHash, log)
+		}
+	}
+	if rep.ExecFailed != 0 {
+		t.Errorf("expected no failed exec commands, got %d", rep.ExecFailed)
+	}
+
+	rep, err = Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "feature/0",
+		Exec:      "exit 3",
+	})
+	if err != nil {
+		t.Fatalf("Extract with a failing command failed: %v", err)
+	}
+	if rep.ExecFailed != len(rep.Results) || rep.Results[0].Exec.ExitCode != 3 {
+		t.Errorf("expected every command to exit with 3, got %d failures", rep.ExecFailed)
+	}
+
+	// Files the command writes are neither listed in the manifest nor stored
+	for _, cas := range []bool{false, true} {
+		outDir := filepath.Join(t.TempDir(), "out")
+		rep, err = Extract(context.Background(), Config{
+			RepoPath:  repo.Path,
+			OutputDir: outDir,
+			Branch:    "feature/0",
+			Exec:      "echo scan > scan.txt",
+			Checksums: !cas,
+			CAS:       cas,
+		})
+		if err != nil {
+			t.Fatalf("Extract with cas=%v failed: %v", cas, err)
+		}
+		for _, r := range rep.Results {
+			if cas {
+				for _, name := range []string{"exec.log", "scan.txt"} {
+					info, err := os.Lstat(filepath.Join(r.OutputPath, name))
+					if err != nil || !info.Mode().IsRegular() {
+						t.Errorf("%s: expected %s to stay out of the store, got %v", r.Commit.ShortHash, name, err)
+					}
+				}
+				continue
+			}
+			sums, err := os.ReadFile(filepath.Join(r.OutputPath, extractor.ChecksumsFileName))
+			if err != nil {
+				t.Fatalf("failed to read checksums: %v", err)
+			}
+			if strings.Contains(string(sums), "exec.log") || strings.Contains(string(sums), "scan.txt") {
+				t.Errorf("%s: expected the command's files to be left out of the manifest:\n%s", r.Commit.ShortHash, sums)
+			}
+			problems, err := extractor.VerifyChecksums(r.OutputPath)
+			if err != nil {
+				t.Fatalf("VerifyChecksums failed: %v", err)
+			}
+			if len(problems) != 1 || problems[0] != "unlisted: scan.txt" {
+				t.Errorf("%s: expected only scan.txt to be reported, got %v", r.Commit.ShortHash, problems)
+			}
+		}
+	}
+}
+
+func TestExtractLFSPointers(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+	if err := os.WriteFile(filepath.Join(repo.Path, "model.bin"), []byte(pointer), 0644); err != nil {
+		t.Fatalf("failed to write model.bin: %v", err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Add LFS pointer"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "main",
+		Workers:   2,
+		LFS:       true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	// The object is in no LFS store, so the pointer is kept even with git-lfs
+	if rep.LFSPointers != 1 || rep.LFSFetched != 0 {
+		t.Errorf("expected 1 LFS pointer and none fetched, got %d and %d", rep.LFSPointers, rep.LFSFetched)
+	}
+}
+
+func TestExtractBranchSummaries(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 2)
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Workers:   2,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	want := map[string]BranchSummary{
+		"main":      {Branch: "main", Commits: 1, Succeeded: 1},
+		"feature/0": {Branch: "feature/0", Commits: 3, Succeeded: 3},
+	}
+	if len(rep.Branches) != len(want) {
+		t.Fatalf("expected %d branch summaries, got %+v", len(want), rep.Branches)
+	}
+	for _, b := range rep.Branches {
+		if b != want[b.Branch] {
+			t.Errorf("branch %s: expected %+v, got %+v", b.Branch, want[b.Branch], b)
+		}
+	}
+
+	var buf bytes.Buffer
+	printBranchSummaries(&buf, rep)
+	if !strings.Contains(buf.String(), "TOTAL") || !strings.Contains(buf.String(), "feature/0") {
+		t.Errorf("unexpected branch table:\n%s", buf.String())
+	}
+}
+
+func TestExtractInteractive(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 1)
+
+	var buf bytes.Buffer
+	stderr = &buf
+	stdin = strings.NewReader("0\n2\n")
+	t.Cleanup(func() {
+		stderr = os.Stderr
+		stdin = os.Stdin
+	})
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:    repo.Path,
+		OutputDir:   filepath.Join(t.TempDir(), "out"),
+		Workers:     2,
+		Interactive: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(rep.Branches) != 1 || rep.Branches[0].Branch != "feature/1" {
+		t.Fatalf("expected only feature/1 to be extracted, got %+v", rep.Branches)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "feature/1 (2 commits)") {
+		t.Errorf("expected commit counts in the branch list:\n%s", out)
+	}
+	if !strings.Contains(out, "Invalid selection") {
+		t.Errorf("expected out-of-range answer to be rejected:\n%s", out)
+	}
+}
+
+func TestExtractIncludeWorktree(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	for name, content := range map[string]string{
+		"README":     "modified",
+		"notes.txt":  "untracked",
+		".gitignore": "*.log\n",
+		"debug.log":  "ignored",
+	} {
+		if err := os.WriteFile(filepath.Join(repo.Path, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:        repo.Path,
+		OutputDir:       outDir,
+		Branch:          "main",
+		IncludeWorktree: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if rep.WorktreeFiles != 3 {
+		t.Errorf("expected 3 working tree files, got %d", rep.WorktreeFiles)
+	}
+
+	wt := filepath.Join(outDir, "working_tree")
+	for name, want := range map[string]string{"README": "modified", "notes.txt": "untracked"} {
+		got, err := os.ReadFile(filepath.Join(wt, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(wt, "debug.log")); !os.IsNotExist(err) {
+		t.Errorf("expected ignored debug.log to be left out, got %v", err)
+	}
+	info, err := os.ReadFile(filepath.Join(wt, "COMMIT_INFO.txt"))
+	if err != nil || !strings.Contains(string(info), "uncommitted") {
+		t.Errorf("expected COMMIT_INFO.txt marking the snapshot as uncommitted, got %q (%v)", info, err)
+	}
+
+	// An output directory inside the working tree is untracked, but the
+	// snapshot must not copy the extraction into itself
+	inside := filepath.Join(repo.Path, "main-exploded")
+	rep, err = Extract(context.Background(), Config{
+		RepoPath:        repo.Path,
+		OutputDir:       inside,
+		Branch:          "main",
+		IncludeWorktree: true,
+		CAS:             true,
+	})
+	if err != nil {
+		t.Fatalf("Extract with the output inside the repository failed: %v", err)
+	}
+	if rep.WorktreeFiles != 3 {
+		t.Errorf("expected 3 working tree files with the output inside the repository, got %d", rep.WorktreeFiles)
+	}
+	if _, err := os.Stat(filepath.Join(inside, "working_tree", "main-exploded")); !os.IsNotExist(err) {
+		t.Errorf("expected the output directory to be left out of the snapshot, got %v", err)
+	}
+	if err := os.RemoveAll(inside); err != nil {
+		t.Fatalf("failed to remove output: %v", err)
+	}
+
+	// Bare repositories have no working tree to snapshot
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	if out, err := exec.Command("git", "clone", "-q", "--bare", repo.Path, bare).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\nOutput: %s", err, out)
+	}
+	_, err = Extract(context.Background(), Config{
+		RepoPath:        bare,
+		OutputDir:       filepath.Join(t.TempDir(), "bare-out"),
+		IncludeWorktree: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "bare") {
+		t.Errorf("expected a bare repository error, got %v", err)
+	}
+}
+
+func TestRunRepositories(t *testing.T) {
+	repos := []*git.Repository{setupMultiBranchRepo(t, 0, 1), setupMultiBranchRepo(t, 1, 1)}
+
+	var buf bytes.Buffer
+	stderr = &buf
+	t.Cleanup(func() { stderr = os.Stderr })
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	missing := filepath.Join(t.TempDir(), "missing")
+	err := RunRepositories(context.Background(), Config{
+		OutputDir: outDir,
+		Workers:   2,
+		Quiet:     true,
+	}, []string{repos[0].Path, missing, repos[1].Path})
+
+	// The missing repository fails without stopping the others
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("expected an error for %s, got %v", missing, err)
+	}
+	for _, repo := range repos {
+		entries, err := os.ReadDir(filepath.Join(outDir, filepath.Base(repo.Path), "main"))
+		if err != nil || len(entries) != 1 {
+			t.Errorf("expected one main commit folder for %s, got %d (%v)", repo.Path, len(entries), err)
+		}
+	}
+	if !strings.Contains(buf.String(), "3 repositories: 2 succeeded, 1 failed") {
+		t.Errorf("unexpected combined summary:\n%s", buf.String())
+	}
+}
+
+func BenchmarkExtractMetadata(b *testing.B) {
+	repo := setupMultiBranchRepo(b, 1, 30)
+
+	for _, noMetadata := range []bool{false, true} {
+		name := "with-metadata"
+		if noMetadata {
+			name = "no-metadata"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cfg := Config{
+					RepoPath:   repo.Path,
+					OutputDir:  filepath.Join(b.TempDir(), "out"),
+					Branch:     "feature/0",
+					Workers:    4,
+					NoMetadata: noMetadata,
+				}
+				if _, err := Extract(context.Background(), cfg); err != nil {
+					b.Fatalf("Extract failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractDateSource(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	authorDate := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	commitDate := time.Date(2022, 6, 7, 8, 9, 10, 0, time.UTC)
+	cmd := exec.Command("git", "commit", "-q", "--allow-empty", "-m", "Rebased")
+	cmd.Dir = repo.Path
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_DATE="+authorDate.Format(time.RFC3339),
+		"GIT_COMMITTER_DATE="+commitDate.Format(time.RFC3339))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\nOutput: %s", err, out)
+	}
+
+	for source, date := range map[string]time.Time{git.DateAuthor: authorDate, git.DateCommit: commitDate} {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:   repo.Path,
+			OutputDir:  filepath.Join(t.TempDir(), "out"),
+			Commit:     "HEAD",
+			DateSource: source,
+		})
+		if err != nil {
+			t.Fatalf("Extract with %s dates failed: %v", source, err)
+		}
+		want := date.Local().Format(config.FolderTimestampFormat) + "_"
+		if name := filepath.Base(rep.Results[0].OutputPath); !strings.HasPrefix(name, want) {
+			t.Errorf("expected %s date folder %s*, got %s", source, want, name)
+		}
+	}
+
+	if _, err := Extract(context.Background(), Config{RepoPath: repo.Path, DateSource: "tree"}); err == nil {
+		t.Error("expected error for an unknown date source, got nil")
+	}
+}
+
+func TestExtractReproducible(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	authorDate := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(repo.Path, "src"), 0755); err != nil {
+		t.Fatalf("failed to create src: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path, "src", "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("failed to write main.go: %v", err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Add main"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+authorDate.Format(time.RFC3339))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+
+	mtimes := func() map[string]time.Time {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:     repo.Path,
+			OutputDir:    filepath.Join(t.TempDir(), "out"),
+			Commit:       "HEAD",
+			Checksums:    true,
+			Reproducible: true,
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		root := rep.Results[0].OutputPath
+		times := make(map[string]time.Time)
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			times[rel] = info.ModTime()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to walk output: %v", err)
+		}
+		return times
+	}
+
+	first, second := mtimes(), mtimes()
+	for _, name := range []string{".", "src", "src/main.go", "README", "COMMIT_INFO.txt", "SHA256SUMS"} {
+		if !first[name].Equal(authorDate) {
+			t.Errorf("%s: expected mtime %s, got %s", name, authorDate, first[name])
+		}
+	}
+	if len(first) != len(second) {
+		t.Fatalf("expected identical trees, got %d and %d entries", len(first), len(second))
+	}
+	for name, mtime := range first {
+		if !second[name].Equal(mtime) {
+			t.Errorf("%s: mtime differs between runs: %s and %s", name, mtime, second[name])
+		}
+	}
+}
+
+// commitFile commits a file with the given name and content to the checked out branch
+func commitFile(t *testing.T, dir, name, message string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(message), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", message}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+}
+
+func TestWatchPoll(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cfg := Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "HEAD",
+		Quiet:     true,
+	}
+	rep, err := Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	ctx := context.Background()
+	w := newWatcher(repo, cfg, rep)
+	if results, err := w.poll(ctx); err != nil || len(results) != 0 {
+		t.Fatalf("expected nothing new before committing, got %d results, err %v", len(results), err)
+	}
+
+	commitFile(t, repo.Path, "new.txt", "Add new file")
+	results, err := w.poll(ctx)
+	if err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Commit.Subject != "Add new file" {
+		t.Fatalf("expected the new commit to be extracted, got %v", results)
+	}
+	if results[0].Index != 1 {
+		t.Errorf("expected the numbering to continue at 1, got %d", results[0].Index)
+	}
+	if _, err := os.Stat(filepath.Join(results[0].OutputPath, "new.txt")); err != nil {
+		t.Errorf("expected the new folder to hold new.txt: %v", err)
+	}
+	if len(w.rep.Results) != 2 || w.rep.Succeeded != 2 {
+		t.Errorf("expected the report to cover both commits, got %d results", len(w.rep.Results))
+	}
+
+	if results, err := w.poll(ctx); err != nil || len(results) != 0 {
+		t.Errorf("expected nothing new on an unchanged branch, got %d results, err %v", len(results), err)
+	}
+}
+
+func TestRunWatchStopsOnCancel(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cfg := Config{
+		RepoPath:      repo.Path,
+		OutputDir:     filepath.Join(t.TempDir(), "out"),
+		Branch:        "HEAD",
+		Quiet:         true,
+		WatchInterval: 10 * time.Millisecond,
+	}
+	rep, err := Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	commitFile(t, repo.Path, "new.txt", "Add new file")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := runWatch(ctx, cfg, rep); err != nil {
+		t.Fatalf("runWatch failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(rep.OutputDir)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	var folders int
+	for _, e := range entries {
+		if e.IsDir() {
+			folders++
+		}
+	}
+	if folders != 2 {
+		t.Errorf("expected 2 commit folders after watching, got %d", folders)
+	}
+}
+
+// finishProbe records what its reporter printed before Finish was called
+type finishProbe struct {
+	progress.Reporter
+	buf          *bytes.Buffer
+	beforeFinish []string
+}
+
+func (p *finishProbe) Finish() {
+	p.beforeFinish = append(p.beforeFinish, p.buf.String())
+	p.Reporter.Finish()
+}
+
+func TestRunWatchVerboseStreams(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	cfg := Config{
+		RepoPath:      repo.Path,
+		OutputDir:     filepath.Join(t.TempDir(), "out"),
+		Branch:        "HEAD",
+		Quiet:         true,
+		WatchInterval: 10 * time.Millisecond,
+	}
+	rep, err := Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	commitFile(t, repo.Path, "new.txt", "Add new file")
+
+	stderr = io.Discard
+	var probes []*finishProbe
+	newProgress = func(pcfg progress.Config) progress.Reporter {
+		buf := new(bytes.Buffer)
+		pcfg.Writer = buf
+		probe := &finishProbe{Reporter: progress.New(pcfg), buf: buf}
+		probes = append(probes, probe)
+		return probe
+	}
+	t.Cleanup(func() {
+		stderr = os.Stderr
+		newProgress = progress.New
+	})
+
+	// The poll numbers its commit after the first one; its verbose line is
+	// printed as soon as it completes, not held back until Finish
+	cfg.Quiet = false
+	cfg.Verbose = true
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := runWatch(ctx, cfg, rep); err != nil {
+		t.Fatalf("runWatch failed: %v", err)
+	}
+
+	if len(probes) != 1 || len(probes[0].beforeFinish) != 1 {
+		t.Fatalf("expected one finished poll reporter, got %d", len(probes))
+	}
+	if out := probes[0].beforeFinish[0]; !strings.Contains(out, "✓") {
+		t.Errorf("expected the verbose line before Finish, got %q", out)
+	}
+}
+
+func TestRunPartialFailureExitCode(t *testing.T) {
+	// The extractor's error for a run in which one of three commits failed;
+	// TestRunPartialFailure in the extractor forces it with a fake repository
+	failed := fmt.Errorf("1 of 3 %w: %w", extractor.ErrCommitsFailed, errors.New("boom"))
+	partial := &Report{Succeeded: 2, Failed: 1}
+	tests := []struct {
+		name string
+		cfg  Config
+		rep  *Report
+		want int
+	}{
+		{"default", Config{}, partial, ExitPartial},
+		{"fail on any", Config{FailOnAny: true}, partial, ExitFatal},
+		{"allow partial", Config{AllowPartial: true}, partial, ExitOK},
+		{"all failed", Config{AllowPartial: true}, &Report{Failed: 3}, ExitFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.partialFailure(tt.rep, failed)
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("expected exit code %d, got %d (error: %v)", tt.want, got, err)
+			}
+		})
+	}
+
+	// Joined per-repository errors are partial only when each one is
+	if got := ExitCode(errors.Join(&PartialFailureError{Err: failed}, &PartialFailureError{Err: failed})); got != ExitPartial {
+		t.Errorf("expected a partial exit code for joined partial failures, got %d", got)
+	}
+	if got := ExitCode(errors.Join(&PartialFailureError{Err: failed}, errors.New("failed to open repository"))); got != ExitFatal {
+		t.Errorf("expected a fatal exit code when any repository failed, got %d", got)
+	}
+	if got := ExitCode(fmt.Errorf("failed to open repository")); got != ExitFatal {
+		t.Errorf("expected a fatal exit code for other errors, got %d", got)
+	}
+}
+
+func TestExtractMergeBase(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 2)
+	ctx := context.Background()
+	base, err := repo.ResolveRef(ctx, "main")
+	if err != nil {
+		t.Fatalf("failed to resolve main: %v", err)
+	}
+	// Move main past the fork point, which must not be taken as the base
+	cmd := exec.Command("git", "checkout", "-q", "main")
+	cmd.Dir = repo.Path
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git checkout failed: %v\nOutput: %s", err, out)
+	}
+	commitFile(t, repo.Path, "later.txt", "Later on main")
+
+	rep, err := Extract(ctx, Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		MergeBase: "feature/0,feature/1",
+		Quiet:     true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(rep.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(rep.Results))
+	}
+	for i, want := range []string{base, "feature/0", "feature/1"} {
+		hash, err := repo.ResolveRef(ctx, want)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", want, err)
+		}
+		role := []string{"base", "tipA", "tipB"}[i]
+		r := rep.Results[i]
+		if r.Commit.Hash != hash {
+			t.Errorf("expected %s to be %s, got %s", role, hash, r.Commit.Hash)
+		}
+		if name := filepath.Base(r.OutputPath); !strings.HasPrefix(name, role+"_") {
+			t.Errorf("expected the %s folder to be labeled, got %s", role, name)
+		}
+	}
+
+	diff, err := os.ReadFile(filepath.Join(rep.OutputDir, "diffs", "tipA-to-tipB.diff"))
+	if err != nil {
+		t.Fatalf("failed to read diff: %v", err)
+	}
+	if !strings.Contains(string(diff), "b0_c1.txt") || !strings.Contains(string(diff), "b1_c1.txt") {
+		t.Errorf("expected the tip diff to cover both branches, got:\n%s", diff)
+	}
+	for _, name := range []string{"base-to-tipA.diff", "base-to-tipB.diff"} {
+		if _, err := os.Stat(filepath.Join(rep.OutputDir, "diffs", name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+}
+
+func TestSelectBranchesOrder(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	for _, b := range []struct {
+		name string
+		date time.Time
+	}{
+		{"a-late", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"b-early", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"c-mid", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		for _, args := range [][]string{{"checkout", "-q", "-b", b.name, "main"}, {"commit", "-q", "--allow-empty", "-m", b.name}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo.Path
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+b.date.Format(time.RFC3339))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+			}
+		}
+	}
+
+	// main holds only the initial commit, authored now and an ancestor of
+	// every other branch
+	tests := map[string][]string{
+		"":              {"a-late", "b-early", "c-mid", "main"},
+		BranchOrderDate: {"b-early", "c-mid", "a-late", "main"},
+		BranchOrderTopo: {"main", "a-late", "b-early", "c-mid"},
+	}
+	for order, want := range tests {
+		cfg := Config{RepoPath: repo.Path, BranchOrder: order, Quiet: true}
+		if err := cfg.validate(); err != nil {
+			t.Fatalf("validate failed for %q: %v", order, err)
+		}
+		branches, err := selectBranches(context.Background(), repo, cfg)
+		if err != nil {
+			t.Fatalf("selectBranches failed for %q: %v", order, err)
+		}
+		if !slices.Equal(branches, want) {
+			t.Errorf("order %q: expected %v, got %v", order, want, branches)
+		}
+	}
+
+	if err := (Config{RepoPath: repo.Path, BranchOrder: "size"}).validate(); err == nil {
+		t.Error("expected error for an unknown branch order, got nil")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	commitFile(t, repo.Path, "notes.txt", "Add notes")
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		Branch:    "main",
+		Checksums: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	cfg := Config{RepoPath: repo.Path, Verify: rep.OutputDir, Quiet: true}
+	var out bytes.Buffer
+	if err := runVerify(cfg, &out); err != nil {
+		t.Fatalf("expected an intact extraction to verify, got %v\n%s", err, out.String())
+	}
+
+	corrupted := rep.Results[1].OutputPath
+	if err := os.WriteFile(filepath.Join(corrupted, "notes.txt"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("failed to corrupt file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(corrupted, "README")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	out.Reset()
+	err = runVerify(cfg, &out)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 folders") {
+		t.Fatalf("expected one mismatched folder, got %v", err)
+	}
+	for _, want := range []string{filepath.Base(corrupted), "modified: notes.txt", "missing: README"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the report, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), filepath.Base(rep.Results[0].OutputPath)) {
+		t.Errorf("expected the intact folder not to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestExtractIgnoreRevsFile(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	commitFile(t, repo.Path, "reformat.txt", "Reformat\neverything\nat\nonce")
+	commitFile(t, repo.Path, "feature.txt", "Add feature")
+	reformat, err := repo.ResolveRef(context.Background(), "HEAD~1")
+	if err != nil {
+		t.Fatalf("failed to resolve reformat commit: %v", err)
+	}
+
+	revsFile := filepath.Join(t.TempDir(), ".git-blame-ignore-revs")
+	if err := os.WriteFile(revsFile, []byte("# Bulk reformat\n"+reformat[:12]+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write ignore-revs file: %v", err)
+	}
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:       repo.Path,
+		OutputDir:      filepath.Join(t.TempDir(), "out"),
+		Branch:         "main",
+		Churn:          true,
+		IgnoreRevsFile: revsFile,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(rep.Results) != 3 {
+		t.Fatalf("expected the ignored commit to be extracted, got %d results", len(rep.Results))
+	}
+
+	f, err := os.Open(filepath.Join(rep.OutputDir, report.ChurnFileName))
+	if err != nil {
+		t.Fatalf("failed to open churn series: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read churn series: %v", err)
+	}
+	// The reformat's 4 lines must not be counted
+	if insertions := records[len(records)-1][7]; insertions != "2" {
+		t.Errorf("expected 2 cumulative insertions, got %s: %v", insertions, records)
+	}
+}
+
+func TestExtractOutTemplate(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 1, 1)
+	root := t.TempDir()
+
+	cfg := Config{
+		RepoPath:    repo.Path,
+		OutTemplate: filepath.Join(root, "archive", "{{.Repo}}", "{{.Branch}}", "{{.Date}}"),
+		Branch:      "feature/0",
+	}
+	rep, err := Extract(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	want := filepath.Join(root, "archive", repoName(repo.Path), "feature_0", time.Now().Format(time.DateOnly))
+	if rep.OutputDir != want {
+		t.Errorf("expected output directory %s, got %s", want, rep.OutputDir)
+	}
+	if entries, err := os.ReadDir(want); err != nil || len(entries) == 0 {
+		t.Errorf("expected commits extracted into %s: %v", want, err)
+	}
+
+	if _, err := Extract(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected the rendered directory to be rejected as existing, got %v", err)
+	}
+
+	cfg.OutTemplate = "{{.Missing}}"
+	if _, err := Extract(context.Background(), cfg); err == nil {
+		t.Error("expected an unknown template field to fail")
+	}
+}
+
+func TestExtractFromStdin(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	commitFile(t, repo.Path, "a.txt", "Add a")
+	commitFile(t, repo.Path, "b.txt", "Add b")
+	commitFile(t, repo.Path, "c.txt", "Add c")
+
+	ctx := context.Background()
+	first, err := repo.ResolveRef(ctx, "HEAD~2")
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD~2: %v", err)
+	}
+	last, err := repo.ResolveRef(ctx, "HEAD")
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+
+	// A bare hash, a --oneline line and a line naming no commit
+	stdin = strings.NewReader(last + "\n" + first[:7] + " Add a\n\nnot-a-commit\n")
+	t.Cleanup(func() { stdin = os.Stdin })
+
+	rep, err := Extract(ctx, Config{
+		RepoPath:  repo.Path,
+		OutputDir: filepath.Join(t.TempDir(), "out"),
+		FromStdin: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(rep.Results) != 2 {
+		t.Fatalf("expected the 2 piped commits to be extracted, got %d", len(rep.Results))
+	}
+	got := map[string]bool{}
+	for _, r := range rep.Results {
+		if r.Error != nil {
+			t.Errorf("extraction of %s failed: %v", r.Commit.ShortHash, r.Error)
+		}
+		got[r.Commit.Hash] = true
+	}
+	if !got[first] || !got[last] {
+		t.Errorf("expected %s and %s to be extracted, got %v", first, last, got)
+	}
+}
+
+func TestExtractInventory(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 2, 2)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	rep, err := Extract(context.Background(), Config{
+		RepoPath:  repo.Path,
+		OutputDir: outDir,
+		Workers:   4,
+		Inventory: true,
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(outDir, report.InventoryFileName))
+	if err != nil {
+		t.Fatalf("failed to open inventory: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read inventory: %v", err)
+	}
+	// main and both feature branches, each with the initial commit
+	if rows := len(records) - 1; rows != 7 || rows != len(rep.Results) {
+		t.Fatalf("expected 7 inventory rows, got %d for %d results", rows, len(rep.Results))
+	}
+	message := slices.Index(records[0], "message")
+	filesChanged := slices.Index(records[0], "files_changed")
+	if message < 0 || filesChanged < 0 {
+		t.Fatalf("expected message and files_changed columns, got %v", records[0])
+	}
+	for _, record := range records[1:] {
+		if record[message] == "" || record[filesChanged] == "0" {
+			t.Errorf("expected message and stats in the inventory, got %v", record)
+		}
+	}
+
+	// Nothing but the inventory and the run summary is written
+	err = filepath.WalkDir(outDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != outDir {
+			t.Errorf("expected no folders in inventory mode, found %s", path)
+		}
+		if name := d.Name(); !d.IsDir() && name != report.InventoryFileName && name != report.SummaryFileName {
+			t.Errorf("unexpected file %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk output: %v", err)
+	}
+
+	// Options that shape the commit folders are rejected
+	for flag, cfg := range map[string]Config{
+		"--html":              {HTML: true},
+		"--compare":           {Compare: true},
+		"--reproducible":      {Reproducible: true},
+		"--include":           {Include: []string{"*.go"}},
+		"--exclude":           {Exclude: []string{"vendor/"}},
+		"--max-file-size":     {MaxFileSize: 1024},
+		"--filter-file":       {FilterFile: "ignore.txt"},
+		"--folder-template":   {FolderTemplate: "{{.Index}}"},
+		"--flatten-symlinks":  {FlattenSymlinks: true},
+		"--no-preserve-perms": {NoPreservePerms: true},
+	} {
+		cfg.RepoPath = repo.Path
+		cfg.Inventory = true
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "--inventory writes no commit folders") {
+			t.Errorf("expected %s to be rejected with --inventory, got %v", flag, err)
+		}
+	}
+}
+
+func TestExtractSetFileTimes(t *testing.T) {
+	repo := setupMultiBranchRepo(t, 0, 0)
+	oldDate := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newDate := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	for _, c := range []struct {
+		name string
+		date time.Time
+	}{{"old.txt", oldDate}, {"new.txt", newDate}} {
+		if err := os.WriteFile(filepath.Join(repo.Path, c.name), []byte(c.name), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", c.name, err)
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Add " + c.name}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo.Path
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+c.date.Format(time.RFC3339))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+			}
+		}
+	}
+
+	extract := func(maxFiles int) *Report {
+		rep, err := Extract(context.Background(), Config{
+			RepoPath:          repo.Path,
+			OutputDir:         filepath.Join(t.TempDir(), "out"),
+			Commit:            "HEAD",
+			SetFileTimes:      true,
+			FileTimesMaxFiles: maxFiles,
+			// Files the command rewrites are dated all the same
+			Exec: "touch old.txt new.txt",
+		})
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		return rep
+	}
+
+	rep := extract(0)
+	folder := rep.Results[0].OutputPath
+	// old.txt keeps the date of the commit that added it, not of HEAD
+	for name, want := range map[string]time.Time{"old.txt": oldDate, "new.txt": newDate} {
+		info, err := os.Stat(filepath.Join(folder, name))
+		if err != nil {
+			t.Fatalf("failed to stat %s: %v", name, err)
+		}
+		if !info.ModTime().Equal(want) {
+			t.Errorf("expected %s dated %s, got %s", name, want, info.ModTime())
+		}
+	}
+
+	if rep := extract(1); rep.FileTimesSkipped != 1 {
+		t.Errorf("expected the commit over the file limit to be reported, got %d", rep.FileTimesSkipped)
+	}
+}
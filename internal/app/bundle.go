@@ -0,0 +1,210 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Bundle formats accepted by Config.Bundle
+const (
+	BundleTar    = "tar"
+	BundleTarZst = "tar.zst"
+	BundleZip    = "zip"
+)
+
+// bundleFormats lists the valid Config.Bundle values
+var bundleFormats = []string{BundleTar, BundleTarZst, BundleZip}
+
+// archiveEntryFunc adds the file at path to an archive under name
+type archiveEntryFunc func(path, name string, info fs.FileInfo) error
+
+// writeBundle archives the output directory into <outDir>.<format>, its
+// entries rooted at the directory's base name, and returns the archive
+// path. The archive is streamed to disk. With remove, each top-level entry
+// is deleted once archived, and the output directory once it is empty, so
+// that the expanded tree and the archive never coexist in full.
+func writeBundle(outDir, format string, remove bool) (bundlePath string, err error) {
+	bundlePath = outDir + "." + format
+	f, err := os.OpenFile(bundlePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	// A partial bundle is only kept when part of the tree is already gone
+	removed := false
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close bundle: %w", closeErr)
+		}
+		if err != nil && !removed {
+			_ = os.Remove(bundlePath)
+		}
+	}()
+
+	add, closeArchive, err := newArchive(f, format)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output directory: %w", err)
+	}
+	parent := filepath.Dir(outDir)
+	addTree := func(root string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(parent, path)
+			if err != nil {
+				return err
+			}
+			return add(path, filepath.ToSlash(rel), info)
+		})
+	}
+
+	info, err := os.Stat(outDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output directory: %w", err)
+	}
+	if err := add(outDir, filepath.Base(outDir), info); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(outDir, entry.Name())
+		if err := addTree(path); err != nil {
+			return "", fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if remove {
+			removed = true
+			if err := os.RemoveAll(path); err != nil {
+				return "", fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+
+	if err := closeArchive(); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if remove {
+		if err := os.Remove(outDir); err != nil {
+			return "", fmt.Errorf("failed to remove output directory: %w", err)
+		}
+	}
+	return bundlePath, nil
+}
+
+// newArchive returns functions adding entries to an archive of the given
+// format written to w, and flushing it
+func newArchive(w io.Writer, format string) (archiveEntryFunc, func() error, error) {
+	switch format {
+	case BundleZip:
+		zw := zip.NewWriter(w)
+		return func(path, name string, info fs.FileInfo) error {
+			return addZipEntry(zw, path, name, info)
+		}, zw.Close, nil
+
+	case BundleTar, BundleTarZst:
+		var zw *zstd.Encoder
+		if format == BundleTarZst {
+			var err error
+			if zw, err = zstd.NewWriter(w); err != nil {
+				return nil, nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+			}
+			w = zw
+		}
+		tw := tar.NewWriter(w)
+		closeArchive := func() error {
+			err := tw.Close()
+			if zw != nil {
+				err = errors.Join(err, zw.Close())
+			}
+			return err
+		}
+		return func(path, name string, info fs.FileInfo) error {
+			return addTarEntry(tw, path, name, info)
+		}, closeArchive, nil
+	}
+	return nil, nil, fmt.Errorf("unknown bundle format %q", format)
+}
+
+// addTarEntry writes a file, directory or symlink to a tar archive
+func addTarEntry(tw *tar.Writer, path, name string, info fs.FileInfo) error {
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return copyFile(tw, path)
+}
+
+// addZipEntry writes a file, directory or symlink to a zip archive.
+// Symlinks are stored with their target as content, as zip tools expect.
+func addZipEntry(zw *zip.Writer, path, name string, info fs.FileInfo) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	} else if info.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, link)
+		return err
+	case info.Mode().IsRegular():
+		return copyFile(w, path)
+	}
+	return nil
+}
+
+// copyFile copies the content of the file at path to w
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(w, f)
+	return err
+}
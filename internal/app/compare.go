@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// writeCompareDiffs writes a NNNN-to-NNNN.diff file into the diffs folder
+// for each pair of consecutive extracted commits, from the older to the
+// newer one. Commits of different branches are never compared; in
+// all-branches mode each branch gets its own subfolder.
+func writeCompareDiffs(ctx context.Context, repo *git.Repository, results []extractor.Result, outDir string, cfg Config) error {
+	var branches []string
+	byBranch := make(map[string][]extractor.Result)
+	for _, r := range results {
+		if r.Error != nil || r.Skipped {
+			continue
+		}
+		if _, ok := byBranch[r.Branch]; !ok {
+			branches = append(branches, r.Branch)
+		}
+		byBranch[r.Branch] = append(byBranch[r.Branch], r)
+	}
+
+	allBranches := cfg.Branch == "" && cfg.Commit == "" && !cfg.Reflog && cfg.Refs == "" && !cfg.Review && !cfg.Tags && !cfg.listsCommits() && cfg.MergeBase == ""
+	for _, branch := range branches {
+		commits := byBranch[branch]
+		if len(commits) < 2 {
+			continue
+		}
+		slices.SortFunc(commits, func(a, b extractor.Result) int { return a.Index - b.Index })
+
+		diffsDir := filepath.Join(outDir, config.DiffsDirName)
+		if allBranches {
+			diffsDir = filepath.Join(diffsDir, sanitizeBranchName(branch))
+		}
+		if err := os.MkdirAll(diffsDir, config.OutputDirPerms); err != nil {
+			return fmt.Errorf("failed to create diffs directory: %w", err)
+		}
+
+		for i := 1; i < len(commits); i++ {
+			older, newer := commits[i-1], commits[i]
+			if cfg.NewestFirst {
+				older, newer = newer, older
+			}
+			name := fmt.Sprintf("%04d-to-%04d.diff", older.Index, newer.Index)
+			if err := repo.WriteDiff(ctx, older.Commit.Hash, newer.Commit.Hash, filepath.Join(diffsDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"context"
+	"os"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/andpalmier/repopsy/internal/report"
+)
+
+// writeDuplicateTrees looks up the tree of every extracted commit and
+// writes duplicate_trees.txt, grouping the distinct commits that share a
+// tree. It returns the number of such trees.
+func writeDuplicateTrees(ctx context.Context, repo *git.Repository, results []extractor.Result, outDir string) (int, error) {
+	if _, err := os.Stat(outDir); err != nil {
+		// Nothing was extracted
+		return 0, nil
+	}
+
+	trees := make(map[string]string)
+	for _, r := range results {
+		if r.Error != nil || r.Skipped {
+			continue
+		}
+		if _, ok := trees[r.Commit.Hash]; ok {
+			continue
+		}
+		tree, err := repo.GetTreeHash(ctx, r.Commit.Hash)
+		if err != nil {
+			return 0, err
+		}
+		trees[r.Commit.Hash] = tree
+	}
+
+	groups := report.DuplicateTrees(results, trees)
+	return len(groups), report.WriteDuplicateTreesFile(outDir, groups)
+}
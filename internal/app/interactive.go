@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// stdin supplies answers to interactive prompts; tests replace it
+var stdin io.Reader = os.Stdin
+
+// promptBranches lists the branches with their commit counts on w and reads
+// the user's selection from r, asking again until the answer is valid
+func promptBranches(ctx context.Context, repo *git.Repository, cfg Config, branches []string, r io.Reader, w io.Writer) ([]string, error) {
+	fmt.Fprintf(w, "Found %d branches:\n", len(branches))
+	for i, branch := range branches {
+		count, err := repo.CountCommits(ctx, cfg.listOptions(branch))
+		if err != nil {
+			fmt.Fprintf(w, "  %3d) %s (unknown commits)\n", i+1, branch)
+			continue
+		}
+		fmt.Fprintf(w, "  %3d) %s (%d commits)\n", i+1, branch, count)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, "Select branches to extract (e.g. 1,3-5; empty for all): ")
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("failed to read branch selection: %w", err)
+			}
+			return nil, fmt.Errorf("no branch selection made")
+		}
+
+		selected, err := parseSelection(scanner.Text(), len(branches))
+		if err != nil {
+			fmt.Fprintf(w, "Invalid selection: %v\n", err)
+			continue
+		}
+		if selected == nil {
+			return branches, nil
+		}
+
+		chosen := make([]string, 0, len(selected))
+		for _, i := range selected {
+			chosen = append(chosen, branches[i])
+		}
+		return chosen, nil
+	}
+}
+
+// parseSelection parses a comma-separated list of 1-based numbers and
+// ranges such as "1,3-5" into sorted, distinct 0-based indexes below n.
+// An empty or "all" answer selects everything and yields nil.
+func parseSelection(answer string, n int) ([]int, error) {
+	answer = strings.TrimSpace(answer)
+	if answer == "" || strings.EqualFold(answer, "all") {
+		return nil, nil
+	}
+
+	chosen := make([]bool, n)
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("%q is not a range", part)
+			}
+		}
+		if first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("%q is outside 1-%d", part, n)
+		}
+		for i := first; i <= last; i++ {
+			chosen[i-1] = true
+		}
+	}
+
+	var selected []int
+	for i, ok := range chosen {
+		if ok {
+			selected = append(selected, i)
+		}
+	}
+	return selected, nil
+}
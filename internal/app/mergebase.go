@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// Roles of the commits extracted in merge-base mode, prefixing their folders
+const (
+	roleBase = "base"
+	roleTipA = "tipA"
+	roleTipB = "tipB"
+)
+
+// listMergeBase returns the merge base of the two cfg.MergeBase branches
+// followed by their tips, each labeled with its role
+func listMergeBase(ctx context.Context, repo *git.Repository, cfg Config) ([]git.Commit, error) {
+	branches := splitPatterns(cfg.MergeBase)
+	base, err := repo.MergeBase(ctx, branches[0], branches[1])
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []struct{ ref, role string }{
+		{base, roleBase},
+		{branches[0], roleTipA},
+		{branches[1], roleTipB},
+	}
+	commits := make([]git.Commit, 0, len(refs))
+	for _, r := range refs {
+		commit, err := repo.ResolveCommit(ctx, r.ref)
+		if err != nil {
+			return nil, err
+		}
+		commit.Role = r.role
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// runMergeBase extracts the merge base of the two cfg.MergeBase branches and
+// their tips, then writes the diffs between the three into the diffs folder
+func runMergeBase(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commits, err := listMergeBase(ctx, repo, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(cfg.out(), "Merge base %s\n\n", commits[0].ShortHash)
+
+	results, err := extractCommits(ctx, repo, outDir, cfg, commits)
+	if ctx.Err() != nil {
+		return results, err
+	}
+	if diffErr := writeMergeBaseDiffs(ctx, repo, commits, outDir); diffErr != nil && err == nil {
+		err = diffErr
+	}
+	return results, err
+}
+
+// writeMergeBaseDiffs writes base-to-tipA.diff, base-to-tipB.diff and
+// tipA-to-tipB.diff into the diffs folder
+func writeMergeBaseDiffs(ctx context.Context, repo *git.Repository, commits []git.Commit, outDir string) error {
+	diffsDir := filepath.Join(outDir, config.DiffsDirName)
+	if err := os.MkdirAll(diffsDir, config.OutputDirPerms); err != nil {
+		return fmt.Errorf("failed to create diffs directory: %w", err)
+	}
+
+	base, tipA, tipB := commits[0], commits[1], commits[2]
+	for _, pair := range [][2]git.Commit{{base, tipA}, {base, tipB}, {tipA, tipB}} {
+		from, to := pair[0], pair[1]
+		name := fmt.Sprintf("%s-to-%s.diff", from.Role, to.Role)
+		if err := repo.WriteDiff(ctx, from.Hash, to.Hash, filepath.Join(diffsDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// repoOutcome is the result of one repository in a multi-repository run
+type repoOutcome struct {
+	Name   string
+	Report *Report
+	Err    error
+}
+
+// RunRepositories runs cfg against each repository in turn. With an output
+// directory set, each repository is extracted into <OutputDir>/<repo-name>;
+// otherwise each gets its default <repo-name>-exploded directory. A failing
+// repository does not stop the others: their errors are joined and a
+// combined summary is printed at the end.
+func RunRepositories(ctx context.Context, cfg Config, repoPaths []string) error {
+	if len(repoPaths) == 1 {
+		cfg.RepoPath = repoPaths[0]
+		return Run(ctx, cfg)
+	}
+	if cfg.Stdout {
+		return fmt.Errorf("--stdout cannot be used with multiple repositories")
+	}
+	if cfg.CSVPath != "" {
+		return fmt.Errorf("--csv cannot be used with multiple repositories")
+	}
+	if cfg.Watch {
+		return fmt.Errorf("--watch cannot be used with multiple repositories")
+	}
+	if cfg.Verify != "" {
+		return fmt.Errorf("--verify cannot be used with multiple repositories")
+	}
+	if cfg.FromStdin {
+		// stdin can only be read once
+		return fmt.Errorf("--from-stdin cannot be used with multiple repositories")
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	names := repoNames(repoPaths)
+	outcomes := make([]repoOutcome, 0, len(repoPaths))
+	var errs []error
+	for i, repoPath := range repoPaths {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		repoCfg := cfg
+		repoCfg.RepoPath = repoPath
+		if cfg.OutputDir != "" {
+			repoCfg.OutputDir = filepath.Join(cfg.OutputDir, names[i])
+		}
+
+		rep, err := run(ctx, repoCfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", repoPath, err))
+		}
+		outcomes = append(outcomes, repoOutcome{Name: names[i], Report: rep, Err: err})
+	}
+
+	if !cfg.List {
+		printRepositoriesSummary(cfg, outcomes)
+	}
+	return errors.Join(errs...)
+}
+
+// repoNames returns a unique output directory name for each repository,
+// appending _2, _3, ... to repeated names
+func repoNames(repoPaths []string) []string {
+	seen := make(map[string]bool)
+	names := make([]string, len(repoPaths))
+	for i, repoPath := range repoPaths {
+		name := repoName(repoPath)
+		claimed := name
+		for n := 2; seen[claimed]; n++ {
+			claimed = fmt.Sprintf("%s_%d", name, n)
+		}
+		seen[claimed] = true
+		names[i] = claimed
+	}
+	return names
+}
+
+// repoName returns the name of the repository at repoPath, dropping the
+// .git suffix of bare repositories and .git directories
+func repoName(repoPath string) string {
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		repoPath = abs
+	}
+	if filepath.Base(repoPath) == ".git" {
+		repoPath = filepath.Dir(repoPath)
+	}
+	return strings.TrimSuffix(filepath.Base(repoPath), ".git")
+}
+
+// printRepositoriesSummary prints the combined summary of a multi-repository run
+func printRepositoriesSummary(cfg Config, outcomes []repoOutcome) {
+	var failed int
+	for _, o := range outcomes {
+		if o.Err != nil {
+			failed++
+		}
+	}
+
+	if cfg.Quiet {
+		fmt.Fprintf(stderr, "%d repositories: %d succeeded, %d failed\n", len(outcomes), len(outcomes)-failed, failed)
+		return
+	}
+
+	fmt.Fprintln(cfg.out(), "")
+	writeRepositoriesTable(cfg.out(), outcomes)
+}
+
+// writeRepositoriesTable writes one row of commit counts per repository
+func writeRepositoriesTable(w io.Writer, outcomes []repoOutcome) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tSUCCEEDED\tFAILED\tSTATUS")
+	var succeeded, failed int
+	for _, o := range outcomes {
+		var ok, bad int
+		if o.Report != nil {
+			ok, bad = o.Report.Succeeded, o.Report.Failed
+		}
+		succeeded += ok
+		failed += bad
+
+		status := "ok"
+		if o.Err != nil {
+			// Joined extraction errors span several lines; keep the first
+			status = "error: " + strings.SplitN(o.Err.Error(), "\n", 2)[0]
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", o.Name, ok, bad, status)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t\n", succeeded, failed)
+	_ = tw.Flush()
+}
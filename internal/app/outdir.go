@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// outTemplateData is the data exposed to --out-template
+type outTemplateData struct {
+	Repo   string    // Repository directory name, without .git
+	Branch string    // Branch extracted, or checked out, made path-safe
+	Date   string    // Day of the run as 2006-01-02
+	Time   time.Time // Time of the run, for custom layouts with date
+	Remote string    // Host and path of the remote, empty without one
+}
+
+// outTemplateFuncs are the helper functions available in --out-template
+var outTemplateFuncs = template.FuncMap{
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+}
+
+// renderOutputDir renders cfg.OutTemplate for repo and creates the parents of
+// the resulting directory, leaving the directory itself to the extraction
+func renderOutputDir(ctx context.Context, repo *git.Repository, cfg Config) (string, error) {
+	tmpl, err := template.New("out").Funcs(outTemplateFuncs).Option("missingkey=error").Parse(cfg.OutTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid output template: %w", err)
+	}
+
+	branch := cfg.Branch
+	if branch == "" {
+		if branch, err = repo.CurrentBranch(ctx); err != nil {
+			return "", err
+		}
+	}
+	remote, err := repo.RemoteURL(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	data := outTemplateData{
+		Repo:   repoName(repo.Path),
+		Branch: sanitizeBranchName(branch),
+		Date:   now.Format(time.DateOnly),
+		Time:   now,
+		Remote: remotePath(remote),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render output template: %w", err)
+	}
+	outDir := buf.String()
+	if err := validateOutputDir(outDir); err != nil {
+		return "", fmt.Errorf("output template rendered %q: %w", outDir, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filepath.Clean(outDir)), config.OutputDirPerms); err != nil {
+		return "", fmt.Errorf("failed to create parent of output directory: %w", err)
+	}
+	return outDir, nil
+}
+
+// validateOutputDir rejects rendered paths that are empty, end in an empty
+// field, or hold control characters
+func validateOutputDir(outDir string) error {
+	if strings.TrimSpace(outDir) == "" {
+		return fmt.Errorf("empty path")
+	}
+	if strings.ContainsFunc(outDir, unicode.IsControl) {
+		return fmt.Errorf("path contains control characters")
+	}
+	if base := filepath.Base(filepath.Clean(outDir)); base == "." || base == ".." || base == string(filepath.Separator) {
+		return fmt.Errorf("path does not name a directory")
+	}
+	return nil
+}
+
+// remotePath reduces a remote URL to its host and path, such as
+// github.com/owner/repo, dropping the scheme, user and .git suffix
+func remotePath(remote string) string {
+	if remote == "" {
+		return ""
+	}
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		remote = u.Host + u.Path
+	} else if host, path, ok := strings.Cut(remote, ":"); ok && !strings.Contains(host, "/") {
+		// scp-like syntax: user@host:owner/repo
+		if _, h, found := strings.Cut(host, "@"); found {
+			host = h
+		}
+		remote = host + "/" + path
+	}
+	return strings.TrimSuffix(strings.Trim(remote, "/"), ".git")
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// Report summarizes an extraction run for programmatic use
+type Report struct {
+	// RepoPath is the absolute path of the extracted repository
+	RepoPath string
+
+	// OutputDir is the absolute path of the output directory
+	OutputDir string
+
+	// Results holds one entry per processed commit, ordered by branch
+	Results []extractor.Result
+
+	// Branches breaks the counts down per branch, in the order of Results
+	Branches []BranchSummary
+
+	// Succeeded and Failed count the results without and with an error
+	Succeeded int
+	Failed    int
+
+	// Elapsed is the wall-clock duration of the run
+	Elapsed time.Duration
+
+	// Empty counts commits whose tree equals their parent's, including
+	// those skipped with Config.SkipEmpty
+	Empty int
+
+	// Untrusted counts extracted commits not signed by an allowed key
+	// (only with Config.AllowedKeys)
+	Untrusted int
+
+	// SkippedLarge counts files left out for exceeding Config.MaxFileSize,
+	// summed over all commits
+	SkippedLarge int
+
+	// LargeBinaries counts commits adding or modifying more binary bytes
+	// than Config.WarnBinaryBytes
+	LargeBinaries int
+
+	// LFSPointers counts Git LFS pointer files found with Config.LFS and
+	// LFSFetched those replaced with the real content, over all commits
+	LFSPointers int
+	LFSFetched  int
+
+	// UnresolvedSubmodules counts submodules, over all commits, whose
+	// commit was not available locally (only with Config.Submodules)
+	UnresolvedSubmodules int
+
+	// CASObjects counts the distinct files in the object store (only with
+	// Config.CAS)
+	CASObjects int
+
+	// XattrsSkipped counts commits extracted without extended attributes
+	// because the filesystem does not support them (only with
+	// Config.XattrMetadata)
+	XattrsSkipped int
+
+	// FileTimesSkipped counts commits whose files kept the extraction time
+	// for having too many of them (only with Config.SetFileTimes)
+	FileTimesSkipped int
+
+	// DuplicateTrees counts the trees shared by several distinct commits
+	// (only with Config.DuplicateTrees)
+	DuplicateTrees int
+
+	// ExecFailed counts commits whose Config.Exec command exited non-zero
+	ExecFailed int
+
+	// Bundle is the path of the archive of the output directory (only
+	// with Config.Bundle)
+	Bundle string
+
+	// statsResults holds the Results counted in the churn and author
+	// statistics, without the commits of Config.IgnoreRevsFile
+	statsResults []extractor.Result
+
+	// WorktreeFiles counts the files of the working tree snapshot
+	// (only with Config.IncludeWorktree)
+	WorktreeFiles int
+}
+
+// BranchSummary counts the results of a single branch
+type BranchSummary struct {
+	Branch    string
+	Commits   int
+	Succeeded int
+	Failed    int
+}
+
+// commitTimes returns the average time spent per processed commit and the
+// share of it spent on metadata
+func commitTimes(results []extractor.Result) (time.Duration, float64) {
+	var total, metadata time.Duration
+	var n int
+	for _, r := range results {
+		if r.Duration > 0 {
+			total += r.Duration
+			metadata += r.MetadataDuration
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return total / time.Duration(n), float64(metadata) / float64(total)
+}
+
+// newReport builds a report from the extraction results
+func newReport(repoPath, outDir string, results []extractor.Result) *Report {
+	rep := &Report{
+		RepoPath:  repoPath,
+		OutputDir: outDir,
+		Results:   results,
+	}
+	branches := make(map[string]int)
+	for _, r := range results {
+		i, ok := branches[r.Branch]
+		if !ok {
+			i = len(rep.Branches)
+			branches[r.Branch] = i
+			rep.Branches = append(rep.Branches, BranchSummary{Branch: r.Branch})
+		}
+		rep.Branches[i].Commits++
+
+		if r.Error != nil {
+			rep.Failed++
+			rep.Branches[i].Failed++
+			continue
+		}
+		rep.Succeeded++
+		rep.Branches[i].Succeeded++
+		if r.Empty {
+			rep.Empty++
+		}
+		rep.SkippedLarge += r.SkippedLarge
+		if r.BinaryBytes > 0 {
+			rep.LargeBinaries++
+		}
+		rep.LFSPointers += r.LFSPointers
+		rep.LFSFetched += r.LFSFetched
+		rep.UnresolvedSubmodules += len(r.UnresolvedSubmodules)
+		rep.CASObjects += r.CASObjects
+		if r.XattrsSkipped {
+			rep.XattrsSkipped++
+		}
+		if r.FileTimesSkipped {
+			rep.FileTimesSkipped++
+		}
+		if r.Exec != nil && r.Exec.ExitCode != 0 {
+			rep.ExecFailed++
+		}
+		if trust := r.Commit.Trust; trust != "" && trust != git.TrustTrusted {
+			rep.Untrusted++
+		}
+	}
+	return rep
+}
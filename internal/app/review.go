@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/andpalmier/repopsy/internal/progress"
+)
+
+// reviewIndexName is the file linking the patches of a review series
+const reviewIndexName = "INDEX.md"
+
+// runReview writes the selected history, oldest first, into outDir as a
+// series of NNNN-shorthash-subject.patch files in format-patch form, with
+// an INDEX.md linking them, for reviewing a branch commit by commit.
+// Merge commits have no patch and are only listed in the index.
+func runReview(ctx context.Context, repo *git.Repository, outDir string, cfg Config) ([]extractor.Result, error) {
+	commits, err := repo.ListCommits(ctx, cfg.listOptions(cfg.Branch))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits found")
+	}
+
+	fmt.Fprintf(cfg.out(), "Found %d commits to review\n\n", len(commits))
+
+	if err := os.MkdirAll(outDir, config.OutputDirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Sequence numbers are padded to the same width so that names sort
+	width := max(4, len(strconv.Itoa(len(commits))))
+
+	reporter := cfg.newReporter(len(commits))
+	reporter.Start()
+	results := make([]extractor.Result, 0, len(commits))
+	for i, commit := range commits {
+		if ctx.Err() != nil {
+			reporter.Finish()
+			return results, ctx.Err()
+		}
+
+		r := extractor.Result{Commit: commit, Branch: cfg.Branch, Index: i}
+		message := "merge commit, no patch"
+		if len(commit.ParentHashes) < 2 {
+			r.OutputPath = filepath.Join(outDir, reviewPatchName(commit, i, width))
+			r.Error = writeReviewPatch(ctx, repo, commit.Hash, r.OutputPath)
+			message = filepath.Base(r.OutputPath)
+		}
+		results = append(results, r)
+		reporter.Increment(progress.Event{
+			Branch:  cfg.Branch,
+			Hash:    commit.ShortHash,
+			Index:   i,
+			Err:     r.Error,
+			Message: message,
+		})
+	}
+	reporter.Finish()
+
+	if err := writeReviewIndex(outDir, cfg, results); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// reviewPatchName returns the NNNN-shorthash-subject.patch name of the
+// commit at index, numbered from 1
+func reviewPatchName(commit git.Commit, index, width int) string {
+	name := fmt.Sprintf("%0*d-%s", width, index+1, commit.ShortHash)
+	if slug := extractor.Slugify(commit.Subject); slug != "" {
+		name += "-" + slug
+	}
+	return name + ".patch"
+}
+
+// writeReviewPatch writes the format-patch form of a commit to path
+func writeReviewPatch(ctx context.Context, repo *git.Repository, hash, path string) error {
+	patch, err := repo.GetFormatPatch(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(patch), 0o644); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	return nil
+}
+
+// writeReviewIndex writes INDEX.md, a table of the series linking each patch
+func writeReviewIndex(outDir string, cfg Config, results []extractor.Result) error {
+	var b strings.Builder
+	title := cfg.Branch
+	if title == "" {
+		title = cfg.Refs
+	}
+	if title == "" {
+		title = "HEAD"
+	}
+	fmt.Fprintf(&b, "# Review of %s\n\n", title)
+	fmt.Fprintf(&b, "%d commits, oldest first. Apply the series with `git am *.patch`.\n\n", len(results))
+	b.WriteString("| # | Commit | Author | Date | Subject |\n")
+	b.WriteString("|---|--------|--------|------|---------|\n")
+	for _, r := range results {
+		commit := r.Commit.ShortHash
+		subject := markdownCell(r.Commit.Subject)
+		switch {
+		case r.Error != nil:
+			subject += " (failed: " + markdownCell(r.Error.Error()) + ")"
+		case r.OutputPath == "":
+			subject += " (merge commit, no patch)"
+		default:
+			commit = fmt.Sprintf("[%s](%s)", commit, filepath.Base(r.OutputPath))
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s |\n", r.Index+1, commit,
+			markdownCell(r.Commit.Author), r.Commit.AuthorDate.Format("2006-01-02"), subject)
+	}
+
+	if err := os.WriteFile(filepath.Join(outDir, reviewIndexName), []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write review index: %w", err)
+	}
+	return nil
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
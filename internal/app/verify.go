@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/fatih/color"
+)
+
+// runVerify checks every commit folder under cfg.Verify holding a SHA256SUMS
+// manifest, printing the problems of each folder that no longer matches it
+// to w, and fails when any folder does not match
+func runVerify(cfg Config, w io.Writer) error {
+	if _, err := os.Stat(cfg.Verify); err != nil {
+		return fmt.Errorf("failed to access %s: %w", cfg.Verify, err)
+	}
+
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+
+	var checked, mismatched int
+	err := filepath.WalkDir(cfg.Verify, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(path, extractor.ChecksumsFileName)); err != nil {
+			return nil
+		}
+
+		problems, err := extractor.VerifyChecksums(path)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", path, err)
+		}
+		checked++
+		if len(problems) > 0 {
+			mismatched++
+			rel, _ := filepath.Rel(cfg.Verify, path)
+			fmt.Fprintf(w, "%s %s\n", red("✗"), rel)
+			for _, p := range problems {
+				fmt.Fprintf(w, "    %s\n", p)
+			}
+		}
+		// Files of the commit tree are covered by the folder's manifest
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case checked == 0:
+		return fmt.Errorf("no %s manifests found under %s: extract with --checksums to verify later", extractor.ChecksumsFileName, cfg.Verify)
+	case mismatched > 0:
+		return fmt.Errorf("%d of %d folders do not match their checksums", mismatched, checked)
+	}
+	fmt.Fprintf(cfg.out(), "%s %d folders verified\n", green("✓"), checked)
+	return nil
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/fatih/color"
+)
+
+// watcher extracts the commits added to cfg.Branch after an initial
+// extraction into the same output directory
+type watcher struct {
+	repo  *git.Repository
+	cfg   Config
+	start time.Time
+
+	// rep accumulates the results of the initial run and of every poll
+	rep *Report
+
+	// tip is the branch tip at the last poll, empty before the first one
+	tip string
+
+	// processed holds the commits already extracted, successfully or not
+	processed map[string]bool
+}
+
+// newWatcher returns a watcher continuing the extraction reported by rep
+func newWatcher(repo *git.Repository, cfg Config, rep *Report) *watcher {
+	processed := make(map[string]bool, len(rep.Results))
+	for _, r := range rep.Results {
+		processed[r.Commit.Hash] = true
+	}
+	return &watcher{repo: repo, cfg: cfg, start: time.Now(), rep: rep, processed: processed}
+}
+
+// poll extracts the commits of the branch not processed yet, when its tip
+// moved since the last poll. The new folders continue the numbering of the
+// earlier ones and the run reports are rewritten to cover all of them.
+func (w *watcher) poll(ctx context.Context) ([]extractor.Result, error) {
+	tip, err := w.repo.ResolveRef(ctx, w.cfg.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if tip == w.tip {
+		return nil, nil
+	}
+
+	listed, err := w.repo.ListCommits(ctx, w.cfg.listOptions(w.cfg.Branch))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+	w.tip = tip
+
+	var commits []git.Commit
+	for _, c := range listed {
+		if !w.processed[c.Hash] {
+			commits = append(commits, c)
+		}
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	cfg := w.cfg
+	cfg.firstIndex = len(w.rep.Results)
+	results, err := extractCommits(ctx, w.repo, w.rep.OutputDir, cfg, commits)
+	for _, r := range results {
+		w.processed[r.Commit.Hash] = true
+	}
+
+	all := append(w.rep.Results, results...)
+	rep := newReport(w.rep.RepoPath, w.rep.OutputDir, all)
+	rep.WorktreeFiles = w.rep.WorktreeFiles
+	w.rep = rep
+	if reportErr := writeReports(all, rep.OutputDir, cfg); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	if summaryErr := writeRunSummary(ctx, w.repo, rep, w.start, cfg); summaryErr != nil && err == nil {
+		err = summaryErr
+	}
+	return results, err
+}
+
+// runWatch polls cfg.Branch every cfg.WatchInterval after the extraction
+// reported by rep, extracting new commits as they appear, until ctx is
+// cancelled. A failed poll is reported and the next one tries again.
+func runWatch(ctx context.Context, cfg Config, rep *Report) error {
+	repo, err := cfg.openRepository()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = repo.Close() }()
+	if err := cfg.load(); err != nil {
+		return err
+	}
+	if err := cfg.resolveBaseline(ctx, repo); err != nil {
+		return err
+	}
+
+	interval := cfg.WatchInterval
+	if interval == 0 {
+		interval = config.DefaultWatchInterval
+	}
+	fmt.Fprintf(cfg.out(), "\nWatching %s for new commits every %s (Ctrl+C to stop)\n", cfg.Branch, interval)
+
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	w := newWatcher(repo, cfg, rep)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		results, err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			cfg.logger().Warn("watch poll failed", "error", err)
+			fmt.Fprintf(cfg.out(), "%s %v\n", yellow("⚠"), err)
+		}
+		if len(results) > 0 {
+			fmt.Fprintf(cfg.out(), "%s %d new commits extracted (%d in total)\n", green("➜"), len(results), len(w.rep.Results))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package colors decides whether repopsy colorizes its terminal output.
+// The banner, summary and progress bar all consult it.
+package colors
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// Configure disables colors for the rest of the run when noColor is set or
+// the NO_COLOR environment variable is non-empty (https://no-color.org).
+// Colors are already off when stdout is not a terminal.
+func Configure(noColor bool) {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		color.NoColor = true
+	}
+}
+
+// Enabled reports whether output may be colorized
+func Enabled() bool {
+	return !color.NoColor
+}
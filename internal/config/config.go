@@ -2,6 +2,8 @@
 // for the repopsy application.
 package config
 
+import "time"
+
 // Concurrency defaults and limits
 const (
 	// Default workers per branch
@@ -24,6 +26,33 @@ const (
 
 	// Minimum buffer size
 	MinBufferSize = 4096
+
+	// Longest git log line read when listing commits (64MB), which bounds
+	// the subject of a single commit
+	MaxLogLineSize = 64 * 1024 * 1024
+
+	// Git subprocesses running at once in nice mode, unless set explicitly
+	NiceGitProcs = 2
+
+	// Pause each worker takes between commits in nice mode, unless set explicitly
+	NiceJobDelay = 100 * time.Millisecond
+
+	// Interval between checks for new commits in watch mode
+	DefaultWatchInterval = 2 * time.Second
+
+	// Lines of each commit's diff kept in COMMIT_INFO.txt
+	DefaultMaxDiffLines = 1000
+
+	// Files in a commit tree above which file times are not set, each
+	// tree needing a walk of the history until all of its files are dated
+	DefaultFileTimesMaxFiles = 10000
+)
+
+// External tool requirements
+const (
+	// Oldest git release providing every option repopsy passes to git
+	// (--end-of-options arrived in 2.24)
+	MinGitVersion = "2.24.0"
 )
 
 // Output directory defaults
@@ -36,10 +65,34 @@ const (
 
 	// File permissions for test files
 	TestFilePerms = 0o600
+
+	// Folder receiving the working tree snapshot
+	WorktreeDirName = "working_tree"
+
+	// Folder receiving the diffs between consecutive commits
+	DiffsDirName = "diffs"
+
+	// Folder holding the content-addressable object store
+	CASDirName = "objects"
 )
 
 // Git constants
 const (
 	// Folder timestamp format
 	FolderTimestampFormat = "20060102_150405"
+
+	// Default folder name template (e.g., 20231205_143022_abc1234)
+	DefaultFolderTemplate = `{{date "` + FolderTimestampFormat + `" .Commit.AuthorDate}}_{{.Commit.ShortHash}}`
+
+	// Folder name template timestamped with the committer date, for --date-source=commit
+	CommitDateFolderTemplate = `{{date "` + FolderTimestampFormat + `" .Commit.CommitDate}}_{{.Commit.ShortHash}}`
+
+	// Prefix of the default folder names in reflog mode (e.g., HEAD@{3}_20231205_143022_abc1234)
+	ReflogFolderPrefix = `{{.Commit.ReflogSelector}}_`
+
+	// Prefix of the default folder names in tags mode (e.g., v1.2.0_20231205_143022_abc1234)
+	TagFolderPrefix = `{{.Commit.Tag}}_`
+
+	// Prefix of the default folder names in merge-base mode (e.g., base_20231205_143022_abc1234)
+	MergeBaseFolderPrefix = `{{.Commit.Role}}_`
 )
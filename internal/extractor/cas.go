@@ -0,0 +1,72 @@
+package extractor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// storeInCAS moves every regular file of the commit folder dir into the
+// content-addressable store under casDir, as objects/<2 hex>/<rest of the
+// SHA-256>, and replaces it with a relative symlink to its object. Files
+// with identical content share one object; executables get a separate
+// object with an "x" suffix since the mode is stored with the content.
+// Objects are made read-only because every commit linking to them would
+// see a change. The metadata file at the root of dir is left in place.
+func storeInCAS(dir, casDir string) (stored int, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		if path == filepath.Join(dir, "COMMIT_INFO.txt") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		name := hex.EncodeToString(sum)
+		mode := fs.FileMode(0o444)
+		if info.Mode()&0o111 != 0 {
+			name += "x"
+			mode = 0o555
+		}
+		object := filepath.Join(casDir, name[:2], name[2:])
+
+		if err := os.MkdirAll(filepath.Dir(object), 0o755); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+		// Linking fails when the object exists, also when a concurrent
+		// worker stored the same content first
+		switch err := os.Link(path, object); {
+		case err == nil:
+			stored++
+		case !errors.Is(err, fs.ErrExist):
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		target, err := filepath.Rel(filepath.Dir(path), object)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, path)
+	})
+	if err != nil {
+		return stored, fmt.Errorf("failed to move files into the object store: %w", err)
+	}
+	return stored, nil
+}
@@ -0,0 +1,140 @@
+package extractor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumsFileName is the manifest written into each commit folder with Checksums
+const ChecksumsFileName = "SHA256SUMS"
+
+// writeChecksums writes a SHA256SUMS manifest of every regular file under dir
+// in the format understood by sha256sum -c. The manifest itself is excluded;
+// COMMIT_INFO.txt and other reports are included so the whole folder verifies.
+func writeChecksums(dir string) error {
+	var buf bytes.Buffer
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ChecksumsFileName {
+			return nil
+		}
+
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(checksumLine(sum, rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compute checksums: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ChecksumsFileName), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write checksums file: %w", err)
+	}
+	return nil
+}
+
+// hashFile returns the SHA-256 digest of a file
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// checksumLine formats a manifest line, escaping backslashes and newlines in
+// the name the way sha256sum does
+func checksumLine(sum []byte, name string) string {
+	if strings.ContainsAny(name, "\\\n\r") {
+		name = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`).Replace(name)
+		return fmt.Sprintf("\\%x  %s\n", sum, name)
+	}
+	return fmt.Sprintf("%x  %s\n", sum, name)
+}
+
+// VerifyChecksums checks the files of dir against its SHA256SUMS manifest
+// and returns one problem per file that was modified, is missing or is not
+// listed. An empty result means the folder is intact.
+func VerifyChecksums(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ChecksumsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksums file: %w", err)
+	}
+
+	var problems []string
+	listed := make(map[string]bool)
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		sum, name, ok := parseChecksumLine(line)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("malformed line %d of %s", i+1, ChecksumsFileName))
+			continue
+		}
+		listed[name] = true
+
+		got, err := hashFile(filepath.Join(dir, filepath.FromSlash(name)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, "missing: "+name)
+		case err != nil:
+			return nil, fmt.Errorf("failed to hash %s: %w", name, err)
+		case fmt.Sprintf("%x", got) != sum:
+			problems = append(problems, "modified: "+name)
+		}
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		// exec.log is written after the manifest
+		if rel = filepath.ToSlash(rel); rel != ChecksumsFileName && rel != execLogName && !listed[rel] {
+			problems = append(problems, "unlisted: "+rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return problems, nil
+}
+
+// parseChecksumLine parses a manifest line written by checksumLine into its
+// hex digest and unescaped name
+func parseChecksumLine(line string) (sum, name string, ok bool) {
+	escaped := strings.HasPrefix(line, `\`)
+	sum, name, ok = strings.Cut(strings.TrimPrefix(line, `\`), "  ")
+	if !ok || len(sum) != 2*sha256.Size || name == "" {
+		return "", "", false
+	}
+	if escaped {
+		name = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(name)
+	}
+	return sum, name, true
+}
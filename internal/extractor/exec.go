@@ -0,0 +1,85 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// execLogName is the file in each commit folder receiving the output of
+// Config.Exec
+const execLogName = "exec.log"
+
+// execWaitDelay bounds how long a cancelled command's children may keep
+// its output open
+const execWaitDelay = 5 * time.Second
+
+// ExecResult is the outcome of Config.Exec in one commit folder
+type ExecResult struct {
+	Command  string // Command line run, with {dir} substituted
+	ExitCode int
+	Output   string // Combined stdout and stderr
+}
+
+// runExec runs Config.Exec in the commit folder, with {dir} replaced by its
+// path, and writes the exit code and output to exec.log. A non-zero exit
+// is recorded in the result; only failing to run the command, or
+// cancellation, is an error.
+func (e *Extractor) runExec(ctx context.Context, outputPath string) (*ExecResult, error) {
+	select {
+	case e.execSlots <- struct{}{}:
+		defer func() { <-e.execSlots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	line := strings.ReplaceAll(e.config.Exec, "{dir}", shellQuote(outputPath))
+	cmd := shellCommand(ctx, line)
+	cmd.Dir = outputPath
+	cmd.WaitDelay = execWaitDelay
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	res := &ExecResult{Command: line, Output: output.String()}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(runErr, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case runErr != nil:
+		return nil, fmt.Errorf("failed to run exec command: %w", runErr)
+	}
+
+	log := fmt.Sprintf("$ %s\nexit code: %d\n\n%s", res.Command, res.ExitCode, res.Output)
+	if err := os.WriteFile(filepath.Join(outputPath, execLogName), []byte(log), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write exec log: %w", err)
+	}
+	return res, nil
+}
+
+// shellCommand returns a command running line through the system shell
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -4,39 +4,293 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"golang.org/x/sync/semaphore"
 
 	"github.com/andpalmier/repopsy/internal/config"
 	"github.com/andpalmier/repopsy/internal/git"
 	"github.com/andpalmier/repopsy/internal/progress"
 )
 
+// archiveExt is the file extension of archives written in flatten mode
+const archiveExt = ".tar"
+
+// patchesDirName is the directory in OutputDir receiving patches
+const patchesDirName = "patches"
+
+// rawDirName is the directory in each commit folder receiving the raw
+// commit object and tree listing
+const rawDirName = "raw"
+
+// incompleteMarkerName is the file marking a commit folder left behind by
+// a failed extraction, holding the error
+const incompleteMarkerName = "INCOMPLETE"
+
+// baselineDiffName is the file in each commit folder receiving its diff
+// against Config.Baseline
+const baselineDiffName = "vs_baseline.diff"
+
+// ErrCommitsFailed is wrapped by the error of Run and RunStream when some
+// commits could not be extracted
+var ErrCommitsFailed = errors.New("extractions failed")
+
+// prefetchBatchSize is the number of commits whose metadata is fetched by
+// a single git call
+const prefetchBatchSize = 64
+
 // Config configures the extraction process.
 type Config struct {
 	OutputDir  string
+	Branch     string // Branch being extracted, recorded in each Result
 	Workers    int
 	Verbose    bool
-	BufferSize int // Scanner buffer size in bytes (default: 1MB)
+	Sizes      bool // Write a sizes.txt report into each commit folder
+	Metrics    bool // Add file count and tree depth to the metadata
+	Checksums  bool // Write a SHA256SUMS manifest into each commit folder
+	Patches    bool // Write each commit as patches/<seq>.patch in format-patch form
+	DiffStat   bool // Write the git show --stat summary as diffstat.txt into each commit folder
+	Raw        bool // Write the raw commit object and tree listing under raw/ in each commit folder
+	NoMetadata bool // Extract only the tree, skipping the metadata git calls and COMMIT_INFO.txt
+	Xattrs     bool // Store key commit fields as user.repopsy.* extended attributes on the output
+	BufferSize int  // Scanner buffer size in bytes (default: 1MB)
+
+	// Exec is a shell command run in each commit folder once it is
+	// extracted, with {dir} replaced by the folder path. Its exit code and
+	// output go to exec.log and Result.Exec. At most ExecJobs commands run
+	// at once (default 1).
+	Exec     string
+	ExecJobs int
+
+	// GitProcs, when set, caps the git subprocesses running at once: each
+	// git call holds one of its tokens. It may be shared by extractors.
+	GitProcs *semaphore.Weighted
+	// JobDelay is a pause each worker takes after every commit
+	JobDelay time.Duration
+
+	// FirstIndex numbers the first commit, so that a later run continues
+	// the sequence of an earlier one in the same OutputDir
+	FirstIndex int
+
+	// IncludeDiff appends each commit's patch to its metadata, cut after
+	// MaxDiffLines lines (0 = config.DefaultMaxDiffLines, -1 = no limit)
+	IncludeDiff  bool
+	MaxDiffLines int
+
+	// Baseline, when set, is the hash of a commit each extracted commit is
+	// diffed against, the diff going to vs_baseline.diff in its folder
+	Baseline string
+
+	// FolderTemplate is a text/template rendering each commit's folder name.
+	// It receives .Index and .Commit and may use the slug and date helpers.
+	// Default: config.DefaultFolderTemplate
+	FolderTemplate string
+
+	// MetadataTemplate replaces the built-in COMMIT_INFO.txt layout when
+	// set (see git.LoadMetadataTemplate)
+	MetadataTemplate *template.Template
+
+	// IncludeGlobs and ExcludeGlobs select which files are extracted (see git.PathFilter)
+	IncludeGlobs []string
+	ExcludeGlobs []string
+
+	// MaxFileSize skips files larger than this many bytes (0 = no limit)
+	MaxFileSize int64
+
+	// WarnBinaryBytes flags commits adding or modifying more than this many
+	// bytes of binary files, often committed by accident (0 = no check)
+	WarnBinaryBytes int64
+
+	// Submodules extracts the tree of each submodule whose commit is
+	// available locally into its path in the commit folder
+	Submodules bool
+
+	// Inventory gathers the metadata of each commit into its Result without
+	// writing anything: no folder, tree or COMMIT_INFO.txt
+	Inventory bool
+
+	// FileTimes sets the modification time of each extracted file to the
+	// author date of the last commit that changed it, for trees of at most
+	// FileTimesMaxFiles files (0 = no limit)
+	FileTimes         bool
+	FileTimesMaxFiles int
+
+	// DeltaOnly extracts only the files each commit added or modified,
+	// with their full content, instead of its whole tree
+	DeltaOnly bool
+
+	// KeepEmptyDirs recreates the directories of each commit's tree that
+	// ended up empty because MaxFileSize dropped every file in them.
+	// Directories left out by the include and exclude globs, or by
+	// DeltaOnly, stay out.
+	KeepEmptyDirs bool
+
+	// Reproducible sets the modification time of everything written for a
+	// commit to its author date and normalizes modes to 0644/0755, so that
+	// repeated extractions of a commit are identical
+	Reproducible bool
+
+	// CASDir, when set, turns each commit folder into a tree of symlinks
+	// into a content-addressable store in this directory, holding every
+	// distinct file once (see storeInCAS)
+	CASDir string
+
+	// LFS detects Git LFS pointer files in each commit folder and, when
+	// git-lfs is installed, replaces them with the real content
+	LFS bool
+
+	// Flatten writes each commit as a <NamePrefix><folder>.tar archive plus a
+	// <NamePrefix><folder>.txt metadata file directly into OutputDir
+	Flatten    bool
+	NamePrefix string
+
+	// AllowedKeys, when non-nil, classifies each commit's signature as
+	// trusted, untrusted or unsigned in its metadata
+	AllowedKeys git.KeyAllowlist
+
+	// SkipEmpty skips commits whose tree equals their parent's without
+	// creating a folder for them
+	SkipEmpty bool
+
+	// CleanupOnInterrupt removes folders whose extraction had not completed
+	// when the context was cancelled. Completed folders are kept.
+	CleanupOnInterrupt bool
+
+	// PerCommitTimeout bounds the extraction of a single commit; a commit that
+	// exceeds it is recorded as failed while other workers proceed (0 = none)
+	PerCommitTimeout time.Duration
+
+	// Logger receives structured logs of the extraction. Default: none
+	Logger *slog.Logger
+
+	// Reporter is an optional shared progress reporter. When set, Run reports
+	// progress to it and leaves finishing it to the caller.
+	Reporter progress.Reporter
 }
 
 // Result represents the outcome of a single commit
 type Result struct {
 	Commit     git.Commit
+	Branch     string
 	Index      int
 	OutputPath string
 	Error      error
+
+	// LargestFile is the largest file in the commit's tree (only set with Config.Sizes)
+	LargestFile git.FileSize
+
+	// Empty is set when the commit's tree equals its parent's; with
+	// Config.SkipEmpty such commits are Skipped and have no OutputPath
+	Empty   bool
+	Skipped bool
+
+	// SkippedLarge counts files left out for exceeding Config.MaxFileSize
+	SkippedLarge int
+
+	// BinaryBytes is the size of the binary files the commit adds or
+	// modifies, set only when it exceeds Config.WarnBinaryBytes
+	BinaryBytes int64
+
+	// LFSPointers counts Git LFS pointer files found with Config.LFS, and
+	// LFSFetched how many of them were replaced with the real content
+	LFSPointers int
+	LFSFetched  int
+
+	// CASObjects counts the objects this commit added to the store (only
+	// with Config.CASDir)
+	CASObjects int
+
+	// XattrsSkipped is set when Config.Xattrs was requested but the
+	// filesystem does not support extended attributes
+	XattrsSkipped bool
+
+	// FileTimesSkipped is set when Config.FileTimes was requested but the
+	// tree has more than Config.FileTimesMaxFiles files
+	FileTimesSkipped bool
+
+	// UnresolvedSubmodules lists the paths of submodules whose commit was
+	// not available locally (only with Config.Submodules)
+	UnresolvedSubmodules []string
+
+	// Exec is the outcome of Config.Exec, nil when it did not run
+	Exec *ExecResult
+
+	// Duration is the time spent on the commit, and MetadataDuration the
+	// part of it spent gathering and writing its metadata
+	Duration         time.Duration
+	MetadataDuration time.Duration
+}
+
+// CommitExtractor is the subset of repository operations used by the Extractor.
+// *git.Repository satisfies it; tests can substitute a fake.
+type CommitExtractor interface {
+	ExtractCommit(ctx context.Context, hash, destPath string) error
+	ExtractCommitFiltered(ctx context.Context, hash, destPath string, filter git.PathFilter) error
+	ArchiveCommit(ctx context.Context, hash, destFile string, filter git.PathFilter) error
+	GetCommitStats(ctx context.Context, hash string) (git.CommitStats, error)
+	GetCommitsMetadata(ctx context.Context, hashes []string) (map[string]git.CommitMetadata, error)
+	GetCommitFullMessage(ctx context.Context, hash string) (string, error)
+	GetSignatureInfo(ctx context.Context, hash string) (git.SignatureInfo, error)
+	GetTagSignature(ctx context.Context, tag string) (git.SignatureInfo, error)
+	GetCommitTreeSizes(ctx context.Context, hash string) ([]git.FileSize, error)
+	TooLargeFiles(ctx context.Context, hash string, filter git.PathFilter) ([]git.FileSize, error)
+	GetBinaryChangeBytes(ctx context.Context, hash string) (int64, error)
+	GetTreeMetrics(ctx context.Context, hash string) (git.TreeMetrics, error)
+	GetFileDiff(ctx context.Context, hash string, maxLines int) (string, bool, error)
+	IsEmptyCommit(ctx context.Context, hash string) (bool, error)
+	GetFormatPatch(ctx context.Context, hash string) (string, error)
+	GetDiffStat(ctx context.Context, hash string) (string, error)
+	WriteDiff(ctx context.Context, from, to, destFile string) error
+	CatObject(ctx context.Context, hash string) (string, error)
+	ListTree(ctx context.Context, hash string) (string, error)
+	FileTimes(ctx context.Context, hash string, paths []string) (map[string]time.Time, error)
+	SmudgeLFS(ctx context.Context, path string) error
+	ExtractSubmodules(ctx context.Context, hash, destPath string) ([]string, error)
 }
 
 // Extractor coordinates the extraction of multiple commits using a worker pool
 type Extractor struct {
-	repo   *git.Repository
-	config Config
+	repo       CommitExtractor
+	config     Config
+	folderTmpl *template.Template
+
+	// lfsInstalled is set when git-lfs can materialize LFS pointers
+	lfsInstalled bool
+
+	// prefetched holds metadata fetched in batches ahead of extraction,
+	// until extractOne consumes it
+	prefetchMu sync.Mutex
+	prefetched map[string]git.CommitMetadata
+
+	// execSlots limits the Config.Exec commands running at once
+	execSlots chan struct{}
+
+	// xattrsUnsupported is set once extended attributes failed with
+	// errXattrUnsupported, so later commits do not try again
+	xattrsUnsupported atomic.Bool
+
+	// foldersMu guards folders, the set of folder names already claimed,
+	// and inProgress, the set of output paths not yet fully written
+	foldersMu  sync.Mutex
+	folders    map[string]bool
+	inProgress map[string]bool
 }
 
 // New creates a new Extractor with the given configuration.
-func New(repo *git.Repository, cfg Config) *Extractor {
+// It returns an error if the folder template is invalid.
+func New(repo CommitExtractor, cfg Config) (*Extractor, error) {
 	if cfg.Workers <= 0 {
 		cfg.Workers = runtime.NumCPU()
 	}
@@ -44,7 +298,31 @@ func New(repo *git.Repository, cfg Config) *Extractor {
 	if cfg.BufferSize < config.MinBufferSize {
 		cfg.BufferSize = config.DefaultBufferSize
 	}
-	return &Extractor{repo: repo, config: cfg}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.DiscardHandler)
+	}
+	if cfg.ExecJobs <= 0 {
+		cfg.ExecJobs = 1
+	}
+	if cfg.MaxDiffLines == 0 {
+		cfg.MaxDiffLines = config.DefaultMaxDiffLines
+	}
+	folderTmpl, err := parseFolderTemplate(cfg.FolderTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.GitProcs != nil {
+		repo = throttledRepo{repo: repo, procs: cfg.GitProcs}
+	}
+	return &Extractor{
+		repo:         repo,
+		config:       cfg,
+		folderTmpl:   folderTmpl,
+		lfsInstalled: cfg.LFS && git.LFSInstalled(),
+		execSlots:    make(chan struct{}, cfg.ExecJobs),
+		folders:      make(map[string]bool),
+		inProgress:   make(map[string]bool),
+	}, nil
 }
 
 // job represents a single extraction task sent to workers
@@ -53,23 +331,52 @@ type job struct {
 	index  int
 }
 
-// Run extracts all provided commits concurrently
+// Run extracts all provided commits concurrently, returning the results
+// sorted by index
 func (e *Extractor) Run(ctx context.Context, commits []git.Commit) ([]Result, error) {
 	if len(commits) == 0 {
 		return nil, nil
 	}
 
-	// Initialize progress reporter
-	reporter := progress.New(progress.Config{
-		Total:   len(commits),
-		Verbose: e.config.Verbose,
-	})
-	reporter.Start()
+	stream := make(chan git.Commit)
+	go func() {
+		defer close(stream)
+		for _, commit := range commits {
+			select {
+			case stream <- commit:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return e.RunStream(ctx, stream, len(commits))
+}
+
+// RunStream extracts the commits received from commits until it is closed,
+// numbering them in arrival order, and returns the results sorted by index.
+// Only a few commits per worker are buffered, so that long histories can be
+// streamed from git.Repository.ListCommitsChan. total sizes the progress bar
+// when no shared reporter is configured (0 = unknown).
+func (e *Extractor) RunStream(ctx context.Context, commits <-chan git.Commit, total int) ([]Result, error) {
+	// Initialize progress reporter, unless a shared one was provided
+	reporter := e.config.Reporter
+	if reporter == nil {
+		reporter = progress.New(progress.Config{
+			Total:   total,
+			Verbose: e.config.Verbose,
+		})
+		reporter.Start()
+		defer reporter.Finish()
+	}
+
+	e.config.Logger.Info("extraction started",
+		"branch", e.config.Branch, "commits", total, "workers", e.config.Workers)
 
-	// jobs channel receives tasks (commits to connect)
+	// jobs channel receives tasks (commits to extract)
 	// results channel collects the extractions
-	jobs := make(chan job, len(commits))
-	results := make(chan Result, len(commits))
+	// Both are bounded by the worker count rather than the number of commits
+	jobs := make(chan job, e.config.Workers)
+	results := make(chan Result, e.config.Workers)
 
 	// Start worker pool
 	var wg sync.WaitGroup
@@ -81,11 +388,32 @@ func (e *Extractor) Run(ctx context.Context, commits []git.Commit) ([]Result, er
 		}()
 	}
 
-	// Send jobs to workers
-	for i, commit := range commits {
-		jobs <- job{commit: commit, index: i}
-	}
-	close(jobs)
+	// Send jobs to workers as commits arrive, in batches whose metadata is
+	// fetched with one git call first
+	go func() {
+		defer close(jobs)
+		index := e.config.FirstIndex
+		batch := make([]git.Commit, 0, prefetchBatchSize)
+		send := func() bool {
+			e.prefetch(ctx, batch)
+			for _, commit := range batch {
+				select {
+				case jobs <- job{commit: commit, index: index}:
+					index++
+				case <-ctx.Done():
+					return false
+				}
+			}
+			batch = batch[:0]
+			return true
+		}
+		for commit := range commits {
+			if batch = append(batch, commit); len(batch) == prefetchBatchSize && !send() {
+				return
+			}
+		}
+		send()
+	}()
 
 	// Wait for all workers to complete, then close results channel
 	go func() {
@@ -93,29 +421,38 @@ func (e *Extractor) Run(ctx context.Context, commits []git.Commit) ([]Result, er
 		close(results)
 	}()
 
-	// Collect results
-	allResults := make([]Result, 0, len(commits))
-	var extractionErrs []error
-
+	// Collect results, which arrive in completion order, and return them
+	// by index so that reports and errors are identical from run to run
+	allResults := make([]Result, 0, total)
 	for result := range results {
 		allResults = append(allResults, result)
+	}
+	slices.SortFunc(allResults, func(a, b Result) int { return a.Index - b.Index })
+
+	var extractionErrs []error
+	for _, result := range allResults {
 		if result.Error != nil {
 			extractionErrs = append(extractionErrs, result.Error)
 		}
 	}
 
-	reporter.Finish()
+	// All workers have returned here, so the in-progress set is stable
+	if ctx.Err() != nil && e.config.CleanupOnInterrupt {
+		if err := e.removeIncomplete(); err != nil {
+			extractionErrs = append(extractionErrs, err)
+		}
+	}
 
 	if len(extractionErrs) > 0 {
-		return allResults, fmt.Errorf("%d of %d extractions failed: %w",
-			len(extractionErrs), len(commits), errors.Join(extractionErrs...))
+		return allResults, fmt.Errorf("%d of %d %w: %w",
+			len(extractionErrs), max(total, len(allResults)), ErrCommitsFailed, errors.Join(extractionErrs...))
 	}
 
 	return allResults, nil
 }
 
 // worker processes jobs from the jobs channel
-func (e *Extractor) worker(ctx context.Context, jobs <-chan job, results chan<- Result, reporter *progress.Reporter) {
+func (e *Extractor) worker(ctx context.Context, jobs <-chan job, results chan<- Result, reporter progress.Reporter) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -125,49 +462,626 @@ func (e *Extractor) worker(ctx context.Context, jobs <-chan job, results chan<-
 				return
 			}
 
+			start := time.Now()
 			result := e.extractOne(ctx, j.commit, j.index)
 			results <- result
+			e.logResult(result, time.Since(start))
 
-			if result.Error != nil {
-				reporter.Increment(fmt.Sprintf("✗ %s: %v", j.commit.ShortHash, result.Error))
-			} else {
-				reporter.Increment(fmt.Sprintf("✓ %s → %s", j.commit.ShortHash, filepath.Base(result.OutputPath)))
+			message := filepath.Base(result.OutputPath)
+			switch {
+			case result.Skipped:
+				message = "skipped empty commit"
+			case e.config.Inventory:
+				message = "metadata collected"
 			}
+			reporter.Increment(progress.Event{
+				Branch:  e.config.Branch,
+				Hash:    j.commit.ShortHash,
+				Index:   j.index,
+				Err:     result.Error,
+				Message: message,
+			})
+
+			pause(ctx, e.config.JobDelay)
+		}
+	}
+}
+
+// logResult logs the outcome of a single commit extraction
+func (e *Extractor) logResult(r Result, elapsed time.Duration) {
+	attrs := []slog.Attr{
+		slog.String("branch", r.Branch),
+		slog.String("hash", r.Commit.ShortHash),
+		slog.Duration("duration", elapsed),
+	}
+	switch {
+	case r.Error != nil:
+		attrs = append(attrs, slog.String("error", r.Error.Error()))
+		e.config.Logger.LogAttrs(context.Background(), slog.LevelWarn, "commit extraction failed", attrs...)
+	case r.Skipped:
+		e.config.Logger.LogAttrs(context.Background(), slog.LevelDebug, "commit skipped", append(attrs, slog.Bool("empty", r.Empty))...)
+	default:
+		attrs = append(attrs, slog.String("path", r.OutputPath))
+		e.config.Logger.LogAttrs(context.Background(), slog.LevelDebug, "commit extracted", attrs...)
+	}
+}
+
+// extractTree extracts the files of a commit into outputPath, aborting the
+// git and tar subprocesses when the per-commit timeout expires
+func (e *Extractor) extractTree(ctx context.Context, hash, outputPath string) error {
+	if e.config.PerCommitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.PerCommitTimeout)
+		defer cancel()
+	}
+
+	var err error
+	filter := e.pathFilter()
+	if e.config.Flatten {
+		err = e.repo.ArchiveCommit(ctx, hash, outputPath, filter)
+	} else if filter.IsEmpty() {
+		err = e.repo.ExtractCommit(ctx, hash, outputPath)
+	} else {
+		err = e.repo.ExtractCommitFiltered(ctx, hash, outputPath, filter)
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("extraction timed out after %s: %w", e.config.PerCommitTimeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+// pathFilter returns the filter selecting the files of each commit to extract
+func (e *Extractor) pathFilter() git.PathFilter {
+	return git.PathFilter{
+		Include: e.config.IncludeGlobs,
+		Exclude: e.config.ExcludeGlobs,
+		MaxSize: e.config.MaxFileSize,
+		Changed: e.config.DeltaOnly,
+	}
+}
+
+// restoreEmptyDirs creates the directories of the dropped files, left out
+// for exceeding MaxFileSize, which the extraction skips when none of their
+// files is kept. Files dropped by the globs, or unchanged files with
+// DeltaOnly, are not among them and restore nothing.
+func restoreEmptyDirs(outputPath string, dropped []git.FileSize) error {
+	for _, fs := range dropped {
+		dir := path.Dir(fs.Path)
+		if dir == "." {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(outputPath, filepath.FromSlash(dir)), config.OutputDirPerms); err != nil {
+			return fmt.Errorf("failed to recreate directory %s: %w", dir, err)
 		}
 	}
+	return nil
+}
+
+// checkBinaryBytes returns the binary bytes a commit adds or modifies when
+// they exceed WarnBinaryBytes, and 0 otherwise
+func (e *Extractor) checkBinaryBytes(ctx context.Context, commit git.Commit) (int64, error) {
+	n, err := e.repo.GetBinaryChangeBytes(ctx, commit.Hash)
+	if err != nil || n <= e.config.WarnBinaryBytes {
+		return 0, err
+	}
+	e.config.Logger.Warn("large binary change",
+		"hash", commit.ShortHash, "bytes", n, "threshold", e.config.WarnBinaryBytes)
+	return n, nil
 }
 
 // extractOne extracts a single commit and returns the result
 func (e *Extractor) extractOne(ctx context.Context, commit git.Commit, index int) Result {
-	// Format: YYYYMMDD_HHMMSS_hash (e.g., 20231205_143022_abc1234)
-	timestamp := commit.AuthorDate.Format("20060102_150405")
-	folderName := fmt.Sprintf("%s_%s", timestamp, commit.ShortHash)
-	outputPath := filepath.Join(e.config.OutputDir, folderName)
+	start := time.Now()
+	// A commit skipped or failed before its metadata is collected leaves its
+	// prefetched metadata unused, which would otherwise be kept until the
+	// end of the run
+	defer e.forgetPrefetched(commit.Hash)
+
+	// Empty commits are detected before extraction so they can be skipped
+	if e.config.SkipEmpty {
+		empty, err := e.repo.IsEmptyCommit(ctx, commit.Hash)
+		if err != nil {
+			return Result{Commit: commit, Branch: e.config.Branch, Index: index, Error: err}
+		}
+		if empty {
+			return Result{Commit: commit, Branch: e.config.Branch, Index: index, Empty: true, Skipped: true}
+		}
+	}
+
+	if e.config.Inventory {
+		return e.inventoryOne(ctx, commit, index)
+	}
+
+	// Default format: YYYYMMDD_HHMMSS_hash (e.g., 20231205_143022_abc1234)
+	folderName, err := renderFolderName(e.folderTmpl, commit, index)
+	if err != nil {
+		return Result{Commit: commit, Branch: e.config.Branch, Index: index, Error: err}
+	}
+	var outputPath string
+	if e.config.Flatten {
+		if outputPath, err = e.claimArchive(e.config.NamePrefix + folderName); err != nil {
+			return Result{Commit: commit, Branch: e.config.Branch, Index: index, Error: err}
+		}
+	} else {
+		outputPath = filepath.Join(e.config.OutputDir, e.claimFolder(folderName))
+	}
+	e.setInProgress(outputPath, true)
 
 	// Extract commit contents
-	err := e.repo.ExtractCommit(ctx, commit.Hash, outputPath)
+	err = e.extractTree(ctx, commit.Hash, outputPath)
+	var empty bool
+	var skippedLarge int
+	var unresolved []string
 
-	// Always write metadata if extraction succeeded
-	if err == nil {
-		if fullMsg, msgErr := e.repo.GetCommitFullMessage(ctx, commit.Hash); msgErr == nil {
-			commit.FullMessage = fullMsg
+	if err == nil && e.config.Submodules {
+		if unresolved, err = e.repo.ExtractSubmodules(ctx, commit.Hash, outputPath); len(unresolved) > 0 {
+			e.config.Logger.Warn("submodules not available locally",
+				"hash", commit.ShortHash, "paths", unresolved)
 		}
+	}
 
-		if stats, statsErr := e.repo.GetCommitStats(ctx, commit.Hash); statsErr == nil {
-			commit.FilesChanged = stats.FilesChanged
-			commit.Insertions = stats.Insertions
-			commit.Deletions = stats.Deletions
+	// Files otherwise selected for extraction but left out for their size
+	// are counted, and their directories restored with KeepEmptyDirs
+	if err == nil && e.config.MaxFileSize > 0 {
+		var dropped []git.FileSize
+		if dropped, err = e.repo.TooLargeFiles(ctx, commit.Hash, e.pathFilter()); err == nil {
+			skippedLarge = len(dropped)
+			if e.config.KeepEmptyDirs {
+				err = restoreEmptyDirs(outputPath, dropped)
+			}
 		}
+	}
+
+	// Flagged before the metadata is written so that it records the warning
+	if err == nil && e.config.WarnBinaryBytes > 0 {
+		commit.BinaryBytes, err = e.checkBinaryBytes(ctx, commit)
+	}
 
-		if metaErr := commit.WriteMetadataFile(outputPath); metaErr != nil {
+	// Write metadata if extraction succeeded, unless only trees are wanted
+	var metadataDuration time.Duration
+	if err == nil && !e.config.NoMetadata {
+		metadataStart := time.Now()
+		empty = e.collectMetadata(ctx, &commit)
+		if metaErr := e.writeMetadata(commit, outputPath); metaErr != nil {
 			err = fmt.Errorf("extraction succeeded but metadata write failed: %w", metaErr)
 		}
+		metadataDuration = time.Since(metadataStart)
+	}
+
+	var xattrsSkipped bool
+	if err == nil && e.config.Xattrs {
+		xattrsSkipped, err = e.setXattrs(outputPath, commit)
+	}
+
+	var lfsPointers, lfsFetched int
+	if err == nil && e.config.LFS {
+		lfsPointers, lfsFetched, err = e.resolveLFS(ctx, outputPath)
+	}
+
+	// Later reports describe the folder, they are not part of the tree
+	var casObjects int
+	if err == nil && e.config.CASDir != "" {
+		casObjects, err = storeInCAS(outputPath, e.config.CASDir)
+	}
+
+	if err == nil && e.config.Patches {
+		err = e.writePatch(ctx, commit.Hash, index)
 	}
 
+	if err == nil && e.config.DiffStat {
+		err = e.writeDiffStat(ctx, commit.Hash, outputPath)
+	}
+
+	if err == nil && e.config.Baseline != "" {
+		err = e.repo.WriteDiff(ctx, e.config.Baseline, commit.Hash, filepath.Join(outputPath, baselineDiffName))
+	}
+
+	if err == nil && e.config.Raw {
+		err = e.writeRaw(ctx, commit.Hash, outputPath)
+	}
+
+	var largest git.FileSize
+	if err == nil && e.config.Sizes {
+		largest, err = e.writeSizes(ctx, commit.Hash, outputPath)
+	}
+
+	// Checksums cover every file repopsy wrote into the folder
+	if err == nil && e.config.Checksums {
+		err = writeChecksums(outputPath)
+	}
+
+	// The command runs once the folder is complete, so that neither the
+	// store nor the manifest takes its outputs for part of the tree
+	var execResult *ExecResult
+	if err == nil && e.config.Exec != "" {
+		execResult, err = e.runExec(ctx, outputPath)
+	}
+
+	// Files are dated once LFS and the command no longer rewrite them
+	var fileTimesSkipped bool
+	if err == nil && e.config.FileTimes {
+		fileTimesSkipped, err = e.setFileTimes(ctx, commit, outputPath)
+	}
+
+	// Times and modes are normalized once nothing else is written
+	if err == nil && e.config.Reproducible {
+		err = e.normalizeOutput(outputPath, commit.AuthorDate)
+	}
+
+	if err == nil {
+		e.setInProgress(outputPath, false)
+	} else if !e.config.Flatten {
+		e.markIncomplete(outputPath, err)
+	}
+
+	return Result{
+		Commit:       commit,
+		Branch:       e.config.Branch,
+		Index:        index,
+		OutputPath:   outputPath,
+		Error:        err,
+		LargestFile:  largest,
+		Empty:        empty,
+		SkippedLarge: skippedLarge,
+		BinaryBytes:  commit.BinaryBytes,
+		LFSPointers:  lfsPointers,
+		LFSFetched:   lfsFetched,
+
+		CASObjects:           casObjects,
+		XattrsSkipped:        xattrsSkipped,
+		FileTimesSkipped:     fileTimesSkipped,
+		UnresolvedSubmodules: unresolved,
+		Exec:                 execResult,
+		Duration:             time.Since(start),
+		MetadataDuration:     metadataDuration,
+	}
+}
+
+// collectMetadata fills in the message, trailers, change statistics and
+// signature of commit and of its tag, along with the metrics, diff and trust requested in
+// the configuration, and reports whether the commit is empty
+func (e *Extractor) collectMetadata(ctx context.Context, commit *git.Commit) (empty bool) {
+	meta, statsErr := e.commitMetadata(ctx, commit.Hash)
+	if meta.FullMessage != "" {
+		commit.FullMessage = meta.FullMessage
+		commit.Trailers = git.ParseTrailers(meta.FullMessage)
+	}
+
+	if statsErr == nil {
+		stats := meta.Stats
+		commit.FilesChanged = stats.FilesChanged
+		commit.Insertions = stats.Insertions
+		commit.Deletions = stats.Deletions
+
+		// Only commits without changed files can be empty; confirm against the tree
+		if stats.FilesChanged == 0 && !e.config.SkipEmpty {
+			empty, _ = e.repo.IsEmptyCommit(ctx, commit.Hash)
+		}
+	}
+
+	if e.config.Metrics {
+		if metrics, metricsErr := e.repo.GetTreeMetrics(ctx, commit.Hash); metricsErr == nil {
+			commit.TreeMetrics = &metrics
+		}
+	}
+
+	if e.config.IncludeDiff {
+		if diff, truncated, diffErr := e.repo.GetFileDiff(ctx, commit.Hash, e.config.MaxDiffLines); diffErr == nil {
+			commit.Diff, commit.DiffTruncated = diff, truncated
+		}
+	}
+
+	if commit.IsSigned() {
+		if sig, sigErr := e.repo.GetSignatureInfo(ctx, commit.Hash); sigErr == nil {
+			commit.GPGKeyID = sig.KeyID
+			commit.GPGSigner = sig.Signer
+			commit.GPGRaw = sig.Raw
+		}
+	}
+
+	if commit.Tag != "" {
+		if sig, sigErr := e.repo.GetTagSignature(ctx, commit.Tag); sigErr == nil {
+			commit.TagSignature = sig.Status
+			commit.TagKeyID = sig.KeyID
+			commit.TagSigner = sig.Signer
+			if e.config.AllowedKeys != nil {
+				commit.TagTrust = e.config.AllowedKeys.ClassifyTag(sig)
+			}
+		}
+	}
+
+	if e.config.AllowedKeys != nil {
+		commit.Trust = e.config.AllowedKeys.Classify(*commit)
+	}
+	return empty
+}
+
+// inventoryOne gathers the metadata of a single commit without writing
+// anything, for Config.Inventory
+func (e *Extractor) inventoryOne(ctx context.Context, commit git.Commit, index int) Result {
+	start := time.Now()
+	empty := e.collectMetadata(ctx, &commit)
+	elapsed := time.Since(start)
 	return Result{
-		Commit:     commit,
-		Index:      index,
-		OutputPath: outputPath,
-		Error:      err,
+		Commit:           commit,
+		Branch:           e.config.Branch,
+		Index:            index,
+		Error:            ctx.Err(),
+		Empty:            empty,
+		Duration:         elapsed,
+		MetadataDuration: elapsed,
+	}
+}
+
+// claimFolder reserves a unique folder name, appending _2, _3, ... when
+// another commit already rendered to the same name or, with an earlier run
+// in OutputDir, the folder already exists
+func (e *Extractor) claimFolder(name string) string {
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	taken := func(name string) bool {
+		if e.folders[name] {
+			return true
+		}
+		_, err := os.Lstat(filepath.Join(e.config.OutputDir, name))
+		return err == nil
+	}
+	claimed := name
+	for n := 2; taken(claimed); n++ {
+		claimed = fmt.Sprintf("%s_%d", name, n)
+	}
+	e.folders[claimed] = true
+	return claimed
+}
+
+// claimArchive reserves a unique archive file in OutputDir. The file is
+// created exclusively so that names also stay unique across the extractors
+// of other branches writing to the same directory.
+func (e *Extractor) claimArchive(name string) (string, error) {
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	if err := os.MkdirAll(e.config.OutputDir, config.OutputDirPerms); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	claimed := name
+	for n := 2; ; n++ {
+		if !e.folders[claimed] {
+			path := filepath.Join(e.config.OutputDir, claimed+archiveExt)
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err == nil {
+				e.folders[claimed] = true
+				return path, f.Close()
+			}
+			if !errors.Is(err, fs.ErrExist) {
+				return "", fmt.Errorf("failed to create archive: %w", err)
+			}
+		}
+		claimed = fmt.Sprintf("%s_%d", name, n)
+	}
+}
+
+// writeMetadata writes the commit metadata into the commit folder, or next
+// to the archive when flattening
+func (e *Extractor) writeMetadata(commit git.Commit, outputPath string) error {
+	metadataPath := filepath.Join(outputPath, "COMMIT_INFO.txt")
+	if e.config.Flatten {
+		metadataPath = strings.TrimSuffix(outputPath, archiveExt) + ".txt"
+	}
+	return commit.WriteMetadataWith(e.config.MetadataTemplate, metadataPath)
+}
+
+// prefetch fetches the metadata of a batch of commits with a single git
+// call. On failure nothing is stored and each commit falls back to its own
+// git calls.
+func (e *Extractor) prefetch(ctx context.Context, batch []git.Commit) {
+	if e.config.NoMetadata || len(batch) == 0 {
+		return
+	}
+	hashes := make([]string, len(batch))
+	for i, commit := range batch {
+		hashes[i] = commit.Hash
+	}
+	metadata, err := e.repo.GetCommitsMetadata(ctx, hashes)
+	if err != nil {
+		e.config.Logger.Debug("metadata prefetch failed, falling back to per-commit calls", "error", err)
+		return
+	}
+
+	e.prefetchMu.Lock()
+	defer e.prefetchMu.Unlock()
+	if e.prefetched == nil {
+		e.prefetched = make(map[string]git.CommitMetadata)
+	}
+	maps.Copy(e.prefetched, metadata)
+}
+
+// forgetPrefetched drops the prefetched metadata of a commit, if any
+func (e *Extractor) forgetPrefetched(hash string) {
+	e.prefetchMu.Lock()
+	defer e.prefetchMu.Unlock()
+	delete(e.prefetched, hash)
+}
+
+// commitMetadata returns the prefetched metadata of a commit, or fetches it
+// with per-commit git calls. The returned error concerns the statistics; a
+// message that cannot be read is left empty.
+func (e *Extractor) commitMetadata(ctx context.Context, hash string) (git.CommitMetadata, error) {
+	e.prefetchMu.Lock()
+	meta, ok := e.prefetched[hash]
+	delete(e.prefetched, hash)
+	e.prefetchMu.Unlock()
+	if ok {
+		return meta, nil
+	}
+
+	if fullMsg, err := e.repo.GetCommitFullMessage(ctx, hash); err == nil {
+		meta.FullMessage = fullMsg
+	}
+	stats, err := e.repo.GetCommitStats(ctx, hash)
+	meta.Stats = stats
+	return meta, err
+}
+
+// setXattrs stores the commit fields as extended attributes of the output.
+// Where they are unsupported it warns once and reports the commit as skipped
+// rather than failed.
+func (e *Extractor) setXattrs(outputPath string, commit git.Commit) (skipped bool, err error) {
+	if e.xattrsUnsupported.Load() {
+		return true, nil
+	}
+	err = writeXattrs(outputPath, commit)
+	if errors.Is(err, errXattrUnsupported) {
+		if !e.xattrsUnsupported.Swap(true) {
+			e.config.Logger.Warn("extended attributes are not supported, skipping them", "path", outputPath)
+		}
+		return true, nil
+	}
+	return false, err
+}
+
+// normalizeOutput normalizes the commit folder, or the archive and its
+// metadata file when flattening
+func (e *Extractor) normalizeOutput(outputPath string, mtime time.Time) error {
+	if err := normalizeTree(outputPath, mtime); err != nil {
+		return err
+	}
+	if e.config.Flatten && !e.config.NoMetadata {
+		return normalizeTree(strings.TrimSuffix(outputPath, archiveExt)+".txt", mtime)
+	}
+	return nil
+}
+
+// setInProgress marks or unmarks an output path as partially written
+func (e *Extractor) setInProgress(outputPath string, active bool) {
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	if active {
+		e.inProgress[outputPath] = true
+	} else {
+		delete(e.inProgress, outputPath)
+	}
+}
+
+// markIncomplete writes an INCOMPLETE marker holding cause into a commit
+// folder whose extraction failed, when the folder was created, so that it
+// is not mistaken for a complete one (e.g. a tree without COMMIT_INFO.txt)
+func (e *Extractor) markIncomplete(outputPath string, cause error) {
+	if _, err := os.Stat(outputPath); err != nil {
+		return
+	}
+	marker := filepath.Join(outputPath, incompleteMarkerName)
+	if err := os.WriteFile(marker, []byte(cause.Error()+"\n"), 0o644); err != nil {
+		e.config.Logger.Warn("failed to mark incomplete folder", "path", outputPath, "error", err)
+	}
+}
+
+// removeIncomplete deletes every folder whose extraction did not complete
+func (e *Extractor) removeIncomplete() error {
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	var errs []error
+	for outputPath := range e.inProgress {
+		if err := os.RemoveAll(outputPath); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove incomplete folder: %w", err))
+			continue
+		}
+		delete(e.inProgress, outputPath)
+	}
+	return errors.Join(errs...)
+}
+
+// resolveLFS finds the LFS pointer files in a commit folder and, when
+// git-lfs is installed, replaces them with their objects. Pointers whose
+// object cannot be fetched are kept and logged.
+func (e *Extractor) resolveLFS(ctx context.Context, outputPath string) (pointers, fetched int, err error) {
+	paths, err := git.FindLFSPointers(outputPath)
+	if err != nil || !e.lfsInstalled {
+		return len(paths), 0, err
+	}
+	for _, path := range paths {
+		if err := e.repo.SmudgeLFS(ctx, path); err != nil {
+			e.config.Logger.Warn("LFS object not fetched", "path", path, "error", err)
+			continue
+		}
+		fetched++
+	}
+	return len(paths), fetched, nil
+}
+
+// writePatch writes a commit's format-patch output to patches/<seq>.patch
+// in OutputDir, numbered from 1 in extraction order so that the patches of
+// a branch can be replayed with git am. Merge commits produce no file.
+func (e *Extractor) writePatch(ctx context.Context, hash string, index int) error {
+	patch, err := e.repo.GetFormatPatch(ctx, hash)
+	if err != nil || patch == "" {
+		return err
+	}
+
+	dir := filepath.Join(e.config.OutputDir, patchesDirName)
+	if err := os.MkdirAll(dir, config.OutputDirPerms); err != nil {
+		return fmt.Errorf("failed to create patches directory: %w", err)
+	}
+	name := fmt.Sprintf("%s%04d.patch", e.config.NamePrefix, index+1)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(patch), 0o644); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	return nil
+}
+
+// writeDiffStat writes the diffstat.txt summary of a commit's changes
+func (e *Extractor) writeDiffStat(ctx context.Context, hash, outputPath string) error {
+	stat, err := e.repo.GetDiffStat(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, "diffstat.txt"), []byte(stat), 0o644); err != nil {
+		return fmt.Errorf("failed to write diffstat file: %w", err)
+	}
+	return nil
+}
+
+// writeRaw writes raw/object.txt (git cat-file -p) and raw/tree.txt
+// (git ls-tree -r), exposing the object IDs and modes behind the tree
+func (e *Extractor) writeRaw(ctx context.Context, hash, outputPath string) error {
+	object, err := e.repo.CatObject(ctx, hash)
+	if err != nil {
+		return err
+	}
+	tree, err := e.repo.ListTree(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(outputPath, rawDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create raw directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "object.txt"), []byte(object), 0o644); err != nil {
+		return fmt.Errorf("failed to write raw object: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tree.txt"), []byte(tree), 0o644); err != nil {
+		return fmt.Errorf("failed to write raw tree: %w", err)
+	}
+	return nil
+}
+
+// writeSizes writes the sizes.txt report for a commit and returns its largest file
+func (e *Extractor) writeSizes(ctx context.Context, hash, outputPath string) (git.FileSize, error) {
+	sizes, err := e.repo.GetCommitTreeSizes(ctx, hash)
+	if err != nil {
+		return git.FileSize{}, err
+	}
+	if err := git.WriteSizesFile(outputPath, sizes); err != nil {
+		return git.FileSize{}, err
+	}
+	if len(sizes) == 0 {
+		return git.FileSize{}, nil
 	}
+	return sizes[0], nil
 }
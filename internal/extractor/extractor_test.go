@@ -0,0 +1,822 @@
+package extractor
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/andpalmier/repopsy/internal/git"
+	"github.com/andpalmier/repopsy/internal/progress"
+)
+
+func testCommit() git.Commit {
+	return git.Commit{
+		Hash:       "abc1234000000000000000000000000000000000",
+		ShortHash:  "abc1234",
+		AuthorDate: time.Date(2023, 12, 5, 14, 30, 22, 0, time.Local),
+		Subject:    "Fix path/handling in src/main.go",
+	}
+}
+
+func TestRenderFolderName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		index    int
+		want     string
+	}{
+		{
+			name:     "default",
+			template: "",
+			want:     "20231205_143022_abc1234",
+		},
+		{
+			name:     "sequence number",
+			template: `{{printf "%04d" .Index}}_{{.Commit.ShortHash}}`,
+			index:    7,
+			want:     "0007_abc1234",
+		},
+		{
+			name:     "slug with slashes",
+			template: `{{.Commit.ShortHash}}_{{slug .Commit.Subject}}`,
+			want:     "abc1234_fix-path-handling-in-src-main-go",
+		},
+		{
+			name:     "raw subject is sanitized",
+			template: `{{.Commit.Subject}}`,
+			want:     "Fix path_handling in src_main.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseFolderTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("parseFolderTemplate failed: %v", err)
+			}
+			got, err := renderFolderName(tmpl, testCommit(), tt.index)
+			if err != nil {
+				t.Fatalf("renderFolderName failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFolderTemplateInvalid(t *testing.T) {
+	if _, err := parseFolderTemplate("{{.Commit"); err == nil {
+		t.Error("expected error for invalid template, got nil")
+	}
+}
+
+// fakeRepo is a CommitExtractor that records calls and fails selected hashes
+type fakeRepo struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	failures map[string]error
+
+	// blocking maps hashes whose extraction writes a partial file, closes
+	// the channel and then waits for cancellation
+	blocking map[string]chan struct{}
+
+	// dirs are created as directories in each commit folder, e.g. to make
+	// a later write to the same path fail
+	dirs []string
+
+	// prefetch makes GetCommitsMetadata succeed; otherwise it fails and
+	// metadata is gathered per commit
+	prefetch bool
+
+	// empty lists the hashes reported as empty commits
+	empty map[string]bool
+
+	// delay makes each extraction take that long; active and maxActive
+	// count the extractions running at once
+	delay     time.Duration
+	active    int
+	maxActive int
+}
+
+func newFakeRepo(failures map[string]error) *fakeRepo {
+	return &fakeRepo{calls: make(map[string]int), failures: failures}
+}
+
+func (f *fakeRepo) record(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+}
+
+func (f *fakeRepo) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeRepo) ExtractCommit(ctx context.Context, hash, destPath string) error {
+	f.record("ExtractCommit")
+	if f.delay > 0 {
+		f.mu.Lock()
+		f.active++
+		f.maxActive = max(f.maxActive, f.active)
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			f.active--
+			f.mu.Unlock()
+		}()
+		time.Sleep(f.delay)
+	}
+	if err, ok := f.failures[hash]; ok {
+		return err
+	}
+	if err := os.MkdirAll(destPath, 0o755); err != nil {
+		return err
+	}
+	for _, dir := range f.dirs {
+		if err := os.MkdirAll(filepath.Join(destPath, dir), 0o755); err != nil {
+			return err
+		}
+	}
+	if started, ok := f.blocking[hash]; ok {
+		if err := os.WriteFile(filepath.Join(destPath, "partial"), nil, 0o644); err != nil {
+			return err
+		}
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (f *fakeRepo) ExtractCommitFiltered(ctx context.Context, hash, destPath string, _ git.PathFilter) error {
+	return f.ExtractCommit(ctx, hash, destPath)
+}
+
+func (f *fakeRepo) ArchiveCommit(_ context.Context, hash, destFile string, _ git.PathFilter) error {
+	f.record("ArchiveCommit")
+	if err, ok := f.failures[hash]; ok {
+		return err
+	}
+	return os.WriteFile(destFile, []byte(hash), 0o644)
+}
+
+func (f *fakeRepo) GetCommitStats(_ context.Context, _ string) (git.CommitStats, error) {
+	f.record("GetCommitStats")
+	return git.CommitStats{FilesChanged: 1, Insertions: 2, Deletions: 3}, nil
+}
+
+func (f *fakeRepo) GetCommitFullMessage(_ context.Context, hash string) (string, error) {
+	f.record("GetCommitFullMessage")
+	return "message for " + hash, nil
+}
+
+func (f *fakeRepo) GetCommitsMetadata(_ context.Context, hashes []string) (map[string]git.CommitMetadata, error) {
+	f.record("GetCommitsMetadata")
+	if !f.prefetch {
+		return nil, errors.New("prefetch unavailable")
+	}
+	metadata := make(map[string]git.CommitMetadata, len(hashes))
+	for _, hash := range hashes {
+		metadata[hash] = git.CommitMetadata{
+			FullMessage: "prefetched message for " + hash,
+			Stats:       git.CommitStats{FilesChanged: 1, Insertions: 4, Deletions: 5},
+		}
+	}
+	return metadata, nil
+}
+
+func (f *fakeRepo) GetSignatureInfo(_ context.Context, _ string) (git.SignatureInfo, error) {
+	f.record("GetSignatureInfo")
+	return git.SignatureInfo{}, nil
+}
+
+func (f *fakeRepo) GetTagSignature(_ context.Context, _ string) (git.SignatureInfo, error) {
+	f.record("GetTagSignature")
+	return git.SignatureInfo{Status: "N"}, nil
+}
+
+func (f *fakeRepo) GetCommitTreeSizes(_ context.Context, _ string) ([]git.FileSize, error) {
+	f.record("GetCommitTreeSizes")
+	return nil, nil
+}
+
+func (f *fakeRepo) TooLargeFiles(_ context.Context, _ string, _ git.PathFilter) ([]git.FileSize, error) {
+	f.record("TooLargeFiles")
+	return nil, nil
+}
+
+func (f *fakeRepo) GetBinaryChangeBytes(_ context.Context, _ string) (int64, error) {
+	f.record("GetBinaryChangeBytes")
+	return 0, nil
+}
+
+func (f *fakeRepo) IsEmptyCommit(_ context.Context, hash string) (bool, error) {
+	f.record("IsEmptyCommit")
+	return f.empty[hash], nil
+}
+
+func (f *fakeRepo) GetFormatPatch(_ context.Context, hash string) (string, error) {
+	f.record("GetFormatPatch")
+	return "From " + hash + " Mon Sep 17 00:00:00 2001\n", nil
+}
+
+func (f *fakeRepo) CatObject(_ context.Context, hash string) (string, error) {
+	f.record("CatObject")
+	return "tree " + hash + "\n", nil
+}
+
+func (f *fakeRepo) ListTree(_ context.Context, _ string) (string, error) {
+	f.record("ListTree")
+	return "", nil
+}
+
+func (f *fakeRepo) WriteDiff(_ context.Context, _, _, _ string) error {
+	f.record("WriteDiff")
+	return nil
+}
+
+func (f *fakeRepo) FileTimes(_ context.Context, _ string, _ []string) (map[string]time.Time, error) {
+	f.record("FileTimes")
+	return nil, nil
+}
+
+func (f *fakeRepo) GetDiffStat(_ context.Context, _ string) (string, error) {
+	f.record("GetDiffStat")
+	return " file.txt | 1 +\n 1 file changed, 1 insertion(+)\n", nil
+}
+
+func (f *fakeRepo) ExtractSubmodules(_ context.Context, _, _ string) ([]string, error) {
+	f.record("ExtractSubmodules")
+	return nil, nil
+}
+
+func (f *fakeRepo) SmudgeLFS(_ context.Context, _ string) error {
+	f.record("SmudgeLFS")
+	return nil
+}
+
+func (f *fakeRepo) GetFileDiff(_ context.Context, _ string, _ int) (string, bool, error) {
+	f.record("GetFileDiff")
+	return "", false, nil
+}
+
+func (f *fakeRepo) GetTreeMetrics(_ context.Context, _ string) (git.TreeMetrics, error) {
+	f.record("GetTreeMetrics")
+	return git.TreeMetrics{}, nil
+}
+
+// fakeCommits returns n commits with distinct hashes
+func fakeCommits(n int) []git.Commit {
+	commits := make([]git.Commit, n)
+	for i := range commits {
+		hash := fmt.Sprintf("%040d", i)
+		commits[i] = git.Commit{
+			Hash:       hash,
+			ShortHash:  hash[33:],
+			AuthorDate: time.Date(2023, 12, 5, 14, 30, i, 0, time.UTC),
+			Subject:    fmt.Sprintf("Commit %d", i),
+		}
+	}
+	return commits
+}
+
+func TestRunAggregatesErrors(t *testing.T) {
+	commits := fakeCommits(6)
+	errBoom := errors.New("boom")
+	repo := newFakeRepo(map[string]error{
+		commits[1].Hash: errBoom,
+		commits[4].Hash: errBoom,
+	})
+
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   3,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	results, err := ext.Run(context.Background(), commits)
+	if err == nil {
+		t.Fatal("expected aggregated error, got nil")
+	}
+	if !errors.Is(err, errBoom) {
+		t.Errorf("expected aggregated error to wrap the failure, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "2 of 6 extractions failed") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+
+	if len(results) != len(commits) {
+		t.Fatalf("expected %d results, got %d", len(commits), len(results))
+	}
+	var failed int
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+			continue
+		}
+		if r.Commit.FullMessage != "message for "+r.Commit.Hash || r.Commit.Insertions != 2 {
+			t.Errorf("expected metadata to be gathered for %s, got %+v", r.Commit.ShortHash, r.Commit)
+		}
+	}
+	if failed != 2 {
+		t.Errorf("expected 2 failed results, got %d", failed)
+	}
+
+	if got := repo.count("ExtractCommit"); got != len(commits) {
+		t.Errorf("expected %d ExtractCommit calls, got %d", len(commits), got)
+	}
+	if got := repo.count("GetCommitStats"); got != len(commits)-2 {
+		t.Errorf("expected metadata only for successful extractions, got %d GetCommitStats calls", got)
+	}
+}
+
+func TestRunPartialFailure(t *testing.T) {
+	commits := fakeCommits(3)
+	repo := newFakeRepo(map[string]error{commits[1].Hash: errors.New("boom")})
+
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   2,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), commits)
+
+	// The app tells a partial failure from a fatal one by this error
+	if !errors.Is(err, ErrCommitsFailed) {
+		t.Fatalf("expected the error to wrap ErrCommitsFailed, got %v", err)
+	}
+	var succeeded int
+	for _, r := range results {
+		if r.Error == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 2 {
+		t.Errorf("expected 2 commits extracted besides the failed one, got %d", succeeded)
+	}
+}
+
+func TestRunPrefetchesMetadata(t *testing.T) {
+	commits := fakeCommits(prefetchBatchSize + 6)
+	repo := newFakeRepo(nil)
+	repo.prefetch = true
+
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   4,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), commits)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range results {
+		if r.Commit.FullMessage != "prefetched message for "+r.Commit.Hash || r.Commit.Insertions != 4 {
+			t.Errorf("expected prefetched metadata for %s, got %+v", r.Commit.ShortHash, r.Commit)
+		}
+	}
+	if got := repo.count("GetCommitsMetadata"); got != 2 {
+		t.Errorf("expected 2 GetCommitsMetadata calls, got %d", got)
+	}
+	for _, method := range []string{"GetCommitFullMessage", "GetCommitStats"} {
+		if n := repo.count(method); n != 0 {
+			t.Errorf("expected no %s calls, got %d", method, n)
+		}
+	}
+}
+
+func TestRunDropsUnusedPrefetchedMetadata(t *testing.T) {
+	commits := fakeCommits(6)
+	repo := newFakeRepo(map[string]error{commits[1].Hash: errors.New("boom")})
+	repo.prefetch = true
+	repo.empty = map[string]bool{commits[3].Hash: true, commits[4].Hash: true}
+
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   2,
+		SkipEmpty: true,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, _ := ext.Run(context.Background(), commits)
+	if len(results) != len(commits) {
+		t.Fatalf("expected %d results, got %d", len(commits), len(results))
+	}
+
+	// Failed and skipped commits never consume their metadata, which must
+	// not outlive them
+	if n := len(ext.prefetched); n != 0 {
+		t.Errorf("expected no prefetched metadata left after the run, got %d entries", n)
+	}
+}
+
+func TestRunSortsResultsByIndex(t *testing.T) {
+	commits := fakeCommits(40)
+	repo := newFakeRepo(map[string]error{commits[5].Hash: errors.New("boom")})
+	repo.delay = time.Millisecond
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   8,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, _ := ext.Run(context.Background(), commits)
+
+	if len(results) != len(commits) {
+		t.Fatalf("expected %d results, got %d", len(commits), len(results))
+	}
+	for i, r := range results {
+		if r.Index != i || r.Commit.Hash != commits[i].Hash {
+			t.Fatalf("expected result %d to be commit %d, got index %d (%s)", i, i, r.Index, r.Commit.ShortHash)
+		}
+	}
+}
+
+func TestRunRecordsDuration(t *testing.T) {
+	commits := fakeCommits(3)
+	ext, err := New(newFakeRepo(nil), Config{
+		OutputDir: t.TempDir(),
+		Workers:   2,
+		Reporter:  progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), commits)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range results {
+		if r.Duration <= 0 {
+			t.Errorf("%s: expected a positive duration, got %s", r.Commit.ShortHash, r.Duration)
+		}
+		if r.MetadataDuration <= 0 || r.MetadataDuration > r.Duration {
+			t.Errorf("%s: expected metadata duration within %s, got %s", r.Commit.ShortHash, r.Duration, r.MetadataDuration)
+		}
+	}
+}
+
+func TestRunCapsGitProcs(t *testing.T) {
+	const maxProcs = 2
+	commits := fakeCommits(12)
+	repo := newFakeRepo(nil)
+	repo.delay = 10 * time.Millisecond
+	procs := semaphore.NewWeighted(maxProcs)
+
+	ext, err := New(repo, Config{
+		OutputDir:  t.TempDir(),
+		Workers:    8,
+		NoMetadata: true,
+		GitProcs:   procs,
+		Reporter:   progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := ext.Run(context.Background(), commits); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if repo.maxActive == 0 || repo.maxActive > maxProcs {
+		t.Errorf("expected at most %d extractions at once, got %d", maxProcs, repo.maxActive)
+	}
+
+	// A cancelled run must give every token back
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	ext, err = New(repo, Config{
+		OutputDir:  t.TempDir(),
+		Workers:    8,
+		NoMetadata: true,
+		GitProcs:   procs,
+		Reporter:   progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, _ = ext.Run(ctx, commits)
+	if !procs.TryAcquire(maxProcs) {
+		t.Error("expected all tokens to be released after cancellation")
+	}
+}
+
+func TestRunDisambiguatesFolderCollisions(t *testing.T) {
+	commits := fakeCommits(3)
+	outDir := t.TempDir()
+
+	// A constant template maps every commit to the same folder name
+	ext, err := New(newFakeRepo(nil), Config{
+		OutputDir:      outDir,
+		Workers:        3,
+		FolderTemplate: "snapshot",
+		Reporter:       progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	results, err := ext.Run(context.Background(), commits)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if seen[r.OutputPath] {
+			t.Errorf("folder %s used by more than one commit", r.OutputPath)
+		}
+		seen[r.OutputPath] = true
+	}
+
+	for _, name := range []string{"snapshot", "snapshot_2", "snapshot_3"} {
+		if _, err := os.Stat(filepath.Join(outDir, name, "COMMIT_INFO.txt")); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+}
+
+func TestRunCleanupOnInterrupt(t *testing.T) {
+	commits := fakeCommits(2)
+	started := make(chan struct{})
+	repo := newFakeRepo(nil)
+	repo.blocking = map[string]chan struct{}{commits[1].Hash: started}
+
+	outDir := t.TempDir()
+	ext, err := New(repo, Config{
+		OutputDir:          outDir,
+		Workers:            1,
+		CleanupOnInterrupt: true,
+		Reporter:           progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A single worker completes the first commit before blocking on the second
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	results, _ := ext.Run(ctx, commits)
+
+	completed := filepath.Join(outDir, "20231205_143000_0000000")
+	interrupted := filepath.Join(outDir, "20231205_143001_0000001")
+
+	if _, err := os.Stat(filepath.Join(completed, "COMMIT_INFO.txt")); err != nil {
+		t.Errorf("expected completed folder to survive: %v", err)
+	}
+	if _, err := os.Stat(interrupted); !os.IsNotExist(err) {
+		t.Errorf("expected interrupted folder to be removed, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+}
+
+func TestRunPerCommitTimeout(t *testing.T) {
+	commits := fakeCommits(3)
+	repo := newFakeRepo(nil)
+	repo.blocking = map[string]chan struct{}{commits[1].Hash: make(chan struct{})}
+
+	ext, err := New(repo, Config{
+		OutputDir:        t.TempDir(),
+		Workers:          2,
+		PerCommitTimeout: 50 * time.Millisecond,
+		Reporter:         progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	results, err := ext.Run(context.Background(), commits)
+	if err == nil || !strings.HasPrefix(err.Error(), "1 of 3 extractions failed") {
+		t.Fatalf("expected a single failure, got %v", err)
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Commit.Hash == commits[1].Hash:
+			if !errors.Is(r.Error, context.DeadlineExceeded) {
+				t.Errorf("expected timeout error for slow commit, got %v", r.Error)
+			}
+		case r.Error != nil:
+			t.Errorf("expected %s to succeed, got %v", r.Commit.ShortHash, r.Error)
+		}
+	}
+}
+
+func TestRunNoMetadata(t *testing.T) {
+	repo := newFakeRepo(nil)
+	outDir := t.TempDir()
+
+	ext, err := New(repo, Config{
+		OutputDir:  outDir,
+		Workers:    2,
+		NoMetadata: true,
+		Reporter:   progress.New(progress.Config{Total: 3, Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), fakeCommits(3))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range results {
+		if _, err := os.Stat(filepath.Join(r.OutputPath, "COMMIT_INFO.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no COMMIT_INFO.txt, got %v", r.Commit.ShortHash, err)
+		}
+	}
+	for _, method := range []string{"GetCommitsMetadata", "GetCommitFullMessage", "GetCommitStats", "GetTagSignature"} {
+		if n := repo.count(method); n != 0 {
+			t.Errorf("expected no %s calls, got %d", method, n)
+		}
+	}
+	if n := repo.count("ExtractCommit"); n != 3 {
+		t.Errorf("expected 3 ExtractCommit calls, got %d", n)
+	}
+}
+
+func TestRunTagSignatures(t *testing.T) {
+	commits := fakeCommits(4)
+	commits[1].Tag = "v1.0.0"
+	commits[3].Tag = "v2.0.0"
+	repo := newFakeRepo(nil)
+
+	ext, err := New(repo, Config{
+		OutputDir:   t.TempDir(),
+		Workers:     2,
+		AllowedKeys: git.KeyAllowlist{"0000000000000000"},
+		Reporter:    progress.New(progress.Config{Total: len(commits), Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), commits)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Tag signatures are verified by the workers, for tagged commits only
+	if n := repo.count("GetTagSignature"); n != 2 {
+		t.Errorf("expected 2 GetTagSignature calls, got %d", n)
+	}
+	for _, r := range results {
+		if r.Commit.Tag == "" {
+			if r.Commit.TagSignature != "" || r.Commit.TagTrust != "" {
+				t.Errorf("%s: expected no tag signature, got %q (%q)", r.Commit.ShortHash, r.Commit.TagSignature, r.Commit.TagTrust)
+			}
+			continue
+		}
+		if r.Commit.TagSignature != "N" || r.Commit.TagTrust != git.TrustUnsigned {
+			t.Errorf("%s: expected an unsigned tag, got %q (%q)", r.Commit.ShortHash, r.Commit.TagSignature, r.Commit.TagTrust)
+		}
+	}
+}
+
+func TestRunMarksIncompleteFolders(t *testing.T) {
+	// A directory in place of COMMIT_INFO.txt makes the metadata write
+	// fail even for root, which a read-only folder would not
+	repo := newFakeRepo(nil)
+	repo.dirs = []string{"COMMIT_INFO.txt"}
+
+	ext, err := New(repo, Config{
+		OutputDir: t.TempDir(),
+		Workers:   1,
+		Reporter:  progress.New(progress.Config{Total: 1, Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), fakeCommits(1))
+	if err == nil {
+		t.Fatal("expected the metadata write failure to be reported")
+	}
+
+	marker, err := os.ReadFile(filepath.Join(results[0].OutputPath, "INCOMPLETE"))
+	if err != nil {
+		t.Fatalf("expected an INCOMPLETE marker in the failed folder: %v", err)
+	}
+	if !strings.Contains(string(marker), "metadata write failed") {
+		t.Errorf("expected the marker to hold the error, got %q", marker)
+	}
+}
+
+func TestWriteChecksums(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"COMMIT_INFO.txt": "metadata",
+		"main.go":         "package main",
+		"docs/guide.md":   "# Guide",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("main.go", filepath.Join(dir, "link.go")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	if err := writeChecksums(dir); err != nil {
+		t.Fatalf("writeChecksums failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ChecksumsFileName))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			t.Fatalf("malformed manifest line: %q", line)
+		}
+		got[name] = sum
+	}
+
+	if len(got) != len(files) {
+		t.Errorf("expected %d entries (no symlinks, no manifest), got %d:\n%s", len(files), len(got), data)
+	}
+	for name, content := range files {
+		want := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		if got[name] != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got[name])
+		}
+	}
+}
+
+func TestRunXattrs(t *testing.T) {
+	probe := t.TempDir()
+	if err := setXattr(probe, xattrPrefix+"probe", "1"); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+
+	repo := newFakeRepo(nil)
+	ext, err := New(repo, Config{
+		OutputDir:  t.TempDir(),
+		Workers:    2,
+		NoMetadata: true,
+		Xattrs:     true,
+		Reporter:   progress.New(progress.Config{Total: 2, Writer: io.Discard}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	results, err := ext.Run(context.Background(), fakeCommits(2))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range results {
+		if r.XattrsSkipped {
+			t.Fatalf("%s: extended attributes were skipped", r.Commit.ShortHash)
+		}
+		for name, want := range map[string]string{"hash": r.Commit.Hash, "subject": r.Commit.Subject} {
+			got, err := getXattr(r.OutputPath, xattrPrefix+name)
+			if err != nil {
+				t.Fatalf("%s: failed to read %s: %v", r.Commit.ShortHash, name, err)
+			}
+			if got != want {
+				t.Errorf("%s: expected %s %q, got %q", r.Commit.ShortHash, name, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// setFileTimes sets the modification time of every regular file extracted
+// into outputPath to the author date of the last commit that changed it;
+// files outside the commit's tree, such as submodule contents and reports,
+// keep theirs. It leaves the times alone and reports true for trees of more than
+// FileTimesMaxFiles files, whose history walk would be too costly.
+func (e *Extractor) setFileTimes(ctx context.Context, commit git.Commit, outputPath string) (bool, error) {
+	var paths []string
+	err := filepath.WalkDir(outputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(outputPath, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list extracted files: %w", err)
+	}
+	if limit := e.config.FileTimesMaxFiles; limit > 0 && len(paths) > limit {
+		e.config.Logger.Warn("file times not set, too many files",
+			"hash", commit.ShortHash, "files", len(paths), "limit", limit)
+		return true, nil
+	}
+
+	times, err := e.repo.FileTimes(ctx, commit.Hash, paths)
+	if err != nil {
+		return false, err
+	}
+	for path, t := range times {
+		if err := os.Chtimes(filepath.Join(outputPath, filepath.FromSlash(path)), t, t); err != nil {
+			return false, fmt.Errorf("failed to set time of %s: %w", path, err)
+		}
+	}
+	return false, nil
+}
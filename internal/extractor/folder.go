@@ -0,0 +1,91 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// maxSlugLength caps the length of slugs generated from commit subjects
+const maxSlugLength = 50
+
+// folderFuncs are the helper functions available in folder name templates
+var folderFuncs = template.FuncMap{
+	"slug": Slugify,
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+}
+
+// folderData is the data exposed to folder name templates
+type folderData struct {
+	Index  int
+	Commit git.Commit
+}
+
+// parseFolderTemplate parses a folder name template, falling back to the default
+func parseFolderTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = config.DefaultFolderTemplate
+	}
+	tmpl, err := template.New("folder").Funcs(folderFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderFolderName renders the folder name for a commit and makes it filesystem-safe
+func renderFolderName(tmpl *template.Template, commit git.Commit, index int) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, folderData{Index: index, Commit: commit}); err != nil {
+		return "", fmt.Errorf("failed to render folder name: %w", err)
+	}
+
+	name := sanitizeFolderName(buf.String())
+	if name == "" {
+		return commit.ShortHash, nil
+	}
+	return name, nil
+}
+
+// sanitizeFolderName replaces characters that are unsafe in file names
+func sanitizeFolderName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Leading/trailing dots and spaces are problematic on some filesystems
+	// and "." or ".." would escape the output directory
+	return strings.Trim(name, ". ")
+}
+
+// Slugify converts text to a lowercase, dash-separated slug of at most
+// 50 characters, as the slug helper of folder templates does
+func Slugify(text string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+		if b.Len() >= maxSlugLength {
+			break
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
@@ -0,0 +1,57 @@
+package extractor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// normalizeTree makes the files under root independent of the extraction
+// run: every file and directory gets mtime as modification time, directories
+// and executables mode 0755 and other files mode 0644. Symlinks are left
+// as they are. root may also be a single file, such as a flattened archive.
+func normalizeTree(root string, mtime time.Time) error {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := fs.FileMode(0o644)
+		if d.IsDir() || info.Mode()&0o111 != 0 {
+			mode = 0o755
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("failed to normalize mode: %w", err)
+		}
+
+		// Directory times are set last, once nothing changes inside them
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return fmt.Errorf("failed to set modification time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i], mtime, mtime); err != nil {
+			return fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package extractor
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// throttledRepo is a CommitExtractor holding one token of procs for the
+// duration of each call, each of which runs a git subprocess. Sharing procs
+// caps the git processes of every worker, and of every extractor given it,
+// independently of the worker count. Tokens are released when the call
+// returns, including when it is cancelled.
+type throttledRepo struct {
+	repo  CommitExtractor
+	procs *semaphore.Weighted
+}
+
+// throttled runs call once a token of procs is available
+func throttled[T any](ctx context.Context, procs *semaphore.Weighted, call func() (T, error)) (T, error) {
+	if err := procs.Acquire(ctx, 1); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer procs.Release(1)
+	return call()
+}
+
+// throttledErr is throttled for calls returning only an error
+func throttledErr(ctx context.Context, procs *semaphore.Weighted, call func() error) error {
+	_, err := throttled(ctx, procs, func() (struct{}, error) { return struct{}{}, call() })
+	return err
+}
+
+func (t throttledRepo) ExtractCommit(ctx context.Context, hash, destPath string) error {
+	return throttledErr(ctx, t.procs, func() error { return t.repo.ExtractCommit(ctx, hash, destPath) })
+}
+
+func (t throttledRepo) ExtractCommitFiltered(ctx context.Context, hash, destPath string, filter git.PathFilter) error {
+	return throttledErr(ctx, t.procs, func() error { return t.repo.ExtractCommitFiltered(ctx, hash, destPath, filter) })
+}
+
+func (t throttledRepo) ArchiveCommit(ctx context.Context, hash, destFile string, filter git.PathFilter) error {
+	return throttledErr(ctx, t.procs, func() error { return t.repo.ArchiveCommit(ctx, hash, destFile, filter) })
+}
+
+func (t throttledRepo) GetCommitStats(ctx context.Context, hash string) (git.CommitStats, error) {
+	return throttled(ctx, t.procs, func() (git.CommitStats, error) { return t.repo.GetCommitStats(ctx, hash) })
+}
+
+func (t throttledRepo) GetCommitsMetadata(ctx context.Context, hashes []string) (map[string]git.CommitMetadata, error) {
+	return throttled(ctx, t.procs, func() (map[string]git.CommitMetadata, error) { return t.repo.GetCommitsMetadata(ctx, hashes) })
+}
+
+func (t throttledRepo) GetCommitFullMessage(ctx context.Context, hash string) (string, error) {
+	return throttled(ctx, t.procs, func() (string, error) { return t.repo.GetCommitFullMessage(ctx, hash) })
+}
+
+func (t throttledRepo) GetSignatureInfo(ctx context.Context, hash string) (git.SignatureInfo, error) {
+	return throttled(ctx, t.procs, func() (git.SignatureInfo, error) { return t.repo.GetSignatureInfo(ctx, hash) })
+}
+
+func (t throttledRepo) GetTagSignature(ctx context.Context, tag string) (git.SignatureInfo, error) {
+	return throttled(ctx, t.procs, func() (git.SignatureInfo, error) { return t.repo.GetTagSignature(ctx, tag) })
+}
+
+func (t throttledRepo) FileTimes(ctx context.Context, hash string, paths []string) (map[string]time.Time, error) {
+	return throttled(ctx, t.procs, func() (map[string]time.Time, error) { return t.repo.FileTimes(ctx, hash, paths) })
+}
+
+func (t throttledRepo) GetCommitTreeSizes(ctx context.Context, hash string) ([]git.FileSize, error) {
+	return throttled(ctx, t.procs, func() ([]git.FileSize, error) { return t.repo.GetCommitTreeSizes(ctx, hash) })
+}
+
+func (t throttledRepo) TooLargeFiles(ctx context.Context, hash string, filter git.PathFilter) ([]git.FileSize, error) {
+	return throttled(ctx, t.procs, func() ([]git.FileSize, error) { return t.repo.TooLargeFiles(ctx, hash, filter) })
+}
+
+func (t throttledRepo) GetBinaryChangeBytes(ctx context.Context, hash string) (int64, error) {
+	return throttled(ctx, t.procs, func() (int64, error) { return t.repo.GetBinaryChangeBytes(ctx, hash) })
+}
+
+func (t throttledRepo) GetFileDiff(ctx context.Context, hash string, maxLines int) (string, bool, error) {
+	var diff string
+	var truncated bool
+	err := throttledErr(ctx, t.procs, func() (err error) {
+		diff, truncated, err = t.repo.GetFileDiff(ctx, hash, maxLines)
+		return err
+	})
+	return diff, truncated, err
+}
+
+func (t throttledRepo) GetTreeMetrics(ctx context.Context, hash string) (git.TreeMetrics, error) {
+	return throttled(ctx, t.procs, func() (git.TreeMetrics, error) { return t.repo.GetTreeMetrics(ctx, hash) })
+}
+
+func (t throttledRepo) IsEmptyCommit(ctx context.Context, hash string) (bool, error) {
+	return throttled(ctx, t.procs, func() (bool, error) { return t.repo.IsEmptyCommit(ctx, hash) })
+}
+
+func (t throttledRepo) GetFormatPatch(ctx context.Context, hash string) (string, error) {
+	return throttled(ctx, t.procs, func() (string, error) { return t.repo.GetFormatPatch(ctx, hash) })
+}
+
+func (t throttledRepo) GetDiffStat(ctx context.Context, hash string) (string, error) {
+	return throttled(ctx, t.procs, func() (string, error) { return t.repo.GetDiffStat(ctx, hash) })
+}
+
+func (t throttledRepo) WriteDiff(ctx context.Context, from, to, destFile string) error {
+	return throttledErr(ctx, t.procs, func() error { return t.repo.WriteDiff(ctx, from, to, destFile) })
+}
+
+func (t throttledRepo) CatObject(ctx context.Context, hash string) (string, error) {
+	return throttled(ctx, t.procs, func() (string, error) { return t.repo.CatObject(ctx, hash) })
+}
+
+func (t throttledRepo) ListTree(ctx context.Context, hash string) (string, error) {
+	return throttled(ctx, t.procs, func() (string, error) { return t.repo.ListTree(ctx, hash) })
+}
+
+func (t throttledRepo) SmudgeLFS(ctx context.Context, path string) error {
+	return throttledErr(ctx, t.procs, func() error { return t.repo.SmudgeLFS(ctx, path) })
+}
+
+func (t throttledRepo) ExtractSubmodules(ctx context.Context, hash, destPath string) ([]string, error) {
+	return throttled(ctx, t.procs, func() ([]string, error) { return t.repo.ExtractSubmodules(ctx, hash, destPath) })
+}
+
+// pause waits for d between two jobs of a worker, returning early when ctx
+// is cancelled
+func pause(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,51 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// xattrPrefix namespaces the extended attributes written for each commit
+const xattrPrefix = "user.repopsy."
+
+// errXattrUnsupported is returned when the platform or filesystem cannot
+// store extended attributes
+var errXattrUnsupported = errors.New("extended attributes are not supported")
+
+// commitXattrs returns the commit fields stored as extended attributes,
+// keyed by attribute name. They only use fields listed by git log, so they
+// are available even without the metadata file.
+func commitXattrs(commit git.Commit) map[string]string {
+	return map[string]string{
+		xattrPrefix + "hash":         commit.Hash,
+		xattrPrefix + "author":       commit.Author,
+		xattrPrefix + "author_email": commit.AuthorEmail,
+		xattrPrefix + "author_date":  commit.AuthorDate.Format(time.RFC3339),
+		xattrPrefix + "committer":    commit.Committer,
+		xattrPrefix + "commit_date":  commit.CommitDate.Format(time.RFC3339),
+		xattrPrefix + "subject":      commit.Subject,
+		xattrPrefix + "parents":      strings.Join(commit.ParentHashes, " "),
+		xattrPrefix + "signature":    commit.GPGSignature,
+	}
+}
+
+// writeXattrs stores the commit fields as extended attributes of path. It
+// returns errXattrUnsupported when they cannot be stored there.
+func writeXattrs(path string, commit git.Commit) error {
+	for name, value := range commitXattrs(commit) {
+		if value == "" {
+			continue
+		}
+		if err := setXattr(path, name, value); err != nil {
+			if errors.Is(err, errXattrUnsupported) {
+				return err
+			}
+			return fmt.Errorf("failed to set extended attribute %s: %w", name, err)
+		}
+	}
+	return nil
+}
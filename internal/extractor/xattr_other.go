@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package extractor
+
+// setXattr reports that extended attributes are not supported here
+func setXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
+
+// getXattr reports that extended attributes are not supported here
+func getXattr(path, name string) (string, error) {
+	return "", errXattrUnsupported
+}
@@ -0,0 +1,31 @@
+//go:build linux || darwin
+
+package extractor
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// setXattr sets the extended attribute name of path to value
+func setXattr(path, name, value string) error {
+	err := unix.Setxattr(path, name, []byte(value), 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errXattrUnsupported
+	}
+	return err
+}
+
+// getXattr reads the extended attribute name of path
+func getXattr(path, name string) (string, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Getxattr(path, name, buf); err != nil {
+		return "", err
+	}
+	return string(buf[:size]), nil
+}
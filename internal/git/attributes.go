@@ -0,0 +1,87 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// exportIgnoreOverride returns a throwaway git directory that borrows the
+// repository's objects and disables export-ignore in info/attributes, which
+// takes precedence over the .gitattributes files of the archived tree.
+// It is created on first use and removed by Close.
+func (r *Repository) exportIgnoreOverride() (string, error) {
+	r.overrideOnce.Do(func() {
+		var format string
+		if format, r.overrideErr = r.objectFormat(); r.overrideErr == nil {
+			r.overrideDir, r.overrideErr = createExportIgnoreOverride(filepath.Join(r.CommonDir, "objects"), format)
+		}
+	})
+	return r.overrideDir, r.overrideErr
+}
+
+// objectFormat returns the hash algorithm of the repository's objects set
+// by extensions.objectFormat, or "" for the default SHA-1
+func (r *Repository) objectFormat() (string, error) {
+	out, err := r.output(r.newGitCmd(context.Background(), "config", "--get", "extensions.objectformat"))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Exit status 1: the key is not set
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read object format: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// createExportIgnoreOverride creates a minimal bare git directory using
+// objectsDir as its object store through an alternates file. A non-empty
+// objectFormat is recorded in its config, as git cannot read the objects of
+// a SHA-256 repository without it.
+func createExportIgnoreOverride(objectsDir, objectFormat string) (string, error) {
+	dir, err := os.MkdirTemp("", "repopsy-attrs-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create attributes override: %w", err)
+	}
+
+	files := map[string]string{
+		"HEAD":                    "ref: refs/heads/main\n",
+		"objects/info/alternates": objectsDir + "\n",
+		"info/attributes":         "* -export-ignore\n",
+		"refs/heads/.keep":        "",
+		"config":                  "[core]\n\trepositoryformatversion = 0\n\tbare = true\n",
+	}
+	if objectFormat != "" {
+		files["config"] = "[core]\n\trepositoryformatversion = 1\n\tbare = true\n[extensions]\n\tobjectformat = " + objectFormat + "\n"
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, []byte(content), 0o644)
+		}
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to create attributes override: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// Close removes temporary state created for the repository. The repository
+// stays usable: the state is created again when needed. Close must not run
+// concurrently with an extraction.
+func (r *Repository) Close() error {
+	dir := r.overrideDir
+	r.overrideOnce = sync.Once{}
+	r.overrideDir, r.overrideErr = "", nil
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,57 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitEnv is the environment of every git command: the process environment
+// with credential prompts disabled, so that a repository needing
+// authentication fails instead of hanging the run, and with messages in the
+// C locale for stable parsing
+func gitEnv() []string {
+	return append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+}
+
+// newGitCmd returns a git command for args running in the repository with
+// the git environment and no time limit
+func (r *Repository) newGitCmd(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Path
+	cmd.Env = gitEnv()
+	return cmd
+}
+
+// gitCommand returns a git command for args like newGitCmd, killed after
+// r.Timeout when one is set. The returned function releases the timeout and
+// must be called once the command finished.
+func (r *Repository) gitCommand(ctx context.Context, args ...string) (*exec.Cmd, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	}
+	return r.newGitCmd(ctx, args...), cancel
+}
+
+// timeoutError explains the failure of a command that ran for the whole
+// r.Timeout, which is what killed it
+func (r *Repository) timeoutError(start time.Time, err error) error {
+	if err != nil && r.Timeout > 0 && time.Since(start) >= r.Timeout {
+		return fmt.Errorf("timed out after %s: %w", r.Timeout, err)
+	}
+	return err
+}
+
+// commandError describes a failed command by its standard error, or by err
+// when it wrote nothing, as when it was killed
+func commandError(stderr *bytes.Buffer, err error) string {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
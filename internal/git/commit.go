@@ -3,6 +3,7 @@ package git
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"text/template"
@@ -15,6 +16,12 @@ const metadataTemplateStr = `COMMIT INFORMATION
 
 Hash:           {{.Hash}}
 Short Hash:     {{.ShortHash}}
+{{- if .ReflogSelector}}
+Reflog:         {{.ReflogSelector}}{{end}}
+{{- if .Tag}}
+Tag:            {{.Tag}}{{end}}
+{{- if .Role}}
+Role:           {{.Role}}{{end}}
 
 AUTHOR (who wrote the code)
 ---------------------------
@@ -35,6 +42,20 @@ NOTE: Author and Committer are different.
 VERIFICATION
 ------------
 GPG Signature:  {{.GPGSignature | formatGPGStatus}}
+{{- if .GPGKeyID}}
+Key ID:         {{.GPGKeyID}}{{end}}
+{{- if .GPGSigner}}
+Signer:         {{.GPGSigner}}{{end}}
+{{- if .Trust}}
+Trust:          {{.Trust}}{{end}}
+{{- if .Tag}}
+Tag Signature:  {{.TagSignature | formatGPGStatus}}
+{{- if .TagKeyID}}
+Tag Key ID:     {{.TagKeyID}}{{end}}
+{{- if .TagSigner}}
+Tag Signer:     {{.TagSigner}}{{end}}
+{{- if .TagTrust}}
+Tag Trust:      {{.TagTrust}}{{end}}{{end}}
 
 LINEAGE
 -------
@@ -45,7 +66,19 @@ CHANGE STATISTICS
 Files Changed:  {{.FilesChanged}}
 Insertions:     +{{.Insertions}}
 Deletions:      -{{.Deletions}}
-
+{{- if .BinaryBytes}}
+WARNING:        {{.BinaryBytes}} bytes of binary files added or modified{{end}}
+{{with .TreeMetrics}}
+TREE METRICS
+------------
+Total Files:    {{.FileCount}}
+Max Depth:      {{.MaxDepth}}
+{{end}}
+{{- with .Trailers}}
+TRAILERS
+--------
+{{range $key, $values := .}}{{range $values}}{{$key}}: {{.}}
+{{end}}{{end}}{{end}}
 COMMIT MESSAGE
 --------------
 Subject:
@@ -53,11 +86,56 @@ Subject:
 
 Full Message:
 {{.FullMessage}}
-`
-
-var metadataTemplate = template.Must(template.New("metadata").Funcs(template.FuncMap{
+{{with .Diff}}
+DIFF
+----
+{{.}}{{end}}{{if .DiffTruncated}}[diff truncated]
+{{end}}`
+
+// metadataFuncs are the functions available to metadata templates
+var metadataFuncs = template.FuncMap{
 	"formatGPGStatus": formatGPGStatus,
-}).Parse(metadataTemplateStr))
+}
+
+var metadataTemplate = template.Must(template.New("metadata").Funcs(metadataFuncs).Parse(metadataTemplateStr))
+
+// LoadMetadataTemplate parses the text/template file at path to replace the
+// built-in COMMIT_INFO.txt layout. The template sees the same Commit fields
+// and functions; it is executed against a fully populated sample commit so
+// that references to unknown fields fail here rather than during extraction.
+func LoadMetadataTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(metadataFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata template: %w", err)
+	}
+
+	sample := Commit{
+		ParentHashes:   []string{"0000000000000000000000000000000000000000"},
+		Trailers:       map[string][]string{"Signed-off-by": {"-"}},
+		GPGSignature:   "G",
+		GPGKeyID:       "-",
+		GPGSigner:      "-",
+		Trust:          "-",
+		ReflogSelector: "-",
+		Tag:            "-",
+		TagSignature:   "G",
+		TagKeyID:       "-",
+		TagSigner:      "-",
+		TagTrust:       "-",
+		Role:           "-",
+		Diff:           "-",
+		DiffTruncated:  true,
+		TreeMetrics:    &TreeMetrics{},
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid metadata template: %w", err)
+	}
+	return tmpl, nil
+}
 
 // Commit represents a single git commit with its metadata
 type Commit struct {
@@ -72,10 +150,31 @@ type Commit struct {
 	Subject        string
 	ParentHashes   []string
 	FullMessage    string
+	Trailers       map[string][]string // Message trailers by token, set with the full message
 	GPGSignature   string
+	GPGKeyID       string
+	GPGSigner      string
+	GPGRaw         string
+	Trust          string // Allowed-keys classification, set only when checked
+	ReflogSelector string // Reflog entry such as HEAD@{3}, set only for reflog listings
+	Tag            string // Tag name, set only for tag listings
+	TagSignature   string // Tag signature status as a %G? letter, set only when extracting tags
+	TagKeyID       string
+	TagSigner      string
+	TagTrust       string // Allowed-keys classification of the tag signature
+	Role           string // base, tipA or tipB, set only for merge-base listings
 	FilesChanged   int
 	Insertions     int
 	Deletions      int
+	TreeMetrics    *TreeMetrics // Set only when tree metrics were requested
+	BinaryBytes    int64        // Binary bytes added or modified, set only above a warning threshold
+	Diff           string       // Patch against the first parent, set only when requested
+	DiffTruncated  bool         // Diff was cut at the line cap
+}
+
+// IsSigned reports whether the commit carries a signature of any status
+func (c Commit) IsSigned() bool {
+	return c.GPGSignature != "" && c.GPGSignature != "N"
 }
 
 // String returns a human-readable representation of the commit
@@ -84,8 +183,22 @@ func (c Commit) String() string {
 }
 
 // WriteMetadataFile writes a COMMIT_INFO.txt file with commit metadata
-func (c Commit) WriteMetadataFile(destPath string) (err error) {
-	metadataPath := filepath.Join(destPath, "COMMIT_INFO.txt")
+func (c Commit) WriteMetadataFile(destPath string) error {
+	return c.WriteMetadataTo(filepath.Join(destPath, "COMMIT_INFO.txt"))
+}
+
+// WriteMetadataTo writes the commit metadata to the file at metadataPath
+func (c Commit) WriteMetadataTo(metadataPath string) error {
+	return c.WriteMetadataWith(nil, metadataPath)
+}
+
+// WriteMetadataWith writes the commit metadata to the file at metadataPath
+// using tmpl, or the built-in template when tmpl is nil
+func (c Commit) WriteMetadataWith(tmpl *template.Template, metadataPath string) (err error) {
+	if tmpl == nil {
+		tmpl = metadataTemplate
+	}
+
 	f, err := os.Create(metadataPath)
 	if err != nil {
 		return fmt.Errorf("failed to create metadata file: %w", err)
@@ -99,7 +212,7 @@ func (c Commit) WriteMetadataFile(destPath string) (err error) {
 	}()
 
 	// Execute the template against the metadata
-	if execErr := metadataTemplate.Execute(f, c); execErr != nil {
+	if execErr := tmpl.Execute(f, c); execErr != nil {
 		return fmt.Errorf("failed to execute metadata template: %w", execErr)
 	}
 
@@ -5,11 +5,14 @@ import (
 	"bufio"
 	"bytes"
 	"context" // added context
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
 )
 
 // ListOptions configures how commits are listed from the repository
@@ -17,40 +20,96 @@ type ListOptions struct {
 	Branch  string
 	Limit   int
 	Reverse bool
+
+	// Refs lists further starting points; commits reachable from any of
+	// them, or from Branch, are listed once
+	Refs []string
+
+	// UseMailmap canonicalizes author/committer identities through .mailmap
+	UseMailmap bool
+
+	// FirstParent follows only the first parent of merge commits (mainline history)
+	FirstParent bool
+
+	// GrepPatterns limits commits to those whose message matches any of
+	// the regexes
+	GrepPatterns []string
+	// GrepAllMatch requires all GrepPatterns to match (git log --all-match)
+	GrepAllMatch bool
+	// GrepIgnoreCase matches GrepPatterns case-insensitively
+	GrepIgnoreCase bool
+
+	// PickaxeString limits commits to those changing the number of
+	// occurrences of the string (git log -S)
+	PickaxeString string
+	// PickaxeRegex limits commits to those whose diff adds or removes a
+	// line matching the regex (git log -G)
+	PickaxeRegex string
+
+	// DateOrder orders commits by DateAuthor (git log --author-date-order)
+	// or DateCommit (--date-order) timestamps, parents always after their
+	// children. Empty keeps git's default order.
+	DateOrder string
 }
 
+// Date sources for ListOptions.DateOrder
+const (
+	DateAuthor = "author"
+	DateCommit = "commit"
+)
+
 // ListCommits returns a list of commits based on the provided options
 func (r *Repository) ListCommits(ctx context.Context, opts ListOptions) ([]Commit, error) {
-	args := []string{
-		"log",
-		"--format=%H%x00%h%x00%an%x00%ae%x00%at%x00%cn%x00%ce%x00%ct%x00%G?%x00%P%x00%s",
-	}
+	stream, errc := r.ListCommitsChan(ctx, opts)
 
-	if opts.Limit > 0 {
-		args = append(args, fmt.Sprintf("-n%d", opts.Limit))
+	var commits []Commit
+	for commit := range stream {
+		commits = append(commits, commit)
 	}
-
-	if opts.Reverse {
-		args = append(args, "--reverse")
+	if err := <-errc; err != nil {
+		return nil, err
 	}
+	return commits, nil
+}
 
-	if opts.Branch != "" {
-		args = append(args, opts.Branch)
-	}
+// ListCommitsChan streams the commits selected by opts as git log produces
+// them, so that long histories need not be held in memory. The commit
+// channel is closed when the listing ends; the error channel then yields
+// a single value, nil on success. Cancelling ctx stops git log and ends the
+// listing with ctx.Err().
+func (r *Repository) ListCommitsChan(ctx context.Context, opts ListOptions) (<-chan Commit, <-chan error) {
+	commits := make(chan Commit)
+	errc := make(chan error, 1)
+	go func() {
+		err := r.streamCommits(ctx, opts, commits)
+		errc <- err
+		close(commits)
+		close(errc)
+	}()
+	return commits, errc
+}
 
-	cmd := exec.CommandContext(ctx, "git", args...)
-	cmd.Dir = r.Path
+// streamCommits runs git log and sends each parsed commit to out
+func (r *Repository) streamCommits(ctx context.Context, opts ListOptions, out chan<- Commit) error {
+	args := append([]string{"log", "--format=" + logFormat(opts.UseMailmap)}, revisionArgs(opts)...)
+	// The listing is consumed for the whole extraction, so it has no time limit
+	cmd := r.newGitCmd(ctx, args...)
 
-	output, err := cmd.Output()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("git log failed: %s", string(exitErr.Stderr))
-		}
-		return nil, fmt.Errorf("git log failed: %w", err)
+		return fmt.Errorf("failed to create pipe: %w", err)
 	}
 
-	var commits []Commit
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git log failed: %w", err)
+	}
+
+	var sendErr error
+	scanner := r.logScanner(stdout)
+scan:
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
@@ -59,23 +118,128 @@ func (r *Repository) ListCommits(ctx context.Context, opts ListOptions) ([]Commi
 
 		commit, err := parseCommitLine(line)
 		if err != nil {
+			r.logger().Warn("skipping unparsable git log line", "error", err)
 			continue
 		}
-		commits = append(commits, commit)
+		select {
+		case out <- commit:
+		case <-ctx.Done():
+			sendErr = ctx.Err()
+			break scan
+		}
 	}
+	scanErr := scanner.Err()
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to parse git log output: %w", err)
+	// Stop git log if the listing ended early so Wait does not block on it
+	if sendErr != nil || scanErr != nil {
+		_ = cmd.Process.Kill()
 	}
+	waitErr := cmd.Wait()
+	r.logCommand(cmd, start, waitErr)
 
-	return commits, nil
+	switch {
+	case sendErr != nil:
+		return sendErr
+	case scanErr != nil:
+		return r.logScanError(scanErr)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil:
+		return fmt.Errorf("git log failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// revisionArgs returns the git log/rev-list arguments selecting the commits of opts
+func revisionArgs(opts ListOptions) []string {
+	var args []string
+
+	if opts.Limit > 0 {
+		args = append(args, fmt.Sprintf("-n%d", opts.Limit))
+	}
+
+	if opts.Reverse {
+		args = append(args, "--reverse")
+	}
+
+	if opts.FirstParent {
+		args = append(args, "--first-parent")
+	}
+
+	switch opts.DateOrder {
+	case DateAuthor:
+		args = append(args, "--author-date-order")
+	case DateCommit:
+		args = append(args, "--date-order")
+	}
+
+	if len(opts.GrepPatterns) > 0 {
+		args = append(args, "--extended-regexp")
+		for _, pattern := range opts.GrepPatterns {
+			args = append(args, "--grep="+pattern)
+		}
+		if opts.GrepAllMatch {
+			args = append(args, "--all-match")
+		}
+		if opts.GrepIgnoreCase {
+			args = append(args, "--regexp-ignore-case")
+		}
+	}
+
+	if opts.PickaxeString != "" {
+		args = append(args, "-S"+opts.PickaxeString)
+	}
+	if opts.PickaxeRegex != "" {
+		args = append(args, "-G"+opts.PickaxeRegex)
+	}
+
+	if opts.Branch != "" {
+		args = append(args, opts.Branch)
+	}
+	return append(args, opts.Refs...)
+}
+
+// logFormat returns the git log format matching parseCommitLine's field layout.
+// The mailmap-aware placeholders (%aN, %aE, %cN, %cE) keep the same layout.
+func logFormat(useMailmap bool) string {
+	identity := "%an%x00%ae%x00%at%x00%cn%x00%ce%x00%ct"
+	if useMailmap {
+		identity = "%aN%x00%aE%x00%at%x00%cN%x00%cE%x00%ct"
+	}
+	return "%H%x00%h%x00" + identity + "%x00%G?%x00%P%x00%s"
+}
+
+// logScanner returns a scanner over the lines of git log output, accepting
+// lines up to an explicit BufferSize, or else config.MaxLogLineSize so that
+// commits with huge subjects are read whole
+func (r *Repository) logScanner(rd io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), r.logLineSize())
+	return scanner
+}
+
+// logLineSize returns the longest git log line a logScanner accepts
+func (r *Repository) logLineSize() int {
+	if r.BufferSize > 0 {
+		return r.BufferSize
+	}
+	return config.MaxLogLineSize
+}
+
+// logScanError describes an error of a logScanner
+func (r *Repository) logScanError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("failed to parse git log output: a commit subject is longer than %d bytes", r.logLineSize())
+	}
+	return fmt.Errorf("failed to parse git log output: %w", err)
 }
 
-// parseCommitLine parses a single line of git log output
+// parseCommitLine parses a single line of git log output. The subject comes
+// last, so NULs in it cannot shift the other fields; they are dropped.
 func parseCommitLine(line string) (Commit, error) {
 	parts := strings.SplitN(line, "\x00", 11)
 	if len(parts) < 11 {
-		return Commit{}, fmt.Errorf("invalid commit line format: %s", line)
+		return Commit{}, fmt.Errorf("invalid commit line format: %.100q", line)
 	}
 
 	authorTimestamp, err := strconv.ParseInt(parts[4], 10, 64)
@@ -103,18 +267,209 @@ func parseCommitLine(line string) (Commit, error) {
 		CommitDate:     time.Unix(commitTimestamp, 0),
 		GPGSignature:   parts[8],
 		ParentHashes:   parents,
-		Subject:        parts[10],
+		Subject:        strings.ReplaceAll(parts[10], "\x00", ""),
 	}, nil
 }
 
+// ResolveCommit resolves a ref (hash, branch, tag, HEAD~2, ...) to a fully populated commit
+func (r *Repository) ResolveCommit(ctx context.Context, ref string) (Commit, error) {
+	hash, err := r.ResolveRef(ctx, ref)
+	if err != nil {
+		return Commit{}, err
+	}
+
+	commits, err := r.ListCommits(ctx, ListOptions{Branch: hash, Limit: 1})
+	if err != nil {
+		return Commit{}, err
+	}
+	if len(commits) != 1 {
+		return Commit{}, fmt.Errorf("unknown commit %q", ref)
+	}
+	return commits[0], nil
+}
+
+// LoadCommitsFile reads a file listing one commit hash (or any other ref)
+// per line. Blank lines and lines starting with # are ignored.
+func LoadCommitsFile(path string) ([]string, error) {
+	return readListFile(path, "commits file")
+}
+
+// PipedLogFormat is the git log --format whose lines ReadPipedCommits takes
+// as they are, without resolving them: the format repopsy reads git log with
+var PipedLogFormat = logFormat(false)
+
+// ReadPipedCommits reads the commits named by git log output piped to
+// repopsy, in order. Lines in PipedLogFormat are parsed; any other line
+// names a commit by its first word, as printed by --format=%H or --oneline.
+// All names are resolved together, with two git calls whatever their
+// number. Blank lines and lines starting with # are ignored, and the names
+// resolving to no commit are returned apart.
+func (r *Repository) ReadPipedCommits(ctx context.Context, rd io.Reader) ([]Commit, []string, error) {
+	// Each entry is either a parsed commit or a name to resolve
+	type entry struct {
+		commit Commit
+		name   string
+	}
+	var entries []entry
+	var names []string
+	scanner := r.logScanner(rd)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if commit, err := parseCommitLine(line); err == nil {
+			entries = append(entries, entry{commit: commit})
+			continue
+		}
+
+		// Other NUL-separated formats are accepted when they start with the hash
+		ref, _, _ := strings.Cut(line, "\x00")
+		fields := strings.Fields(ref)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entries = append(entries, entry{name: fields[0]})
+		names = append(names, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, r.logScanError(err)
+	}
+
+	var hashes map[string]string
+	var found map[string]Commit
+	if len(names) > 0 {
+		var err error
+		if hashes, err = r.resolveCommitNames(ctx, names); err != nil {
+			return nil, nil, err
+		}
+		seen := make(map[string]bool, len(hashes))
+		unique := make([]string, 0, len(hashes))
+		for _, hash := range hashes {
+			if !seen[hash] {
+				seen[hash] = true
+				unique = append(unique, hash)
+			}
+		}
+		if len(unique) > 0 {
+			if found, err = r.lookupCommits(ctx, unique); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var commits []Commit
+	var unresolved []string
+	for _, e := range entries {
+		if e.name == "" {
+			commits = append(commits, e.commit)
+			continue
+		}
+		if commit, ok := found[hashes[e.name]]; ok {
+			commits = append(commits, commit)
+		} else {
+			unresolved = append(unresolved, e.name)
+		}
+	}
+	return commits, unresolved, nil
+}
+
+// resolveCommitNames resolves names (hashes, abbreviated hashes or refs) to
+// full commit hashes with a single git call. Names resolving to no commit,
+// or to several, are left out of the result.
+func (r *Repository) resolveCommitNames(ctx context.Context, names []string) (map[string]string, error) {
+	cmd, cancel := r.gitCommand(ctx, "cat-file", "--batch-check=%(objectname)")
+	defer cancel()
+	var input strings.Builder
+	for _, name := range names {
+		input.WriteString(name + "^{commit}\n")
+	}
+	cmd.Stdin = strings.NewReader(input.String())
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve commits: %w", err)
+	}
+
+	// One line per name, in order: the hash, or "<name> missing" and the like
+	hashes := make(map[string]string, len(names))
+	for i, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+		if i < len(names) && line != "" && !strings.Contains(line, " ") {
+			hashes[names[i]] = line
+		}
+	}
+	return hashes, nil
+}
+
+// LoadIgnoreRevsFile reads a file of commits to ignore, in the
+// .git-blame-ignore-revs format: one full or abbreviated hash per line,
+// blank lines and lines starting with # ignored. Hashes are lowercased.
+func LoadIgnoreRevsFile(path string) ([]string, error) {
+	revs, err := readListFile(path, "ignore-revs file")
+	if err != nil {
+		return nil, err
+	}
+	for i, rev := range revs {
+		// Trailing comments are allowed after the hash
+		rev, _, _ = strings.Cut(rev, " ")
+		rev = strings.ToLower(rev)
+		if len(rev) < 4 || strings.Trim(rev, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("invalid commit %q in ignore-revs file", revs[i])
+		}
+		revs[i] = rev
+	}
+	return revs, nil
+}
+
+// MergeBase returns the full hash of the best common ancestor of a and b
+func (r *Repository) MergeBase(ctx context.Context, a, b string) (string, error) {
+	hash, err := r.runGitCommand(ctx, "merge-base", "--end-of-options", a, b)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the merge base of %s and %s: %w", a, b, err)
+	}
+	return hash, nil
+}
+
+// ResolveRef resolves a ref to the full hash of the commit it names
+func (r *Repository) ResolveRef(ctx context.Context, ref string) (string, error) {
+	hash, err := r.runGitCommand(ctx, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %q: %w", ref, err)
+	}
+	return hash, nil
+}
+
+// GetTreeHash returns the hash of a commit's root tree
+func (r *Repository) GetTreeHash(ctx context.Context, hash string) (string, error) {
+	tree, err := r.runGitCommand(ctx, "rev-parse", "--verify", "--end-of-options", hash+"^{tree}")
+	if err != nil {
+		return "", fmt.Errorf("failed to get tree of %s: %w", hash, err)
+	}
+	return tree, nil
+}
+
 // CommitCount returns the total number of commits in the repository
 func (r *Repository) CommitCount(ctx context.Context, branch string) (int, error) {
-	ref := branch
-	if ref == "" {
-		ref = "HEAD"
+	return r.CountCommits(ctx, ListOptions{Branch: branch})
+}
+
+// CountCommits returns the number of commits ListCommits would return for opts
+func (r *Repository) CountCommits(ctx context.Context, opts ListOptions) (int, error) {
+	if opts.Branch == "" && len(opts.Refs) == 0 {
+		opts.Branch = "HEAD"
+	}
+	// Order does not change the count
+	opts.Reverse = false
+
+	// rev-list has no pickaxe, so those commits are counted from git log
+	if opts.PickaxeString != "" || opts.PickaxeRegex != "" {
+		args := append([]string{"log", "--format=%H"}, revisionArgs(opts)...)
+		output, err := r.runGitCommand(ctx, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count commits: %w", err)
+		}
+		return len(strings.Fields(output)), nil
 	}
 
-	output, err := r.runGitCommand(ctx, "rev-list", "--count", ref)
+	args := append([]string{"rev-list", "--count"}, revisionArgs(opts)...)
+	output, err := r.runGitCommand(ctx, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count commits: %w", err)
 	}
@@ -0,0 +1,40 @@
+package git
+
+import "errors"
+
+// Errors of the git layer, to be matched with errors.Is
+var (
+	// ErrNotARepository is returned by Open for a directory outside any
+	// git repository
+	ErrNotARepository = errors.New("not a git repository")
+
+	// ErrGitNotFound is returned when the git executable is not in PATH
+	ErrGitNotFound = errors.New("git was not found in PATH")
+)
+
+// ExtractionError is returned, to be matched with errors.As, when git
+// archive or tar failed to write the tree of a commit
+type ExtractionError struct {
+	Hash   string // Commit being extracted, as given by the caller
+	Stderr string // Standard error of the failed command, empty when it wrote none
+	Err    error
+}
+
+func (e *ExtractionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExtractionError) Unwrap() error {
+	return e.Err
+}
+
+// extractionError attributes err, the failure of an extraction command, to
+// hash, keeping the standard error recorded when it is an ExtractionError
+func extractionError(hash string, err error) error {
+	var extErr *ExtractionError
+	if errors.As(err, &extErr) {
+		extErr.Hash = hash
+		return err
+	}
+	return &ExtractionError{Hash: hash, Err: err}
+}
@@ -2,23 +2,29 @@
 package git
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
 )
 
 // ExtractCommit extracts the contents of a commit to the specified destination path
 func (r *Repository) ExtractCommit(ctx context.Context, hash, destPath string) error {
-	if err := os.MkdirAll(destPath, 0755); err != nil {
+	if err := os.MkdirAll(destPath, config.OutputDirPerms); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 	// Use 'git archive' piped to 'tar' to extract the commit
 	// This avoids checking out the commit into the working directory
-	return r.runArchiveToTar(ctx, []string{"archive", "--format=tar", hash}, destPath)
+	return r.runArchiveToTar(ctx, hash, []string{"archive", "--format=tar", hash}, destPath)
 }
 
 // ExtractCommitExcludingBinaries extracts commit contents, excluding binary files
@@ -54,10 +60,10 @@ func (r *Repository) ExtractCommitExcludingBinaries(ctx context.Context, hash, d
 
 	// If all files are binary, nothing to extract
 	if len(textFiles) == 0 {
-		return os.MkdirAll(destPath, 0755)
+		return os.MkdirAll(destPath, config.OutputDirPerms)
 	}
 
-	if err := os.MkdirAll(destPath, 0755); err != nil {
+	if err := os.MkdirAll(destPath, config.OutputDirPerms); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
@@ -65,19 +71,239 @@ func (r *Repository) ExtractCommitExcludingBinaries(ctx context.Context, hash, d
 	archiveArgs := []string{"archive", "--format=tar", hash, "--"}
 	archiveArgs = append(archiveArgs, textFiles...)
 
-	return r.runArchiveToTar(ctx, archiveArgs, destPath)
+	return r.runArchiveToTar(ctx, hash, archiveArgs, destPath)
+}
+
+// ExtractCommitFiltered extracts only the files of a commit kept by the filter
+func (r *Repository) ExtractCommitFiltered(ctx context.Context, hash, destPath string, filter PathFilter) error {
+	if filter.IsEmpty() {
+		return r.ExtractCommit(ctx, hash, destPath)
+	}
+
+	keep, err := r.filterPathspecs(ctx, hash, filter)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destPath, config.OutputDirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Nothing matched, leave an empty folder
+	if len(keep) == 0 {
+		return nil
+	}
+
+	archiveArgs := []string{"archive", "--format=tar", hash, "--"}
+	archiveArgs = append(archiveArgs, keep...)
+
+	return r.runArchiveToTar(ctx, hash, archiveArgs, destPath)
+}
+
+// filterPathspecs returns literal pathspecs for the files of a commit kept by the filter
+func (r *Repository) filterPathspecs(ctx context.Context, hash string, filter PathFilter) ([]string, error) {
+	var files []FileSize
+	if filter.MaxSize > 0 {
+		// Sizes are only needed, and only listed, when filtering on them
+		sizes, err := r.GetCommitTreeSizes(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		files = sizes
+	} else {
+		paths, err := r.listFiles(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			files = append(files, FileSize{Path: p})
+		}
+	}
+
+	selects, err := r.selector(ctx, hash, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var keep []string
+	for _, file := range files {
+		if selects(file.Path) && !filter.TooLarge(file.Size) {
+			// Literal pathspecs keep git from expanding wildcards in file names
+			keep = append(keep, ":(literal)"+file.Path)
+		}
+	}
+	return keep, nil
+}
+
+// TooLargeFiles returns the files of a commit that the filter selects but
+// drops for exceeding MaxSize, largest first
+func (r *Repository) TooLargeFiles(ctx context.Context, hash string, filter PathFilter) ([]FileSize, error) {
+	if filter.MaxSize <= 0 {
+		return nil, nil
+	}
+	sizes, err := r.GetCommitTreeSizes(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	selects, err := r.selector(ctx, hash, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []FileSize
+	for _, file := range sizes {
+		if selects(file.Path) && filter.TooLarge(file.Size) {
+			dropped = append(dropped, file)
+		}
+	}
+	return dropped, nil
+}
+
+// selector returns a function reporting whether the filter selects a file
+// of a commit by its globs and, with Changed, by the files the commit
+// added or modified. Sizes are left to the caller.
+func (r *Repository) selector(ctx context.Context, hash string, filter PathFilter) (func(file string) bool, error) {
+	if !filter.Changed {
+		return filter.Match, nil
+	}
+	paths, err := r.changedFiles(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		changed[p] = true
+	}
+	return func(file string) bool { return changed[file] && filter.Match(file) }, nil
 }
 
-// runArchiveToTar executes git archive piped to tar for extraction
-func (r *Repository) runArchiveToTar(ctx context.Context, archiveArgs []string, destPath string) error {
-	archiveCmd := exec.CommandContext(ctx, "git", archiveArgs...)
-	archiveCmd.Dir = r.Path
+// ArchiveCommit writes a tar archive of the files of a commit kept by the
+// filter to destFile
+func (r *Repository) ArchiveCommit(ctx context.Context, hash, destFile string, filter PathFilter) error {
+	args := []string{"archive", "--format=tar", "--output=" + destFile, hash}
+	if !filter.IsEmpty() {
+		keep, err := r.filterPathspecs(ctx, hash, filter)
+		if err != nil {
+			return err
+		}
+		// Nothing matched, write an empty archive
+		if len(keep) == 0 {
+			return writeEmptyTar(destFile)
+		}
+		args = append(append(args, "--"), keep...)
+	}
+	if r.PreservePerms {
+		args = append([]string{"-c", "tar.umask=0022"}, args...)
+	}
+
+	cmd, cancel, err := r.archiveCommand(ctx, args...)
+	if err != nil {
+		return err
+	}
+	defer cancel()
 
-	// tar -x: extract
-	// -f -: from stdin
-	// -C destPath: change directory to destination before extracting
-	tarCmd := exec.CommandContext(ctx, "tar", "-xf", "-", "-C", destPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
+	if err := r.run(cmd); err != nil {
+		return &ExtractionError{Hash: hash, Stderr: strings.TrimSpace(stderr.String()), Err: fmt.Errorf("git archive failed: %s", commandError(&stderr, err))}
+	}
+	return nil
+}
+
+// writeEmptyTar writes a valid tar archive with no entries to path
+func writeEmptyTar(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	if err := tar.NewWriter(f).Close(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return f.Close()
+}
+
+// archiveCommand returns a git archive command for args like gitCommand,
+// routed through the export-ignore override when export-ignore is not
+// respected
+func (r *Repository) archiveCommand(ctx context.Context, args ...string) (*exec.Cmd, context.CancelFunc, error) {
+	if !r.RespectExportIgnore {
+		gitDir, err := r.exportIgnoreOverride()
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append([]string{"--git-dir=" + gitDir}, args...)
+	}
+	cmd, cancel := r.gitCommand(ctx, args...)
+	return cmd, cancel, nil
+}
+
+// ArchiveToWriter writes the tar archive of a commit's tree to w
+func (r *Repository) ArchiveToWriter(ctx context.Context, hash string, w io.Writer) error {
+	cmd, cancel, err := r.archiveCommand(ctx, "archive", "--format=tar", hash)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	cmd.Stdout = w
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := r.run(cmd); err != nil {
+		return &ExtractionError{Hash: hash, Stderr: strings.TrimSpace(stderr.String()), Err: fmt.Errorf("git archive failed: %s", commandError(&stderr, err))}
+	}
+	return nil
+}
+
+// runArchiveToTar executes git archive piped to tar, or to the built-in tar
+// reader without r.ExternalTar, for extraction of the commit hash, reporting
+// failures as an ExtractionError
+func (r *Repository) runArchiveToTar(ctx context.Context, hash string, archiveArgs []string, destPath string) error {
+	gitArgs := archiveArgs
+	tarArgs := []string{"-xf", "-", "-C", destPath}
+	if r.PreservePerms {
+		// git archive masks modes with tar.umask (default 0002); use 0022 so the
+		// archive carries git's own 0644/0755 modes, and have tar apply them as-is
+		gitArgs = append([]string{"-c", "tar.umask=0022"}, archiveArgs...)
+		tarArgs = append(tarArgs, "-p")
+	}
+
+	archiveCmd, cancel, err := r.archiveCommand(ctx, gitArgs...)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	if r.ExternalTar {
+		// tar -x: extract
+		// -f -: from stdin
+		// -C destPath: change directory to destination before extracting
+		// -p: apply the archived permissions without the process umask
+		tarCmd := exec.CommandContext(ctx, "tar", tarArgs...)
+		err = pipeArchiveToTar(archiveCmd, tarCmd)
+		r.logCommand(archiveCmd, start, err)
+		r.logCommand(tarCmd, start, err)
+	} else {
+		err = pipeArchiveToDir(archiveCmd, destPath, r.PreservePerms)
+		r.logCommand(archiveCmd, start, err)
+	}
+	if err != nil {
+		return extractionError(hash, r.timeoutError(start, err))
+	}
+
+	if !r.PreserveSymlinks {
+		return flattenSymlinks(destPath)
+	}
+	return nil
+}
+
+// pipeArchiveToTar runs archiveCmd with its stdout feeding tarCmd and waits
+// for both. Both commands should be created with exec.CommandContext so that
+// cancellation kills them; neither process is left running on return.
+func pipeArchiveToTar(archiveCmd, tarCmd *exec.Cmd) error {
 	pipe, err := archiveCmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("failed to create pipe: %w", err)
@@ -93,33 +319,93 @@ func (r *Repository) runArchiveToTar(ctx context.Context, archiveArgs []string,
 	}
 	if err := tarCmd.Start(); err != nil {
 		_ = archiveCmd.Process.Kill()
+		_ = archiveCmd.Wait()
 		return fmt.Errorf("failed to start tar: %w", err)
 	}
 
+	// tar holds its own copy of the read end; closing ours lets git archive
+	// fail with EPIPE instead of blocking forever if tar exits early
+	_ = pipe.Close()
+
 	archiveErr := archiveCmd.Wait()
 	tarErr := tarCmd.Wait()
 
 	if archiveErr != nil {
-		return fmt.Errorf("git archive failed: %s", archiveStderr.String())
+		return &ExtractionError{Stderr: strings.TrimSpace(archiveStderr.String()), Err: fmt.Errorf("git archive failed: %s", archiveStderr.String())}
 	}
 	if tarErr != nil {
-		return fmt.Errorf("tar extraction failed: %s", tarStderr.String())
+		return &ExtractionError{Stderr: strings.TrimSpace(tarStderr.String()), Err: fmt.Errorf("tar extraction failed: %s", tarStderr.String())}
 	}
 	return nil
 }
 
+// flattenSymlinks replaces every symlink under root with a regular file
+// containing the link target
+func flattenSymlinks(root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.Type()&os.ModeSymlink == 0 {
+			return err
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink: %w", err)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove symlink: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(target), 0o644); err != nil {
+			return fmt.Errorf("failed to write symlink placeholder: %w", err)
+		}
+		return nil
+	})
+}
+
 // listFiles returns all files in a commit
 func (r *Repository) listFiles(ctx context.Context, hash string) ([]string, error) {
-	cmd := exec.CommandContext(ctx, "git", "ls-tree", "-r", "--name-only", hash)
-	cmd.Dir = r.Path
-
-	output, err := cmd.Output()
+	files, err := r.listTreePaths(ctx, hash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
+	return files, nil
+}
+
+// changedFiles returns the paths a commit added or modified compared to its
+// first parent, or all of its files for a root commit
+func (r *Repository) changedFiles(ctx context.Context, hash string) ([]string, error) {
+	cmd, cancel := r.gitCommand(ctx, "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", "-z",
+		"--first-parent", "--diff-filter=d", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed by %s: %w", hash, err)
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// listTreePaths returns the paths printed by a recursive git ls-tree of a
+// commit, with the given extra options
+func (r *Repository) listTreePaths(ctx context.Context, hash string, args ...string) ([]string, error) {
+	args = append([]string{"ls-tree", "-r", "-z", "--name-only"}, args...)
+	cmd, cancel := r.gitCommand(ctx, append(args, hash)...)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	var files []string
 	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	scanner.Split(splitNull)
 	for scanner.Scan() {
 		if line := scanner.Text(); line != "" {
 			files = append(files, line)
@@ -128,23 +414,22 @@ func (r *Repository) listFiles(ctx context.Context, hash string) ([]string, erro
 	return files, nil
 }
 
-// listBinaryFiles returns a set of file paths that are binary in the given commit
+// listBinaryFiles returns a set of file paths that are binary in the given
+// commit. The NUL-separated output keeps paths unquoted, so they match the
+// paths of ls-tree -z.
 func (r *Repository) listBinaryFiles(ctx context.Context, hash string) (map[string]bool, error) {
-	cmd := exec.CommandContext(ctx, "git", "diff-tree", "--numstat", "-r", "--root", hash)
-	cmd.Dir = r.Path
+	cmd, cancel := r.gitCommand(ctx, "diff-tree", "--numstat", "-z", "--no-commit-id", "-r", "--root", hash)
+	defer cancel()
 
-	output, err := cmd.Output()
+	output, err := r.output(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list binary files: %w", err)
 	}
 
 	binaryFiles := make(map[string]bool)
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
+	for _, record := range strings.Split(string(output), "\x00") {
 		// Binary files show as: -\t-\tfilename
-		if strings.HasPrefix(line, "-\t-\t") {
-			filename := strings.TrimPrefix(line, "-\t-\t")
+		if filename, ok := strings.CutPrefix(record, "-\t-\t"); ok {
 			binaryFiles[filename] = true
 		}
 	}
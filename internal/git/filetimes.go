@@ -0,0 +1,107 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileTimes returns, for each of paths, the author date of the last commit
+// reachable from hash that added or modified it. The history is walked once,
+// newest first, and the walk stops as soon as every path is dated. Paths
+// that are not files of the commit's tree, such as the contents of
+// submodules or reports, are left out without being looked for, since no
+// commit of the history touches them.
+func (r *Repository) FileTimes(ctx context.Context, hash string, paths []string) (map[string]time.Time, error) {
+	blobs, err := r.treeBlobs(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Time, len(paths))
+	pending := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		if blobs[p] {
+			pending[p] = true
+		}
+	}
+	if len(pending) == 0 {
+		return times, nil
+	}
+
+	// Cancelling the walk once every path is dated kills the rest of it
+	walkCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	cmd, cancel := r.gitCommand(walkCtx, "log", "--format=%x01%at", "--name-only", "--no-renames", "--diff-filter=d", "-z", hash)
+	defer cancel()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start git log: %w", err)
+	}
+
+	var current time.Time
+	var parseErr error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	scanner.Split(splitNull)
+	for len(pending) > 0 && scanner.Scan() {
+		// The first file of each commit follows the newline ending its format
+		token := strings.TrimPrefix(scanner.Text(), "\n")
+		if ts, ok := strings.CutPrefix(token, "\x01"); ok {
+			sec, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				parseErr = fmt.Errorf("invalid author timestamp %q: %w", ts, err)
+				break
+			}
+			current = time.Unix(sec, 0)
+			continue
+		}
+		if pending[token] {
+			delete(pending, token)
+			times[token] = current
+		}
+	}
+	scanErr := scanner.Err()
+	stop()
+	waitErr := cmd.Wait()
+	r.logCommand(cmd, start, waitErr)
+
+	switch {
+	case parseErr != nil:
+		return nil, fmt.Errorf("failed to read file times: %w", parseErr)
+	case scanErr != nil:
+		return nil, fmt.Errorf("failed to read file times: %w", scanErr)
+	case len(pending) > 0 && waitErr != nil:
+		// A walk stopped early was killed on purpose
+		return nil, fmt.Errorf("failed to read file times: %w", r.timeoutError(start, waitErr))
+	}
+	return times, nil
+}
+
+// treeBlobs returns the set of paths of the files in a commit's tree,
+// leaving out the submodule entries
+func (r *Repository) treeBlobs(ctx context.Context, hash string) (map[string]bool, error) {
+	cmd, cancel := r.gitCommand(ctx, "ls-tree", "-r", "-z", "--end-of-options", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", hash, err)
+	}
+
+	blobs := make(map[string]bool)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		// <mode> SP <type> SP <object> TAB <path>
+		info, path, ok := strings.Cut(entry, "\t")
+		if fields := strings.Fields(info); ok && len(fields) == 3 && fields[1] == "blob" {
+			blobs[path] = true
+		}
+	}
+	return blobs, nil
+}
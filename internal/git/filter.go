@@ -0,0 +1,137 @@
+// Package git provides functionality for interacting with git repositories.
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// PathFilter selects which files of a commit are extracted.
+//
+// Patterns use path.Match syntax plus "**" for any number of directories.
+// A pattern without a slash matches the file name at any depth (e.g. "*.go"),
+// and a pattern ending in a slash matches a directory at any depth (e.g. "vendor/").
+type PathFilter struct {
+	Include []string // If set, only files matching at least one pattern are kept
+	Exclude []string // Files matching any pattern are dropped
+	MaxSize int64    // If positive, files larger than MaxSize bytes are dropped
+	Changed bool     // If set, only files the commit added or modified are kept
+}
+
+// IsEmpty reports whether the filter keeps every file
+func (f PathFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && f.MaxSize <= 0 && !f.Changed
+}
+
+// TooLarge reports whether a file of the given size is dropped by MaxSize
+func (f PathFilter) TooLarge(size int64) bool {
+	return f.MaxSize > 0 && size > f.MaxSize
+}
+
+// Match reports whether the file at the given slash-separated path is kept
+func (f PathFilter) Match(file string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, file) {
+		return false
+	}
+	return !matchAny(f.Exclude, file)
+}
+
+// LoadFilterFile reads a filter file with one PathFilter pattern per line.
+// Blank lines and lines starting with # are ignored.
+func LoadFilterFile(path string) ([]string, error) {
+	return readListFile(path, "filter file")
+}
+
+// readListFile returns the trimmed lines of a file, skipping blank lines
+// and lines starting with #. kind names the file in errors.
+func readListFile(path, kind string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", kind, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+	return lines, nil
+}
+
+// matchAny reports whether file matches any of the patterns
+func matchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a single pattern against a file path
+func matchGlob(pattern, file string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+
+	segments := strings.Split(file, "/")
+
+	// Unanchored pattern: match a single path component
+	if !strings.Contains(pattern, "/") {
+		candidates := segments
+		if dirOnly {
+			candidates = segments[:len(segments)-1]
+		} else {
+			candidates = segments[len(segments)-1:]
+		}
+		for _, seg := range candidates {
+			if ok, _ := path.Match(pattern, seg); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Anchored pattern: a directory pattern matches everything below it
+	patternSegs := strings.Split(pattern, "/")
+	if dirOnly {
+		patternSegs = append(patternSegs, "**")
+	}
+	return matchSegments(patternSegs, segments)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
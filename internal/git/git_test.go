@@ -1,11 +1,21 @@
 package git
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 // setupTestRepo creates a temporary git repository with some commits
@@ -51,6 +61,18 @@ func setupTestRepo(t *testing.T) *Repository {
 	return repo
 }
 
+// runGit runs a git command in dir and fails the test on error
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+	}
+	return string(out)
+}
+
 func TestListCommits(t *testing.T) {
 	repo := setupTestRepo(t)
 
@@ -93,3 +115,1690 @@ func TestCommitCount(t *testing.T) {
 		t.Errorf("expected count 2, got %d", count)
 	}
 }
+
+func TestGetSignatureInfoUnsigned(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	commits, err := repo.ListCommits(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+
+	info, err := repo.GetSignatureInfo(context.Background(), commits[0].Hash)
+	if err != nil {
+		t.Fatalf("GetSignatureInfo failed: %v", err)
+	}
+
+	if info.KeyID != "" || info.Signer != "" || info.Raw != "" {
+		t.Errorf("expected empty signature info for unsigned commit, got %+v", info)
+	}
+	if commits[0].IsSigned() {
+		t.Errorf("expected unsigned commit, got status %q", commits[0].GPGSignature)
+	}
+}
+
+func TestListCommitsGrep(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	runGit(t, repo.Path, "commit", "--allow-empty", "-m", "bugfix")
+	runGit(t, repo.Path, "commit", "--allow-empty", "-m", "feature")
+
+	commits, err := repo.ListCommits(context.Background(), ListOptions{GrepPatterns: []string{"fix|CVE-"}})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "bugfix" {
+		t.Errorf("expected only the bugfix commit, got %v", commits)
+	}
+
+	commits, err = repo.ListCommits(context.Background(), ListOptions{GrepPatterns: []string{"FEATURE"}, GrepIgnoreCase: true})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "feature" {
+		t.Errorf("expected only the feature commit, got %v", commits)
+	}
+
+	// Several patterns match any of them, or all of them with GrepAllMatch
+	runGit(t, repo.Path, "commit", "--allow-empty", "-m", "bugfix in feature")
+	for allMatch, want := range map[bool]int{false: 3, true: 1} {
+		commits, err = repo.ListCommits(context.Background(), ListOptions{GrepPatterns: []string{"fix", "feature"}, GrepAllMatch: allMatch})
+		if err != nil {
+			t.Fatalf("ListCommits failed: %v", err)
+		}
+		if len(commits) != want {
+			t.Errorf("allMatch=%v: expected %d commits, got %v", allMatch, want, commits)
+		}
+	}
+}
+
+func TestGetCommitTreeSizes(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	large := make([]byte, 4096)
+	if err := os.WriteFile(filepath.Join(repo.Path, "large.bin"), large, 0644); err != nil {
+		t.Fatalf("failed to write large file: %v", err)
+	}
+	runGit(t, repo.Path, "add", "large.bin")
+	runGit(t, repo.Path, "commit", "-m", "Add large file")
+
+	sizes, err := repo.GetCommitTreeSizes(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("GetCommitTreeSizes failed: %v", err)
+	}
+
+	if len(sizes) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(sizes))
+	}
+	if sizes[0].Path != "large.bin" || sizes[0].Size != int64(len(large)) {
+		t.Errorf("expected largest file large.bin (%d bytes), got %s (%d bytes)", len(large), sizes[0].Path, sizes[0].Size)
+	}
+
+	dest := t.TempDir()
+	if err := WriteSizesFile(dest, sizes); err != nil {
+		t.Fatalf("WriteSizesFile failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "sizes.txt"))
+	if err != nil {
+		t.Fatalf("failed to read sizes.txt: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "large.bin\t4096\n") {
+		t.Errorf("expected path then size on each line, got:\n%s", data)
+	}
+}
+
+func TestGetBinaryChangeBytes(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// Names git would quote in its line-based output must still be counted
+	binary := []byte{0, 1, 2, 3, 0, 5, 6, 7}
+	for _, name := range []string{"plain.bin", "caf\u00e9.bin", "tab\tname.bin"} {
+		if err := os.WriteFile(filepath.Join(repo.Path, name), binary, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	runGit(t, repo.Path, "add", ".")
+	runGit(t, repo.Path, "commit", "-m", "Add binaries")
+
+	total, err := repo.GetBinaryChangeBytes(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("GetBinaryChangeBytes failed: %v", err)
+	}
+	if want := int64(3 * len(binary)); total != want {
+		t.Errorf("expected %d binary bytes, got %d", want, total)
+	}
+}
+
+func TestBareRepository(t *testing.T) {
+	src := setupTestRepo(t)
+	bareDir := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, src.Path, "clone", "--bare", src.Path, bareDir)
+
+	repo, err := Open(bareDir)
+	if err != nil {
+		t.Fatalf("failed to open bare repo: %v", err)
+	}
+	if !repo.IsBare(context.Background()) {
+		t.Error("expected bare repository to be detected")
+	}
+	if src.IsBare(context.Background()) {
+		t.Error("expected non-bare repository not to be reported as bare")
+	}
+
+	commits, err := repo.ListCommits(context.Background(), ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("expected 1 commit, got %d", len(commits))
+	}
+
+	destPath := filepath.Join(t.TempDir(), "out")
+	if err := repo.ExtractCommit(context.Background(), commits[0].Hash, destPath); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+	for _, name := range []string{"file1.txt", "file2.txt"} {
+		if _, err := os.Stat(filepath.Join(destPath, name)); err != nil {
+			t.Errorf("expected %s to be extracted: %v", name, err)
+		}
+	}
+}
+
+func TestListCommitsFirstParent(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	runGit(t, repo.Path, "checkout", "-q", "-b", "topic")
+	runGit(t, repo.Path, "commit", "--allow-empty", "-m", "Topic commit 1")
+	runGit(t, repo.Path, "commit", "--allow-empty", "-m", "Topic commit 2")
+	runGit(t, repo.Path, "checkout", "-q", "-")
+	runGit(t, repo.Path, "merge", "--no-ff", "-m", "Merge topic", "topic")
+
+	all, err := repo.ListCommits(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	mainline, err := repo.ListCommits(context.Background(), ListOptions{FirstParent: true, Reverse: true})
+	if err != nil {
+		t.Fatalf("ListCommits with FirstParent failed: %v", err)
+	}
+
+	if len(all) != 5 {
+		t.Errorf("expected 5 commits in full traversal, got %d", len(all))
+	}
+	if len(mainline) != 3 {
+		t.Errorf("expected 3 first-parent commits, got %d", len(mainline))
+	}
+	if last := mainline[len(mainline)-1]; last.Subject != "Merge topic" {
+		t.Errorf("expected merge commit last in reverse order, got %q", last.Subject)
+	}
+
+	limited, err := repo.ListCommits(context.Background(), ListOptions{FirstParent: true, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListCommits with FirstParent and Limit failed: %v", err)
+	}
+	if len(limited) != 2 || limited[1].Subject != "Commit with | pipe" {
+		t.Errorf("expected merge and its first parent, got %v", limited)
+	}
+}
+
+// setupFilterRepo creates a repository with Go sources, docs and vendored code
+func setupFilterRepo(t *testing.T) *Repository {
+	repo := setupTestRepo(t)
+	files := map[string]string{
+		"main.go":               "package main",
+		"internal/app/app.go":   "package app",
+		"docs/guide.md":         "# Guide",
+		"vendor/lib/lib.go":     "package lib",
+		"vendor/lib/README.txt": "vendored",
+	}
+	for name, content := range files {
+		full := filepath.Join(repo.Path, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	runGit(t, repo.Path, "add", ".")
+	runGit(t, repo.Path, "commit", "-m", "Add sources")
+	return repo
+}
+
+// extractedFiles returns the slash-separated paths of all files under dir
+func extractedFiles(t *testing.T, dir string) map[string]bool {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk %s: %v", dir, err)
+	}
+	return files
+}
+
+func TestExtractCommitFiltered(t *testing.T) {
+	repo := setupFilterRepo(t)
+
+	tests := []struct {
+		name   string
+		filter PathFilter
+		want   []string
+	}{
+		{
+			name:   "keep only go",
+			filter: PathFilter{Include: []string{"*.go"}},
+			want:   []string{"main.go", "internal/app/app.go", "vendor/lib/lib.go"},
+		},
+		{
+			name:   "exclude dir",
+			filter: PathFilter{Exclude: []string{"vendor/"}},
+			want:   []string{"file1.txt", "file2.txt", "main.go", "internal/app/app.go", "docs/guide.md"},
+		},
+		{
+			name:   "keep go outside vendor",
+			filter: PathFilter{Include: []string{"**/*.go"}, Exclude: []string{"vendor/"}},
+			want:   []string{"main.go", "internal/app/app.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destPath := filepath.Join(t.TempDir(), "out")
+			if err := repo.ExtractCommitFiltered(context.Background(), "HEAD", destPath, tt.filter); err != nil {
+				t.Fatalf("ExtractCommitFiltered failed: %v", err)
+			}
+
+			got := extractedFiles(t, destPath)
+			if len(got) != len(tt.want) {
+				t.Errorf("expected %d files, got %v", len(tt.want), got)
+			}
+			for _, name := range tt.want {
+				if !got[name] {
+					t.Errorf("expected %s to be extracted, got %v", name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractCommitChanged(t *testing.T) {
+	repo := setupFilterRepo(t)
+	if err := os.WriteFile(filepath.Join(repo.Path, "docs", "guide.md"), []byte("# Guide, revised"), 0644); err != nil {
+		t.Fatalf("failed to modify guide: %v", err)
+	}
+	runGit(t, repo.Path, "rm", "-q", "main.go")
+	runGit(t, repo.Path, "commit", "-q", "-am", "Revise guide, drop main")
+
+	tests := []struct {
+		name   string
+		rev    string
+		filter PathFilter
+		want   []string
+	}{
+		{
+			name:   "modified only",
+			rev:    "HEAD",
+			filter: PathFilter{Changed: true},
+			want:   []string{"docs/guide.md"},
+		},
+		{
+			name:   "added and filtered",
+			rev:    "HEAD~1",
+			filter: PathFilter{Changed: true, Exclude: []string{"vendor/"}},
+			want:   []string{"main.go", "internal/app/app.go", "docs/guide.md"},
+		},
+		{
+			name:   "root commit",
+			rev:    "HEAD~3",
+			filter: PathFilter{Changed: true},
+			want:   []string{"file1.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destPath := filepath.Join(t.TempDir(), "out")
+			if err := repo.ExtractCommitFiltered(context.Background(), tt.rev, destPath, tt.filter); err != nil {
+				t.Fatalf("ExtractCommitFiltered failed: %v", err)
+			}
+
+			got := extractedFiles(t, destPath)
+			if len(got) != len(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			for _, name := range tt.want {
+				if !got[name] {
+					t.Errorf("expected %s to be extracted, got %v", name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPreservesModesAndSymlinks(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.Path, "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.Symlink("file1.txt", filepath.Join(repo.Path, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	runGit(t, repo.Path, "add", "run.sh", "link.txt")
+	runGit(t, repo.Path, "commit", "-m", "Add script and symlink")
+
+	destPath := filepath.Join(t.TempDir(), "out")
+	if err := repo.ExtractCommit(context.Background(), "HEAD", destPath); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{"run.sh": 0755, "file1.txt": 0644} {
+		info, err := os.Stat(filepath.Join(destPath, name))
+		if err != nil {
+			t.Fatalf("failed to stat %s: %v", name, err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: expected mode %o, got %o", name, want, got)
+		}
+	}
+
+	target, err := os.Readlink(filepath.Join(destPath, "link.txt"))
+	if err != nil {
+		t.Fatalf("expected link.txt to be a symlink: %v", err)
+	}
+	if target != "file1.txt" {
+		t.Errorf("expected link target file1.txt, got %s", target)
+	}
+
+	// Without symlink preservation the link becomes a file holding its target
+	repo.PreserveSymlinks = false
+	flatPath := filepath.Join(t.TempDir(), "flat")
+	if err := repo.ExtractCommit(context.Background(), "HEAD", flatPath); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+	info, err := os.Lstat(filepath.Join(flatPath, "link.txt"))
+	if err != nil {
+		t.Fatalf("failed to lstat link.txt: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Errorf("expected link.txt to be a regular file, got mode %v", info.Mode())
+	}
+	if data, _ := os.ReadFile(filepath.Join(flatPath, "link.txt")); string(data) != "file1.txt" {
+		t.Errorf("expected placeholder content file1.txt, got %q", data)
+	}
+}
+
+func TestExtractBuiltinTar(t *testing.T) {
+	if !TarAvailable() {
+		t.Skip("tar is not installed")
+	}
+	repo := setupTestRepo(t)
+
+	if err := os.MkdirAll(filepath.Join(repo.Path, "bin", "sub"), 0755); err != nil {
+		t.Fatalf("failed to create directories: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path, "bin", "sub", "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.Symlink("../file1.txt", filepath.Join(repo.Path, "bin", "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	runGit(t, repo.Path, "add", "bin")
+	runGit(t, repo.Path, "commit", "-m", "Add script and symlink")
+
+	// tree describes every entry under root by its mode, modification time
+	// and content or link target
+	tree := func(root string) map[string]string {
+		entries := make(map[string]string)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || path == root {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			desc := info.Mode().String()
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				desc += " -> " + target
+			case info.Mode().IsRegular():
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				desc += " " + info.ModTime().UTC().String() + " " + string(data)
+			default:
+				desc += " " + info.ModTime().UTC().String()
+			}
+			entries[rel] = desc
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to walk %s: %v", root, err)
+		}
+		return entries
+	}
+
+	for _, preservePerms := range []bool{true, false} {
+		repo.PreservePerms = preservePerms
+
+		repo.ExternalTar = true
+		tarPath := filepath.Join(t.TempDir(), "tar")
+		if err := repo.ExtractCommit(context.Background(), "HEAD", tarPath); err != nil {
+			t.Fatalf("ExtractCommit with tar failed: %v", err)
+		}
+		repo.ExternalTar = false
+		builtinPath := filepath.Join(t.TempDir(), "builtin")
+		if err := repo.ExtractCommit(context.Background(), "HEAD", builtinPath); err != nil {
+			t.Fatalf("ExtractCommit with the built-in reader failed: %v", err)
+		}
+
+		want, got := tree(tarPath), tree(builtinPath)
+		if len(want) == 0 {
+			t.Fatal("expected tar to extract files")
+		}
+		for name, desc := range want {
+			if got[name] != desc {
+				t.Errorf("preservePerms=%v: %s: expected %q, got %q", preservePerms, name, desc, got[name])
+			}
+		}
+		for name := range got {
+			if _, ok := want[name]; !ok {
+				t.Errorf("preservePerms=%v: unexpected entry %s", preservePerms, name)
+			}
+		}
+	}
+}
+
+func TestOpenWithoutTar(t *testing.T) {
+	repo := setupTestRepo(t)
+	if !repo.ExternalTar {
+		t.Skip("tar not available")
+	}
+
+	// A PATH holding only git leaves the built-in reader to unpack trees
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatalf("failed to find git: %v", err)
+	}
+	bin := t.TempDir()
+	if err := os.Symlink(gitPath, filepath.Join(bin, "git")); err != nil {
+		t.Fatalf("failed to link git: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	repo, err = Open(repo.Path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if repo.ExternalTar {
+		t.Fatal("expected ExternalTar to be false without tar in PATH")
+	}
+	if err := repo.ExtractCommit(context.Background(), "HEAD", filepath.Join(t.TempDir(), "out")); err != nil {
+		t.Errorf("ExtractCommit without tar failed: %v", err)
+	}
+}
+
+func TestUntarRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{"../escape.txt", "/abs.txt"} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 2, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		_, _ = tw.Write([]byte("hi"))
+		_ = tw.Close()
+
+		dest := t.TempDir()
+		err := untar(&buf, dest, true)
+		if err == nil || !strings.Contains(err.Error(), "unsafe path") {
+			t.Errorf("%s: expected an unsafe path error, got %v", name, err)
+		}
+	}
+}
+
+func TestArchiveToWriter(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	commit, err := repo.ResolveCommit(context.Background(), "HEAD~1")
+	if err != nil {
+		t.Fatalf("ResolveCommit failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := repo.ArchiveToWriter(context.Background(), commit.Hash, &buf); err != nil {
+		t.Fatalf("ArchiveToWriter failed: %v", err)
+	}
+
+	files := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar stream: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = string(data)
+	}
+
+	// HEAD~1 is the initial commit, which only contains file1.txt
+	if len(files) != 1 || files["file1.txt"] != "content1" {
+		t.Errorf("expected only file1.txt with content1, got %v", files)
+	}
+
+}
+
+func TestLinkedWorktree(t *testing.T) {
+	mainRepo := setupTestRepo(t)
+	wtPath := filepath.Join(t.TempDir(), "wt")
+	runGit(t, mainRepo.Path, "worktree", "add", "-q", "-b", "wt-branch", wtPath)
+
+	if err := os.WriteFile(filepath.Join(wtPath, "wt.txt"), []byte("worktree only"), 0644); err != nil {
+		t.Fatalf("failed to write wt.txt: %v", err)
+	}
+	runGit(t, wtPath, "add", "wt.txt")
+	runGit(t, wtPath, "commit", "-m", "Worktree commit")
+
+	repo, err := Open(wtPath)
+	if err != nil {
+		t.Fatalf("failed to open worktree: %v", err)
+	}
+	if !repo.IsLinkedWorktree() {
+		t.Errorf("expected linked worktree, got GitDir=%s CommonDir=%s", repo.GitDir, repo.CommonDir)
+	}
+	if mainRepo.IsLinkedWorktree() {
+		t.Error("expected main checkout not to be a linked worktree")
+	}
+
+	branches, err := repo.ListBranches(context.Background())
+	if err != nil {
+		t.Fatalf("ListBranches failed: %v", err)
+	}
+	found := false
+	for _, b := range branches {
+		found = found || b == "wt-branch"
+	}
+	if !found {
+		t.Errorf("expected wt-branch in %v", branches)
+	}
+
+	// HEAD resolves to the worktree's own branch
+	commits, err := repo.ListCommits(context.Background(), ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "Worktree commit" {
+		t.Fatalf("expected worktree commit at HEAD, got %v", commits)
+	}
+
+	destPath := filepath.Join(t.TempDir(), "out")
+	if err := repo.ExtractCommit(context.Background(), commits[0].Hash, destPath); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(destPath, "wt.txt")); err != nil || string(data) != "worktree only" {
+		t.Errorf("expected wt.txt to be extracted, got %q (%v)", data, err)
+	}
+}
+
+func TestGetTreeMetrics(t *testing.T) {
+	repo := setupFilterRepo(t)
+
+	metrics, err := repo.GetTreeMetrics(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("GetTreeMetrics failed: %v", err)
+	}
+
+	// file1.txt, file2.txt plus the 5 files added by setupFilterRepo
+	if metrics.FileCount != 7 {
+		t.Errorf("expected 7 files, got %d", metrics.FileCount)
+	}
+	// internal/app/app.go and vendor/lib/lib.go are two directories deep
+	if metrics.MaxDepth != 2 {
+		t.Errorf("expected max depth 2, got %d", metrics.MaxDepth)
+	}
+}
+
+func TestResolveCommit(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	commit, err := repo.ResolveCommit(context.Background(), "HEAD~1")
+	if err != nil {
+		t.Fatalf("ResolveCommit failed: %v", err)
+	}
+	if commit.Subject != "Initial commit" || commit.Author != "Test User" || len(commit.Hash) != 40 {
+		t.Errorf("expected fully populated initial commit, got %+v", commit)
+	}
+	if len(commit.ParentHashes) != 0 {
+		t.Errorf("expected root commit without parents, got %v", commit.ParentHashes)
+	}
+
+	if _, err := repo.ResolveCommit(context.Background(), "does-not-exist"); err == nil {
+		t.Error("expected error resolving unknown commit, got nil")
+	}
+}
+
+func TestListCommitsMailmap(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	mailmap := "Canonical Name <canonical@example.com> <test@example.com>\n"
+	if err := os.WriteFile(filepath.Join(repo.Path, ".mailmap"), []byte(mailmap), 0644); err != nil {
+		t.Fatalf("failed to write .mailmap: %v", err)
+	}
+
+	raw, err := repo.ListCommits(context.Background(), ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if raw[0].AuthorEmail != "test@example.com" {
+		t.Errorf("expected raw author email without mailmap, got %s", raw[0].AuthorEmail)
+	}
+
+	mapped, err := repo.ListCommits(context.Background(), ListOptions{Limit: 1, UseMailmap: true})
+	if err != nil {
+		t.Fatalf("ListCommits with mailmap failed: %v", err)
+	}
+	c := mapped[0]
+	if c.Author != "Canonical Name" || c.AuthorEmail != "canonical@example.com" {
+		t.Errorf("expected normalized author, got %s <%s>", c.Author, c.AuthorEmail)
+	}
+	if c.Committer != "Canonical Name" || c.CommitterEmail != "canonical@example.com" {
+		t.Errorf("expected normalized committer, got %s <%s>", c.Committer, c.CommitterEmail)
+	}
+	if c.Subject != raw[0].Subject || c.Hash != raw[0].Hash {
+		t.Errorf("expected remaining fields to parse identically, got %+v", c)
+	}
+}
+
+func TestPipeArchiveToTarCancel(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// A blob much larger than the pipe buffer keeps git archive writing
+	// while the consumer never reads
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	if err := os.WriteFile(filepath.Join(repo.Path, "large.bin"), large, 0644); err != nil {
+		t.Fatalf("failed to write large file: %v", err)
+	}
+	runGit(t, repo.Path, "add", "large.bin")
+	runGit(t, repo.Path, "commit", "-q", "-m", "Add large file")
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		archiveCmd := exec.CommandContext(ctx, "git", "archive", "--format=tar", "HEAD")
+		archiveCmd.Dir = repo.Path
+		tarCmd := exec.CommandContext(ctx, "sleep", "30")
+
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		if err := pipeArchiveToTar(archiveCmd, tarCmd); err == nil {
+			t.Error("expected error after cancellation, got nil")
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("expected cancellation to stop the pipeline promptly, took %s", elapsed)
+		}
+		assertExited(t, archiveCmd, tarCmd)
+	})
+
+	t.Run("consumer exits early", func(t *testing.T) {
+		archiveCmd := exec.Command("git", "archive", "--format=tar", "HEAD")
+		archiveCmd.Dir = repo.Path
+		tarCmd := exec.Command("true")
+
+		if err := pipeArchiveToTar(archiveCmd, tarCmd); err == nil {
+			t.Error("expected git archive to fail on a closed pipe, got nil")
+		}
+		assertExited(t, archiveCmd, tarCmd)
+	})
+}
+
+// assertExited fails the test if any command has not been waited for
+func assertExited(t *testing.T, cmds ...*exec.Cmd) {
+	t.Helper()
+	for _, cmd := range cmds {
+		if cmd.ProcessState == nil {
+			t.Errorf("expected %s to have exited", cmd.Path)
+		}
+	}
+}
+
+func TestKeyAllowlistClassify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowed_keys")
+	content := "# release signers\n\nABCD 1234 EF56 7890 ABCD  1234 4AEE 18F8 3AFD EB23\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write allowlist: %v", err)
+	}
+
+	allowlist, err := LoadKeyAllowlist(path)
+	if err != nil {
+		t.Fatalf("LoadKeyAllowlist failed: %v", err)
+	}
+	if len(allowlist) != 1 {
+		t.Fatalf("expected 1 allowed key, got %d: %v", len(allowlist), allowlist)
+	}
+
+	tests := []struct {
+		name   string
+		commit Commit
+		want   string
+	}{
+		{"unsigned", Commit{GPGSignature: "N"}, TrustUnsigned},
+		{"allowed long key ID", Commit{GPGSignature: "G", GPGKeyID: "4aee18f83afdeb23"}, TrustTrusted},
+		{"allowed fingerprint", Commit{GPGSignature: "U", GPGKeyID: "ABCD1234EF567890ABCD12344AEE18F83AFDEB23"}, TrustTrusted},
+		{"missing public key", Commit{GPGSignature: "E", GPGKeyID: "4AEE18F83AFDEB23"}, "UNTRUSTED (signature cannot be verified)"},
+		{"other key", Commit{GPGSignature: "G", GPGKeyID: "1111222233334444"}, "UNTRUSTED (key 1111222233334444 not in allowlist)"},
+		{"bad signature", Commit{GPGSignature: "B", GPGKeyID: "4AEE18F83AFDEB23"}, "UNTRUSTED (bad signature)"},
+		{"short key ID", Commit{GPGSignature: "G", GPGKeyID: "3AFDEB23"}, "UNTRUSTED (key 3AFDEB23 not in allowlist)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowlist.Classify(tt.commit); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := LoadKeyAllowlist(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing allowlist, got nil")
+	}
+
+	// Short key IDs are easily spoofed and rejected
+	for _, key := range []string{"3AFDEB23", "not-a-key-id-at-all"} {
+		if err := os.WriteFile(path, []byte(key+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write allowlist: %v", err)
+		}
+		if _, err := LoadKeyAllowlist(path); err == nil || !strings.Contains(err.Error(), "16-character key ID") {
+			t.Errorf("%s: expected the key to be rejected, got %v", key, err)
+		}
+	}
+}
+
+func TestExtractExportIgnore(t *testing.T) {
+	repo := setupTestRepo(t)
+	t.Cleanup(func() { _ = repo.Close() })
+
+	if err := os.WriteFile(filepath.Join(repo.Path, ".gitattributes"), []byte("secret.txt export-ignore\n"), 0644); err != nil {
+		t.Fatalf("failed to write .gitattributes: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("failed to write secret.txt: %v", err)
+	}
+	runGit(t, repo.Path, "add", ".gitattributes", "secret.txt")
+	runGit(t, repo.Path, "commit", "-q", "-m", "Add export-ignored file")
+	hash := strings.TrimSpace(runGit(t, repo.Path, "rev-parse", "HEAD"))
+
+	for _, respect := range []bool{true, false} {
+		repo.RespectExportIgnore = respect
+		dest := filepath.Join(t.TempDir(), "out")
+		if err := repo.ExtractCommit(context.Background(), hash, dest); err != nil {
+			t.Fatalf("ExtractCommit failed (respect=%v): %v", respect, err)
+		}
+
+		files := extractedFiles(t, dest)
+		if !files["file1.txt"] {
+			t.Errorf("respect=%v: expected regular files to be extracted, got %v", respect, files)
+		}
+		if files["secret.txt"] == respect {
+			t.Errorf("respect=%v: unexpected presence of secret.txt: %v", respect, files)
+		}
+	}
+
+	overrideDir := repo.overrideDir
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(overrideDir); !os.IsNotExist(err) {
+		t.Errorf("expected override dir to be removed, got %v", err)
+	}
+
+	// Archiving after Close creates the override again
+	dest := filepath.Join(t.TempDir(), "again")
+	if err := repo.ExtractCommit(context.Background(), hash, dest); err != nil {
+		t.Fatalf("ExtractCommit after Close failed: %v", err)
+	}
+	if files := extractedFiles(t, dest); !files["secret.txt"] {
+		t.Errorf("expected secret.txt to be extracted after Close, got %v", files)
+	}
+}
+
+func TestExtractExportIgnoreSHA256(t *testing.T) {
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "--object-format=sha256", dir).CombinedOutput(); err != nil {
+		t.Skipf("git does not support SHA-256 repositories: %s", out)
+	}
+	runGit(t, dir, "config", "user.email", "test@test.com")
+	runGit(t, dir, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("secret.txt export-ignore\n"), 0644); err != nil {
+		t.Fatalf("failed to write .gitattributes: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("failed to write secret.txt: %v", err)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "Add export-ignored file")
+	hash := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	repo.RespectExportIgnore = false
+
+	dest := filepath.Join(t.TempDir(), "out")
+	if err := repo.ExtractCommit(context.Background(), hash, dest); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+	if files := extractedFiles(t, dest); !files["secret.txt"] {
+		t.Errorf("expected secret.txt to be extracted, got %v", files)
+	}
+}
+
+func TestCommandDebugLog(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	var buf bytes.Buffer
+	repo.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	if _, err := repo.CommitCount(context.Background(), ""); err != nil {
+		t.Fatalf("CommitCount failed: %v", err)
+	}
+
+	var entry struct {
+		Level    string `json:"level"`
+		Msg      string `json:"msg"`
+		Cmd      string `json:"cmd"`
+		Dir      string `json:"dir"`
+		Duration *int64 `json:"duration"`
+	}
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+
+	if entry.Level != "DEBUG" || entry.Msg != "command finished" {
+		t.Errorf("unexpected log entry: %+v", entry)
+	}
+	if !strings.HasPrefix(entry.Cmd, "git rev-list") {
+		t.Errorf("expected git command in log, got %q", entry.Cmd)
+	}
+	if entry.Dir != repo.Path {
+		t.Errorf("expected dir %s, got %s", repo.Path, entry.Dir)
+	}
+	if entry.Duration == nil || *entry.Duration <= 0 {
+		t.Errorf("expected a positive duration, got %v", entry.Duration)
+	}
+}
+
+func TestGetFormatPatch(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	commits, err := repo.ListCommits(ctx, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+
+	// Covers both the root commit and a regular one
+	for _, c := range commits {
+		patch, err := repo.GetFormatPatch(ctx, c.Hash)
+		if err != nil {
+			t.Fatalf("GetFormatPatch(%s) failed: %v", c.ShortHash, err)
+		}
+		if !strings.HasPrefix(patch, "From "+c.Hash) {
+			t.Errorf("patch for %s does not start with From line: %q", c.ShortHash, patch)
+		}
+		if !strings.Contains(patch, c.Subject) {
+			t.Errorf("patch for %s does not contain subject %q", c.ShortHash, c.Subject)
+		}
+	}
+}
+
+func TestFindLFSPointers(t *testing.T) {
+	dir := t.TempDir()
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+	files := map[string]string{
+		"model.bin":        pointer,
+		"nested/data.bin":  pointer,
+		"README.md":        "# readme\n",
+		"short.txt":        "version",
+		"large-prefix.txt": pointer + strings.Repeat("x", 2048),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	pointers, err := FindLFSPointers(dir)
+	if err != nil {
+		t.Fatalf("FindLFSPointers failed: %v", err)
+	}
+	if len(pointers) != 2 {
+		t.Fatalf("expected 2 LFS pointers, got %v", pointers)
+	}
+	for _, p := range pointers {
+		if base := filepath.Base(p); base != "model.bin" && base != "data.bin" {
+			t.Errorf("unexpected pointer %s", p)
+		}
+	}
+}
+
+func TestGetDiffStat(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	stat, err := repo.GetDiffStat(ctx, "HEAD")
+	if err != nil {
+		t.Fatalf("GetDiffStat failed: %v", err)
+	}
+	if !strings.Contains(stat, "file2.txt") {
+		t.Errorf("expected diffstat to list file2.txt, got %q", stat)
+	}
+	if !strings.Contains(stat, "1 file changed, 1 insertion(+)") {
+		t.Errorf("expected a summary line, got %q", stat)
+	}
+}
+
+func TestListReflog(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	// Resetting makes the second commit unreachable, leaving it in the reflog
+	dropped := strings.TrimSpace(runGit(t, repo.Path, "rev-parse", "HEAD"))
+	runGit(t, repo.Path, "reset", "-q", "--hard", "HEAD~1")
+	if err := os.WriteFile(filepath.Join(repo.Path, "file3.txt"), []byte("content3"), 0644); err != nil {
+		t.Fatalf("failed to write file3: %v", err)
+	}
+	runGit(t, repo.Path, "add", "file3.txt")
+	runGit(t, repo.Path, "commit", "-q", "-m", "Rewritten commit")
+
+	commits, err := repo.ListReflog(ctx, "")
+	if err != nil {
+		t.Fatalf("ListReflog failed: %v", err)
+	}
+
+	// The initial commit appears twice in the reflog (HEAD@{1} and HEAD@{3})
+	// but is listed once, under its most recent selector
+	want := []string{"Rewritten commit", "Initial commit", "Commit with | pipe"}
+	if len(commits) != len(want) {
+		t.Fatalf("expected %d reflog commits, got %d: %v", len(want), len(commits), commits)
+	}
+	for i, c := range commits {
+		if c.Subject != want[i] {
+			t.Errorf("entry %d: expected %q, got %q", i, want[i], c.Subject)
+		}
+		if !strings.HasPrefix(c.ReflogSelector, "HEAD@{") {
+			t.Errorf("entry %d: unexpected selector %q", i, c.ReflogSelector)
+		}
+	}
+	if commits[2].Hash != dropped {
+		t.Errorf("expected the dropped commit %s, got %s", dropped, commits[2].Hash)
+	}
+	if commits[1].ReflogSelector != "HEAD@{1}" {
+		t.Errorf("expected the initial commit at HEAD@{1}, got %q", commits[1].ReflogSelector)
+	}
+}
+
+func TestListCommitsChan(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	stream, errc := repo.ListCommitsChan(context.Background(), ListOptions{Reverse: true})
+	var subjects []string
+	for c := range stream {
+		subjects = append(subjects, c.Subject)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("ListCommitsChan failed: %v", err)
+	}
+	if len(subjects) != 2 || subjects[0] != "Initial commit" || subjects[1] != "Commit with | pipe" {
+		t.Errorf("unexpected commits: %q", subjects)
+	}
+
+	if n, err := repo.CountCommits(context.Background(), ListOptions{Reverse: true}); err != nil || n != 2 {
+		t.Errorf("expected CountCommits to match the stream, got %d (%v)", n, err)
+	}
+
+	// Cancelling mid-stream closes both channels without a consumer draining them
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, errc = repo.ListCommitsChan(ctx, ListOptions{})
+	<-stream
+	cancel()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listing did not stop after cancellation")
+	}
+	if _, ok := <-stream; ok {
+		t.Error("expected the commit channel to be closed")
+	}
+}
+
+func TestExtractSubmodules(t *testing.T) {
+	base := t.TempDir()
+	lib := filepath.Join(base, "lib")
+	runGit(t, base, "init", "-q", "lib")
+	runGit(t, lib, "config", "user.name", "Test User")
+	runGit(t, lib, "config", "user.email", "test@example.com")
+	if err := os.WriteFile(filepath.Join(lib, "lib.txt"), []byte("library"), 0644); err != nil {
+		t.Fatalf("failed to write lib.txt: %v", err)
+	}
+	runGit(t, lib, "add", ".")
+	runGit(t, lib, "commit", "-q", "-m", "Library")
+
+	super := filepath.Join(base, "super")
+	runGit(t, base, "init", "-q", "super")
+	runGit(t, super, "config", "user.name", "Test User")
+	runGit(t, super, "config", "user.email", "test@example.com")
+	runGit(t, super, "-c", "protocol.file.allow=always", "submodule", "add", "-q", "../lib", "vendor/lib")
+	// A gitlink to a commit that exists nowhere locally
+	runGit(t, super, "update-index", "--add", "--cacheinfo", "160000,1111111111111111111111111111111111111111,missing")
+	runGit(t, super, "commit", "-q", "-m", "Add submodules")
+
+	repo, err := Open(super)
+	if err != nil {
+		t.Fatalf("failed to open repo: %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "out")
+	ctx := context.Background()
+	if err := repo.ExtractCommit(ctx, "HEAD", dest); err != nil {
+		t.Fatalf("ExtractCommit failed: %v", err)
+	}
+	unresolved, err := repo.ExtractSubmodules(ctx, "HEAD", dest)
+	if err != nil {
+		t.Fatalf("ExtractSubmodules failed: %v", err)
+	}
+
+	if got, err := os.ReadFile(filepath.Join(dest, "vendor", "lib", "lib.txt")); err != nil || string(got) != "library" {
+		t.Errorf("expected submodule file under vendor/lib, got %q (%v)", got, err)
+	}
+	if len(unresolved) != 1 || unresolved[0] != "missing" {
+		t.Errorf("expected the missing submodule to be unresolved, got %v", unresolved)
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	msg := "Pair on the parser\n\nWorked through the edge cases together.\n\n" +
+		"Co-authored-by: Alice <alice@example.com>\n" +
+		"Co-authored-by: Bob <bob@example.com>\n" +
+		"Signed-off-by: Carol <carol@example.com>\n"
+	want := []string{"Alice <alice@example.com>", "Bob <bob@example.com>"}
+
+	trailers := ParseTrailers(msg)
+	got := trailers["Co-authored-by"]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected co-authors %q, got %q", want, got)
+	}
+	if len(trailers["Signed-off-by"]) != 1 {
+		t.Errorf("expected one Signed-off-by trailer, got %v", trailers)
+	}
+
+	if trailers := ParseTrailers("Fix: crash on startup\n"); trailers != nil {
+		t.Errorf("expected a subject-only message to have no trailers, got %v", trailers)
+	}
+	if trailers := ParseTrailers("Fix parser\n\nSigned-off-by: Carol\nnot a trailer\n"); trailers != nil {
+		t.Errorf("expected a mixed final paragraph to have no trailers, got %v", trailers)
+	}
+	continued := ParseTrailers("Fix parser\n\nReviewed-by: Dan\n  <dan@example.com>\n")
+	if got := continued["Reviewed-by"]; len(got) != 1 || got[0] != "Dan <dan@example.com>" {
+		t.Errorf("expected continuation line to be joined, got %q", got)
+	}
+
+	var buf bytes.Buffer
+	commit := Commit{AuthorDate: time.Now(), CommitDate: time.Now(), Trailers: ParseTrailers(msg)}
+	if err := metadataTemplate.Execute(&buf, commit); err != nil {
+		t.Fatalf("failed to render metadata: %v", err)
+	}
+	if !strings.Contains(buf.String(), "TRAILERS\n--------\nCo-authored-by: Alice <alice@example.com>\nCo-authored-by: Bob <bob@example.com>\nSigned-off-by: Carol") {
+		t.Errorf("expected a TRAILERS section, got:\n%s", buf.String())
+	}
+}
+
+func TestLoadMetadataTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "info.tmpl")
+	if err := os.WriteFile(tmplPath, []byte("{{.ShortHash}} {{.Subject}} [{{formatGPGStatus .GPGSignature}}]\n"), 0644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+
+	tmpl, err := LoadMetadataTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("LoadMetadataTemplate failed: %v", err)
+	}
+	metadataPath := filepath.Join(dir, "COMMIT_INFO.txt")
+	commit := Commit{ShortHash: "abc1234", Subject: "Add parser", GPGSignature: "G"}
+	if err := commit.WriteMetadataWith(tmpl, metadataPath); err != nil {
+		t.Fatalf("WriteMetadataWith failed: %v", err)
+	}
+	got, err := os.ReadFile(metadataPath)
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	if want := "abc1234 Add parser [Valid signature (good)]\n"; string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for name, content := range map[string]string{
+		"unknown field":  "{{.Branch}}",
+		"nested unknown": "{{with .TreeMetrics}}{{.Files}}{{end}}",
+		"syntax":         "{{.Hash",
+	} {
+		if err := os.WriteFile(tmplPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+		if _, err := LoadMetadataTemplate(tmplPath); err == nil {
+			t.Errorf("%s: expected template %q to be rejected", name, content)
+		}
+	}
+}
+
+func TestListCommitsPickaxe(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	secret := filepath.Join(repo.Path, "config.ini")
+	for i, step := range []struct{ content, subject string }{
+		{"user=admin\npassword=hunter2\n", "Add config"},
+		{"user=root\npassword=hunter2\n", "Rename user"},
+		{"user=root\n", "Remove password"},
+	} {
+		if err := os.WriteFile(secret, []byte(step.content), 0644); err != nil {
+			t.Fatalf("step %d: failed to write config: %v", i, err)
+		}
+		runGit(t, repo.Path, "add", "config.ini")
+		runGit(t, repo.Path, "commit", "-q", "-m", step.subject)
+	}
+
+	want := []string{"Add config", "Remove password"}
+	for _, opts := range []ListOptions{
+		{Reverse: true, PickaxeString: "hunter2"},
+		{Reverse: true, PickaxeRegex: "^password="},
+	} {
+		commits, err := repo.ListCommits(ctx, opts)
+		if err != nil {
+			t.Fatalf("ListCommits failed: %v", err)
+		}
+		if len(commits) != len(want) {
+			t.Fatalf("%+v: expected %d commits, got %v", opts, len(want), commits)
+		}
+		for i, c := range commits {
+			if c.Subject != want[i] {
+				t.Errorf("%+v: commit %d: expected %q, got %q", opts, i, want[i], c.Subject)
+			}
+		}
+
+		count, err := repo.CountCommits(ctx, opts)
+		if err != nil {
+			t.Fatalf("CountCommits failed: %v", err)
+		}
+		if count != len(want) {
+			t.Errorf("%+v: expected a count of %d, got %d", opts, len(want), count)
+		}
+	}
+}
+
+// setupMetadataRepo creates a repository with a root commit, a binary file,
+// a multi-paragraph message and a merge, followed by n plain commits
+func setupMetadataRepo(tb testing.TB, n int) *Repository {
+	tb.Helper()
+	dir := tb.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			tb.Fatalf("git %v failed: %v\nOutput: %s", args, err, out)
+		}
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			tb.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	run("config", "user.name", "Test User")
+	run("config", "user.email", "test@example.com")
+	write("a.txt", []byte("one\ntwo\n"))
+	run("add", ".")
+	run("commit", "-q", "-m", "Root")
+
+	run("checkout", "-q", "-b", "topic")
+	write("image.bin", []byte{0, 1, 2, 0, 3})
+	run("add", ".")
+	run("commit", "-q", "-m", "Add binary", "-m", "Second paragraph.", "-m", "Signed-off-by: Test User <test@example.com>")
+
+	run("checkout", "-q", "main")
+	write("a.txt", []byte("one\nthree\nfour\n"))
+	run("commit", "-q", "-am", "Edit a")
+	run("merge", "-q", "--no-ff", "-m", "Merge topic", "topic")
+
+	for i := range n {
+		write("b.txt", []byte(strings.Repeat("line\n", i+1)))
+		run("add", ".")
+		run("commit", "-q", "-m", "Commit "+strconv.Itoa(i))
+	}
+
+	repo, err := Open(dir)
+	if err != nil {
+		tb.Fatalf("failed to open repo: %v", err)
+	}
+	return repo
+}
+
+func TestGetCommitsMetadata(t *testing.T) {
+	repo := setupMetadataRepo(t, 2)
+	ctx := context.Background()
+
+	commits, err := repo.ListCommits(ctx, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	hashes := make([]string, len(commits))
+	for i, c := range commits {
+		hashes[i] = c.Hash
+	}
+
+	metadata, err := repo.GetCommitsMetadata(ctx, hashes)
+	if err != nil {
+		t.Fatalf("GetCommitsMetadata failed: %v", err)
+	}
+	if len(metadata) != len(commits) {
+		t.Fatalf("expected metadata for %d commits, got %d", len(commits), len(metadata))
+	}
+
+	// The batch must agree with the per-commit calls it replaces
+	for _, c := range commits {
+		fullMsg, err := repo.GetCommitFullMessage(ctx, c.Hash)
+		if err != nil {
+			t.Fatalf("GetCommitFullMessage failed: %v", err)
+		}
+		stats, err := repo.GetCommitStats(ctx, c.Hash)
+		if err != nil {
+			t.Fatalf("GetCommitStats failed: %v", err)
+		}
+		got := metadata[c.Hash]
+		if got.FullMessage != fullMsg {
+			t.Errorf("%s: expected message %q, got %q", c.Subject, fullMsg, got.FullMessage)
+		}
+		if got.Stats != stats {
+			t.Errorf("%s: expected stats %+v, got %+v", c.Subject, stats, got.Stats)
+		}
+	}
+}
+
+func BenchmarkCommitMetadata(b *testing.B) {
+	repo := setupMetadataRepo(b, 60)
+	ctx := context.Background()
+
+	commits, err := repo.ListCommits(ctx, ListOptions{})
+	if err != nil {
+		b.Fatalf("ListCommits failed: %v", err)
+	}
+	hashes := make([]string, len(commits))
+	for i, c := range commits {
+		hashes[i] = c.Hash
+	}
+
+	b.Run("per-commit", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, hash := range hashes {
+				if _, err := repo.GetCommitFullMessage(ctx, hash); err != nil {
+					b.Fatalf("GetCommitFullMessage failed: %v", err)
+				}
+				if _, err := repo.GetCommitStats(ctx, hash); err != nil {
+					b.Fatalf("GetCommitStats failed: %v", err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetCommitsMetadata(ctx, hashes); err != nil {
+				b.Fatalf("GetCommitsMetadata failed: %v", err)
+			}
+		}
+	})
+}
+
+func TestListTags(t *testing.T) {
+	repo := setupTestRepo(t)
+	runGit(t, repo.Path, "tag", "v1.2.0", "HEAD~1")
+	runGit(t, repo.Path, "tag", "-a", "-m", "Release 1.9.0", "v1.9.0")
+	runGit(t, repo.Path, "tag", "v1.10.0")
+	runGit(t, repo.Path, "tag", "nightly")
+	runGit(t, repo.Path, "tag", "vtree", "HEAD^{tree}")
+
+	commits, err := repo.ListTags(context.Background(), "v*")
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	var names []string
+	for _, c := range commits {
+		names = append(names, c.Tag)
+	}
+	// Tags are listed by name; the tree tag has no commit
+	if got := strings.Join(names, " "); got != "v1.10.0 v1.2.0 v1.9.0" {
+		t.Fatalf("expected tags in name order, got %q", got)
+	}
+	if commits[1].Subject != "Initial commit" || commits[2].Subject != "Commit with | pipe" {
+		t.Errorf("unexpected tagged commits: %v", commits)
+	}
+
+	names = names[:0]
+	for _, c := range SortSemver(commits) {
+		names = append(names, c.Tag)
+	}
+	if got := strings.Join(names, " "); got != "v1.2.0 v1.9.0 v1.10.0" {
+		t.Errorf("expected tags in version order, got %q", got)
+	}
+}
+
+func TestGetTagSignature(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+	runGit(t, repo.Path, "tag", "-a", "-m", "Release 1.0.0", "v1.0.0")
+	runGit(t, repo.Path, "tag", "light")
+
+	for _, tag := range []string{"v1.0.0", "light"} {
+		sig, err := repo.GetTagSignature(ctx, tag)
+		if err != nil {
+			t.Fatalf("GetTagSignature(%s) failed: %v", tag, err)
+		}
+		if got := formatGPGStatus(sig.Status); got != "Not signed" {
+			t.Errorf("expected %s to be Not signed, got %q", tag, got)
+		}
+	}
+
+	t.Run("verifier failure", func(t *testing.T) {
+		object := strings.TrimSpace(runGit(t, repo.Path, "rev-parse", "HEAD"))
+		mktag := exec.Command("git", "mktag")
+		mktag.Dir = repo.Path
+		mktag.Stdin = strings.NewReader("object " + object + "\ntype commit\ntag forged\ntagger Test <test@example.com> 0 +0000\n\nForged\n" +
+			"-----BEGIN PGP SIGNATURE-----\n\nAAAA\n-----END PGP SIGNATURE-----\n")
+		out, err := mktag.Output()
+		if err != nil {
+			t.Fatalf("git mktag failed: %v", err)
+		}
+		runGit(t, repo.Path, "update-ref", "refs/tags/forged", strings.TrimSpace(string(out)))
+
+		gpg := filepath.Join(t.TempDir(), "gpg")
+		runGit(t, repo.Path, "config", "gpg.program", gpg)
+		t.Cleanup(func() { runGit(t, repo.Path, "config", "--unset", "gpg.program") })
+
+		// A verifier rejecting the signature is a status, not an error
+		if err := os.WriteFile(gpg, []byte("#!/bin/sh\nexit 2\n"), 0o755); err != nil {
+			t.Fatalf("failed to write gpg script: %v", err)
+		}
+		sig, err := repo.GetTagSignature(ctx, "forged")
+		if err != nil {
+			t.Fatalf("GetTagSignature failed: %v", err)
+		}
+		if sig.Status != "E" {
+			t.Errorf("expected status E for a failed verification, got %+v", sig)
+		}
+
+		// A verifier killed by --git-timeout is an error
+		if err := os.WriteFile(gpg, []byte("#!/bin/sh\nexec sleep 1\n"), 0o755); err != nil {
+			t.Fatalf("failed to write gpg script: %v", err)
+		}
+		repo.Timeout = 100 * time.Millisecond
+		defer func() { repo.Timeout = 0 }()
+		if _, err := repo.GetTagSignature(ctx, "forged"); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("expected a timeout error, got %v", err)
+		}
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		if _, err := exec.LookPath("gpg"); err != nil {
+			t.Skip("gpg not available")
+		}
+		// A short home keeps the gpg-agent socket path within its length limit
+		home, err := os.MkdirTemp("", "gpg")
+		if err != nil {
+			t.Fatalf("failed to create gpg home: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+			_ = os.RemoveAll(home)
+		})
+		t.Setenv("GNUPGHOME", home)
+		gen := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "Tag Signer <signer@example.com>", "default", "default", "never")
+		if out, err := gen.CombinedOutput(); err != nil {
+			t.Skipf("failed to generate a gpg key: %v\n%s", err, out)
+		}
+
+		runGit(t, repo.Path, "tag", "-s", "-u", "signer@example.com", "-m", "Release 2.0.0", "v2.0.0")
+		sig, err := repo.GetTagSignature(ctx, "v2.0.0")
+		if err != nil {
+			t.Fatalf("GetTagSignature failed: %v", err)
+		}
+		// The generated key is ultimately trusted by its own keyring
+		if sig.Status != "G" || sig.KeyID == "" || sig.Signer != "Tag Signer <signer@example.com>" {
+			t.Errorf("expected a good signature by the generated key, got %+v", sig)
+		}
+		if trust := (KeyAllowlist{sig.KeyID}).ClassifyTag(sig); trust != TrustTrusted {
+			t.Errorf("expected the signing key to be trusted, got %q", trust)
+		}
+		if trust := (KeyAllowlist{"0000000000000000"}).ClassifyTag(sig); !strings.HasPrefix(trust, "UNTRUSTED") {
+			t.Errorf("expected another key to be untrusted, got %q", trust)
+		}
+	})
+}
+
+func TestSortSemver(t *testing.T) {
+	var commits []Commit
+	for _, tag := range []string{
+		"v1.10.0", "v1.2.0", "v1.9.0", "1.0.0", "v1.0.0-rc.1", "v1.0.0-alpha", "v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta", "v1.0.0-beta.11", "v1.0.0-beta.2", "v2.0.0+build.5",
+		"release-1", "v1.2", "v01.2.3", "v1.0.0-01",
+	} {
+		commits = append(commits, Commit{Tag: tag})
+	}
+
+	var got []string
+	for _, c := range SortSemver(commits) {
+		got = append(got, c.Tag)
+	}
+	want := []string{
+		"v1.0.0-alpha", "v1.0.0-alpha.1", "v1.0.0-alpha.beta", "v1.0.0-beta.2", "v1.0.0-beta.11",
+		"v1.0.0-rc.1", "1.0.0", "v1.2.0", "v1.9.0", "v1.10.0", "v2.0.0+build.5",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestCheckTools(t *testing.T) {
+	original := commandOutput
+	t.Cleanup(func() { commandOutput = original })
+
+	fake := func(gitOut string, gitErr, tarErr error) func(string, ...string) ([]byte, error) {
+		return func(name string, _ ...string) ([]byte, error) {
+			if name == "tar" {
+				return []byte("tar (GNU tar) 1.34\n"), tarErr
+			}
+			return []byte(gitOut), gitErr
+		}
+	}
+	notFound := &exec.Error{Name: "git", Err: exec.ErrNotFound}
+
+	tests := []struct {
+		name    string
+		gitOut  string
+		gitErr  error
+		wantErr string
+	}{
+		{name: "current", gitOut: "git version 2.39.5\n"},
+		{name: "windows build", gitOut: "git version 2.45.1.windows.1\n"},
+		{name: "apple build", gitOut: "git version 2.24.3 (Apple Git-128)\n"},
+		{name: "too old", gitOut: "git version 2.20.1\n", wantErr: "git 2.20.1 is too old: repopsy needs git 2.24.0 or later"},
+		{name: "missing git", gitErr: notFound, wantErr: "git was not found in PATH"},
+		{name: "unexpected output", gitOut: "hub version 2.14.2\n", wantErr: "unexpected git --version output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandOutput = fake(tt.gitOut, tt.gitErr, nil)
+			err := CheckTools()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// A missing tar is not an error: the built-in reader replaces it
+	commandOutput = fake("git version 2.39.5\n", nil, notFound)
+	if err := CheckTools(); err != nil {
+		t.Errorf("expected no error without tar, got %v", err)
+	}
+	if TarAvailable() {
+		t.Error("expected tar to be reported unavailable")
+	}
+	commandOutput = fake("git version 2.39.5\n", nil, nil)
+	if !TarAvailable() {
+		t.Error("expected tar to be reported available")
+	}
+
+	commandOutput = fake("", notFound, nil)
+	if err := CheckTools(); !errors.Is(err, ErrGitNotFound) {
+		t.Errorf("expected ErrGitNotFound, got %v", err)
+	}
+}
+
+func TestStructuredErrors(t *testing.T) {
+	if _, err := Open(t.TempDir()); !errors.Is(err, ErrNotARepository) {
+		t.Errorf("expected ErrNotARepository for a plain directory, got %v", err)
+	}
+
+	repo := setupTestRepo(t)
+	missing := strings.Repeat("0", 40)
+	extract := map[string]func() error{
+		"extract": func() error {
+			return repo.ExtractCommit(context.Background(), missing, filepath.Join(t.TempDir(), "out"))
+		},
+		"archive": func() error {
+			return repo.ArchiveCommit(context.Background(), missing, filepath.Join(t.TempDir(), "out.tar"), PathFilter{})
+		},
+	}
+	for name, run := range extract {
+		t.Run(name, func(t *testing.T) {
+			err := run()
+			var extErr *ExtractionError
+			if !errors.As(err, &extErr) {
+				t.Fatalf("expected an ExtractionError, got %v", err)
+			}
+			if extErr.Hash != missing || !strings.Contains(extErr.Stderr, "not a tree object") {
+				t.Errorf("expected the hash and git's error, got %+v", extErr)
+			}
+		})
+	}
+}
+
+func TestGitCommandEnvironment(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// A shell alias prints the environment git passes to its children
+	output, err := repo.runGitCommand(context.Background(), "-c", "alias.env=!env", "env")
+	if err != nil {
+		t.Fatalf("runGitCommand failed: %v", err)
+	}
+	env := strings.Split(output, "\n")
+	for _, want := range []string{"GIT_TERMINAL_PROMPT=0", "LC_ALL=C"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("expected %s in the git environment", want)
+		}
+	}
+}
+
+func TestListCommitsLongSubject(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// Longer than the default scanner buffer
+	subject := strings.Repeat("long subject ", 2*1024*1024/13) + "end"
+	msgFile := filepath.Join(t.TempDir(), "msg")
+	if err := os.WriteFile(msgFile, []byte(subject+"\n\nBody"), 0600); err != nil {
+		t.Fatalf("failed to write message: %v", err)
+	}
+	runGit(t, repo.Path, "commit", "-q", "--allow-empty", "--cleanup=verbatim", "-F", msgFile)
+
+	commits, err := repo.ListCommits(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCommits failed: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("expected 3 commits, got %d", len(commits))
+	}
+	if commits[0].Subject != subject {
+		t.Errorf("expected the %d-byte subject intact, got %d bytes", len(subject), len(commits[0].Subject))
+	}
+	if commits[1].Subject != "Commit with | pipe" {
+		t.Errorf("expected the next commit to parse, got subject %q", commits[1].Subject)
+	}
+
+	// An explicit buffer size is honoured, also when smaller
+	repo.BufferSize = 1024 * 1024
+	if _, err := repo.ListCommits(context.Background(), ListOptions{}); err == nil || !strings.Contains(err.Error(), "longer than 1048576 bytes") {
+		t.Errorf("expected the subject to exceed the explicit buffer size, got %v", err)
+	}
+
+	// NULs in the subject, which comes last, do not shift other fields
+	line := strings.Join([]string{"h", "s", "A", "a@x", "1", "C", "c@x", "2", "N", "", "sub\x00ject"}, "\x00")
+	commit, err := parseCommitLine(line)
+	if err != nil || commit.Subject != "subject" || commit.CommitDate.Unix() != 2 {
+		t.Errorf("expected NULs dropped from the subject, got %+v, %v", commit, err)
+	}
+}
+
+func TestFileTimes(t *testing.T) {
+	repo := setupTestRepo(t)
+	var buf bytes.Buffer
+	repo.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	first := strings.TrimSpace(runGit(t, repo.Path, "log", "-1", "--format=%at", "HEAD~1"))
+	times, err := repo.FileTimes(context.Background(), "HEAD", []string{"file1.txt", "COMMIT_INFO.txt", "sub/file.txt"})
+	if err != nil {
+		t.Fatalf("FileTimes failed: %v", err)
+	}
+	if len(times) != 1 || strconv.FormatInt(times["file1.txt"].Unix(), 10) != first {
+		t.Errorf("expected only file1.txt dated by the first commit (%s), got %v", first, times)
+	}
+
+	// Paths outside the tree never start a history walk
+	buf.Reset()
+	if times, err := repo.FileTimes(context.Background(), "HEAD", []string{"COMMIT_INFO.txt", "sub/file.txt"}); err != nil || len(times) != 0 {
+		t.Errorf("expected no times for paths outside the tree, got %v, %v", times, err)
+	}
+	if strings.Contains(buf.String(), "git log") {
+		t.Errorf("expected no history walk, got log:\n%s", buf.String())
+	}
+}
+
+func TestReadPipedCommits(t *testing.T) {
+	repo := setupTestRepo(t)
+	piped := runGit(t, repo.Path, "log", "--format="+PipedLogFormat) + "# comment\nunknown\n"
+
+	commits, unresolved, err := repo.ReadPipedCommits(context.Background(), strings.NewReader(piped))
+	if err != nil {
+		t.Fatalf("ReadPipedCommits failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "Commit with | pipe" || commits[1].Subject != "Initial commit" {
+		t.Errorf("expected both log lines parsed in order, got %v", commits)
+	}
+	if len(unresolved) != 1 || unresolved[0] != "unknown" {
+		t.Errorf("expected the unknown line reported, got %v", unresolved)
+	}
+
+	// Bare names are resolved together, whatever their number
+	var buf bytes.Buffer
+	repo.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tree := strings.TrimSpace(runGit(t, repo.Path, "rev-parse", "HEAD^{tree}"))
+	piped = runGit(t, repo.Path, "log", "--oneline") + tree + "\nHEAD~1\nunknown\n"
+	commits, unresolved, err = repo.ReadPipedCommits(context.Background(), strings.NewReader(piped))
+	if err != nil {
+		t.Fatalf("ReadPipedCommits failed: %v", err)
+	}
+	if len(commits) != 3 || commits[0].Subject != "Commit with | pipe" || commits[1].Subject != "Initial commit" || commits[2].Subject != "Initial commit" {
+		t.Errorf("expected the named commits in order, got %v", commits)
+	}
+	if len(unresolved) != 2 || unresolved[0] != tree || unresolved[1] != "unknown" {
+		t.Errorf("expected the tree and the unknown name reported, got %v", unresolved)
+	}
+	if n := strings.Count(buf.String(), "command finished"); n != 2 {
+		t.Errorf("expected 2 git calls, got %d:\n%s", n, buf.String())
+	}
+}
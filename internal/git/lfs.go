@@ -0,0 +1,115 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// lfsPointerPrefix starts every Git LFS pointer file
+const lfsPointerPrefix = "version https://git-lfs"
+
+// lfsPointerMaxSize bounds pointer files; git-lfs never writes larger ones
+const lfsPointerMaxSize = 1024
+
+// LFSInstalled reports whether the git-lfs extension is available
+func LFSInstalled() bool {
+	_, err := exec.LookPath("git-lfs")
+	return err == nil
+}
+
+// FindLFSPointers returns the paths of the Git LFS pointer files under root
+func FindLFSPointers(root string) ([]string, error) {
+	var pointers []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		ok, err := isLFSPointer(path)
+		if ok {
+			pointers = append(pointers, path)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for LFS pointers: %w", err)
+	}
+	return pointers, nil
+}
+
+// isLFSPointer reports whether the file at path is a Git LFS pointer
+func isLFSPointer(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > lfsPointerMaxSize {
+		return false, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(lfsPointerPrefix))
+	if _, err := io.ReadFull(f, head); err != nil {
+		// Files shorter than the prefix are not pointers
+		return false, nil
+	}
+	return string(head) == lfsPointerPrefix, nil
+}
+
+// SmudgeLFS replaces the LFS pointer file at path with the object it points
+// to, using git lfs smudge. The object is read from the repository's LFS
+// store, or downloaded from its LFS remote when missing.
+func (r *Repository) SmudgeLFS(ctx context.Context, path string) error {
+	pointer, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open LFS pointer: %w", err)
+	}
+	defer pointer.Close()
+
+	info, err := pointer.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat LFS pointer: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lfs-*")
+	if err != nil {
+		return fmt.Errorf("failed to create LFS object file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	cmd, cancel := r.gitCommand(ctx, "lfs", "smudge")
+	defer cancel()
+	cmd.Stdin = pointer
+	cmd.Stdout = tmp
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	runErr := r.run(cmd)
+	if err := tmp.Close(); err != nil && runErr == nil {
+		runErr = err
+	}
+	if runErr != nil {
+		return fmt.Errorf("git lfs smudge failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	// git-lfs may pass the pointer through when the object is unavailable
+	if still, _ := isLFSPointer(tmp.Name()); still {
+		return fmt.Errorf("LFS object for %s is not available", filepath.Base(path))
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set LFS object mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace LFS pointer: %w", err)
+	}
+	return nil
+}
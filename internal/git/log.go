@@ -0,0 +1,46 @@
+package git
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// logger returns the repository logger, discarding logs when none is set
+func (r *Repository) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return r.Logger
+}
+
+// logCommand logs a finished command and its duration at debug level
+func (r *Repository) logCommand(cmd *exec.Cmd, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String("cmd", strings.Join(cmd.Args, " ")),
+		slog.String("dir", cmd.Dir),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	r.logger().LogAttrs(context.Background(), slog.LevelDebug, "command finished", attrs...)
+}
+
+// output runs cmd, logs it and returns its standard output
+func (r *Repository) output(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	out, err := cmd.Output()
+	r.logCommand(cmd, start, err)
+	return out, r.timeoutError(start, err)
+}
+
+// run runs cmd and logs it
+func (r *Repository) run(cmd *exec.Cmd) error {
+	start := time.Now()
+	err := cmd.Run()
+	r.logCommand(cmd, start, err)
+	return r.timeoutError(start, err)
+}
@@ -0,0 +1,43 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// GetFormatPatch returns a commit as an mbox patch in git format-patch form,
+// starting with its "From <hash>" line. Merge commits yield an empty patch.
+func (r *Repository) GetFormatPatch(ctx context.Context, hash string) (string, error) {
+	// --root lets root commits produce a patch against the empty tree
+	cmd, cancel := r.gitCommand(ctx, "format-patch", "-1", "--root", "--stdout", "--no-color", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to format patch: %w", err)
+	}
+	return string(output), nil
+}
+
+// WriteDiff writes the diff from one commit's tree to another's into the
+// file at destFile
+func (r *Repository) WriteDiff(ctx context.Context, from, to, destFile string) (err error) {
+	f, err := os.Create(destFile)
+	if err != nil {
+		return fmt.Errorf("failed to create diff file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close diff file: %w", closeErr)
+		}
+	}()
+
+	cmd, cancel := r.gitCommand(ctx, "diff", "--no-color", "--no-ext-diff", from, to)
+	defer cancel()
+	cmd.Stdout = f
+	if err := r.run(cmd); err != nil {
+		return fmt.Errorf("failed to diff %s..%s: %w", from, to, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package git
+
+import (
+	"context"
+	"fmt"
+)
+
+// CatObject returns the pretty-printed content of an object, as shown by
+// git cat-file -p. For a commit this is its raw header (tree, parents,
+// author, committer, signature) followed by the message.
+func (r *Repository) CatObject(ctx context.Context, hash string) (string, error) {
+	cmd, cancel := r.gitCommand(ctx, "cat-file", "-p", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	return string(output), nil
+}
+
+// ListTree returns the recursive git ls-tree listing of a commit's tree:
+// one "<mode> <type> <object>\t<path>" line per file
+func (r *Repository) ListTree(ctx context.Context, hash string) (string, error) {
+	cmd, cancel := r.gitCommand(ctx, "ls-tree", "-r", "--end-of-options", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tree of %s: %w", hash, err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,53 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ListReflog returns the commits recorded in the reflog of ref (HEAD when
+// empty), most recent entry first, each with its ReflogSelector set. This
+// includes commits no longer reachable from any branch, such as those
+// dropped by a reset or rebase. A commit visited several times is listed
+// once, under its most recent selector; git skips entries whose commit has
+// already been pruned.
+func (r *Repository) ListReflog(ctx context.Context, ref string) ([]Commit, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	cmd, cancel := r.gitCommand(ctx, "log", "--walk-reflogs", "--format=%gd%x00"+logFormat(false), ref, "--")
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git log -g failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git log -g failed: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var commits []Commit
+	scanner := r.logScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		selector, line, ok := strings.Cut(scanner.Text(), "\x00")
+		if !ok {
+			continue
+		}
+		commit, err := parseCommitLine(line)
+		if err != nil || seen[commit.Hash] {
+			continue
+		}
+		seen[commit.Hash] = true
+		commit.ReflogSelector = selector
+		commits = append(commits, commit)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse reflog output: %w", err)
+	}
+	return commits, nil
+}
@@ -3,11 +3,17 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/andpalmier/repopsy/internal/config"
 )
@@ -17,9 +23,51 @@ type Repository struct {
 	// Path is the absolute path to the repository root
 	Path string
 
-	// BufferSize is the scanner buffer size for git operations
-	// Default: 1MB (set by Open if not specified)
+	// GitDir is the absolute path to the git directory of this checkout.
+	// For a linked worktree it is <main>/.git/worktrees/<name> and holds
+	// the worktree's own HEAD and index.
+	GitDir string
+
+	// CommonDir is the absolute path to the shared git directory holding
+	// objects and refs. It equals GitDir except for linked worktrees.
+	CommonDir string
+
+	// BufferSize is the scanner buffer size for git operations, bounding
+	// the longest line read. Default (0): config.DefaultBufferSize, and
+	// config.MaxLogLineSize for git log lines
 	BufferSize int
+
+	// PreservePerms extracts files with their exact git modes (0644/0755),
+	// independent of the process umask. Default: true (set by Open)
+	PreservePerms bool
+
+	// PreserveSymlinks restores symlinks as symlinks. When false, each
+	// symlink is replaced by a regular file containing its target path,
+	// like git does with core.symlinks=false. Default: true (set by Open)
+	PreserveSymlinks bool
+
+	// RespectExportIgnore leaves out paths marked export-ignore in the
+	// archived tree's .gitattributes, as git archive does by default.
+	// Default: true (set by Open)
+	RespectExportIgnore bool
+
+	// ExternalTar unpacks commit trees with the tar executable; when false,
+	// a built-in tar reader unpacks them.
+	// Default: true when tar is in PATH (set by Open)
+	ExternalTar bool
+
+	// Logger receives debug logs of every git command run. Default: none
+	Logger *slog.Logger
+
+	// Timeout limits each git command, except the commit listing streamed
+	// for a whole extraction. Default: none
+	Timeout time.Duration
+
+	// overrideDir is the git directory used to archive while ignoring
+	// export-ignore, created once by exportIgnoreOverride
+	overrideOnce sync.Once
+	overrideDir  string
+	overrideErr  error
 }
 
 // Open opens and validates a git repository at the given path
@@ -61,28 +109,56 @@ func Open(path string) (*Repository, error) {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	// Verify it's a git repository by checking for .git
-	gitDir := filepath.Join(absPath, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
-		// Could be a bare repository or worktree, try git rev-parse
-		cmd := exec.Command("git", "rev-parse", "--git-dir")
-		cmd.Dir = absPath
-		if _, err := cmd.Output(); err != nil {
-			return nil, fmt.Errorf("not a git repository: %s", absPath)
-		}
+	// Verify it's a git repository and resolve its git directories. This also
+	// covers bare repositories and linked worktrees, where .git is a file
+	// pointing into the main repository's .git/worktrees/<name>
+	cmd := exec.Command("git", "rev-parse", "--git-dir", "--git-common-dir")
+	cmd.Dir = absPath
+	cmd.Env = gitEnv()
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, ErrGitNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotARepository, absPath)
 	}
+	dirs := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(dirs) != 2 {
+		return nil, fmt.Errorf("unexpected rev-parse output for %s", absPath)
+	}
+	gitDir, commonDir := resolveGitPath(absPath, dirs[0]), resolveGitPath(absPath, dirs[1])
 
 	return &Repository{
-		Path:       absPath,
-		BufferSize: config.DefaultBufferSize,
+		Path:             absPath,
+		GitDir:           gitDir,
+		CommonDir:        commonDir,
+		PreservePerms:    true,
+		PreserveSymlinks: true,
+
+		RespectExportIgnore: true,
+		ExternalTar:         TarAvailable(),
 	}, nil
 }
 
+// resolveGitPath makes a path printed by git rev-parse absolute
+func resolveGitPath(base, p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	return filepath.Clean(p)
+}
+
+// IsLinkedWorktree reports whether the repository was opened from a linked
+// worktree created with git worktree add
+func (r *Repository) IsLinkedWorktree() bool {
+	return r.GitDir != "" && r.GitDir != r.CommonDir
+}
+
 // runGitCommand executes a git command and returns trimmed output.
 func (r *Repository) runGitCommand(ctx context.Context, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", args...)
-	cmd.Dir = r.Path
-	output, err := cmd.Output()
+	cmd, cancel := r.gitCommand(ctx, args...)
+	defer cancel()
+	output, err := r.output(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return "", fmt.Errorf("git %s failed: %w", args[0], exitErr)
@@ -92,6 +168,14 @@ func (r *Repository) runGitCommand(ctx context.Context, args ...string) (string,
 	return strings.TrimSpace(string(output)), nil
 }
 
+// IsBare reports whether the repository is a bare repository (no working tree).
+// Git commands run with Dir set to the bare repository path, so no extra
+// --git-dir handling is needed for listing or archiving.
+func (r *Repository) IsBare(ctx context.Context) bool {
+	output, err := r.runGitCommand(ctx, "rev-parse", "--is-bare-repository")
+	return err == nil && output == "true"
+}
+
 // GetBufferSize returns the scanner buffer size, defaulting to 1MB if not set
 func (r *Repository) GetBufferSize() int {
 	if r.BufferSize > 0 {
@@ -122,3 +206,77 @@ func (r *Repository) ListBranches(ctx context.Context) ([]string, error) {
 	}
 	return branches, nil
 }
+
+// HasCommits reports whether any commit is reachable from a ref or HEAD;
+// a freshly initialized repository has none
+func (r *Repository) HasCommits(ctx context.Context) (bool, error) {
+	output, err := r.runGitCommand(ctx, "rev-list", "-n", "1", "--all")
+	if err != nil {
+		return false, fmt.Errorf("failed to look for commits: %w", err)
+	}
+	return output != "", nil
+}
+
+// DetachedHead returns the commit HEAD points to when it is detached, and
+// an empty string when HEAD names a branch
+func (r *Repository) DetachedHead(ctx context.Context) (string, error) {
+	_, err := r.runGitCommand(ctx, "symbolic-ref", "-q", "HEAD")
+	if err == nil {
+		return "", nil
+	}
+	// symbolic-ref -q exits with 1, and no message, when HEAD is detached
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	return r.ResolveRef(ctx, "HEAD")
+}
+
+// CurrentBranch returns the short name of the branch checked out, or HEAD
+// when HEAD is detached
+func (r *Repository) CurrentBranch(ctx context.Context) (string, error) {
+	branch, err := r.runGitCommand(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the current branch: %w", err)
+	}
+	return branch, nil
+}
+
+// OnBranch reports whether a commit is reachable from any local branch
+func (r *Repository) OnBranch(ctx context.Context, hash string) (bool, error) {
+	output, err := r.runGitCommand(ctx, "for-each-ref", "--count=1", "--contains", hash, "--format=%(refname)", "refs/heads/")
+	if err != nil {
+		return false, fmt.Errorf("failed to find branches containing %s: %w", hash, err)
+	}
+	return output != "", nil
+}
+
+// RemoteURL returns the URL of the origin remote, or of the first remote
+// when there is no origin, with any password removed. It returns an empty
+// string for repositories without remotes.
+func (r *Repository) RemoteURL(ctx context.Context) (string, error) {
+	output, err := r.runGitCommand(ctx, "remote")
+	if err != nil {
+		return "", fmt.Errorf("failed to list remotes: %w", err)
+	}
+	remotes := strings.Fields(output)
+	if len(remotes) == 0 {
+		return "", nil
+	}
+	name := remotes[0]
+	if slices.Contains(remotes, "origin") {
+		name = "origin"
+	}
+
+	remoteURL, err := r.runGitCommand(ctx, "remote", "get-url", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote URL: %w", err)
+	}
+	if u, err := url.Parse(remoteURL); err == nil && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.User(u.User.Username())
+			remoteURL = u.String()
+		}
+	}
+	return remoteURL, nil
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version as specified by https://semver.org. Build
+// metadata is dropped since it does not affect precedence.
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+// parseSemver parses a semantic version such as 1.2.3 or v1.2.3-rc.1+build,
+// the v prefix being common in tag names
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, build, hasBuild := strings.Cut(s, "+")
+	if hasBuild && !validIdentifiers(build, false) {
+		return semver{}, false
+	}
+
+	var v semver
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if !validIdentifiers(pre, true) {
+			return semver{}, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	numbers := []*uint64{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		if !isNumeric(part) || (len(part) > 1 && part[0] == '0') {
+			return semver{}, false
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return semver{}, false
+		}
+		*numbers[i] = n
+	}
+	return v, true
+}
+
+// validIdentifiers reports whether s is a dot-separated list of non-empty
+// [0-9A-Za-z-] identifiers. Numeric pre-release identifiers must not have
+// leading zeros.
+func validIdentifiers(s string, prerelease bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+		if prerelease && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumeric reports whether s is a non-empty string of ASCII digits
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareSemver orders versions by precedence: numerically by major, minor
+// and patch, a pre-release before its release, and pre-releases by their
+// identifiers, numeric ones numerically and below alphanumeric ones
+func compareSemver(a, b semver) int {
+	if c := cmp.Compare(a.major, b.major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.minor, b.minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.patch, b.patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, y := a.pre[i], b.pre[i]
+		xNum, yNum := isNumeric(x), isNumeric(y)
+		var c int
+		switch {
+		case xNum && yNum:
+			// Without leading zeros, the longer number is the larger one
+			c = cmp.Or(cmp.Compare(len(x), len(y)), strings.Compare(x, y))
+		case xNum:
+			c = -1
+		case yNum:
+			c = 1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a.pre), len(b.pre))
+}
+
+// SortSemver returns the commits whose Tag is a semantic version, ordered
+// from the lowest version to the highest. Other tags are left out; tags of
+// equal precedence keep their order.
+func SortSemver(commits []Commit) []Commit {
+	type versioned struct {
+		commit  Commit
+		version semver
+	}
+	var tagged []versioned
+	for _, c := range commits {
+		if v, ok := parseSemver(c.Tag); ok {
+			tagged = append(tagged, versioned{c, v})
+		}
+	}
+	slices.SortStableFunc(tagged, func(a, b versioned) int {
+		return compareSemver(a.version, b.version)
+	})
+
+	sorted := make([]Commit, len(tagged))
+	for i, t := range tagged {
+		sorted[i] = t.commit
+	}
+	return sorted
+}
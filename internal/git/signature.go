@@ -0,0 +1,84 @@
+// Package git provides functionality for interacting with git repositories.
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SignatureInfo holds details about a commit's or tag's GPG signature
+type SignatureInfo struct {
+	Status string // Verification status as a %G? letter, set only for tags
+	KeyID  string // Key used to sign the commit (%GK)
+	Signer string // Name of the signer (%GS)
+	Raw    string // Raw verification output from gpg (%GG)
+}
+
+// GetSignatureInfo returns the signature details of a commit.
+// Unsigned commits yield an empty SignatureInfo and no error.
+func (r *Repository) GetSignatureInfo(ctx context.Context, hash string) (SignatureInfo, error) {
+	output, err := r.runGitCommand(ctx, "log", "-1", "--format=%GK%x00%GS%x00%GG", hash)
+	if err != nil {
+		return SignatureInfo{}, fmt.Errorf("failed to get signature info: %w", err)
+	}
+
+	parts := strings.SplitN(output, "\x00", 3)
+	if len(parts) < 3 {
+		return SignatureInfo{}, nil
+	}
+
+	return SignatureInfo{
+		KeyID:  strings.TrimSpace(parts[0]),
+		Signer: strings.TrimSpace(parts[1]),
+		Raw:    strings.TrimSpace(parts[2]),
+	}, nil
+}
+
+// parseGPGStatus reads the [GNUPG:] status lines of a verification, as
+// printed by git verify-tag --raw, classifying the signature with the same
+// letters git uses for commits in %G?
+func parseGPGStatus(output string) SignatureInfo {
+	sig := SignatureInfo{Raw: strings.TrimSpace(output)}
+	untrusted := false
+	for _, line := range strings.Split(output, "\n") {
+		rest, ok := strings.CutPrefix(line, "[GNUPG:] ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var status string
+		switch fields[0] {
+		case "GOODSIG":
+			status = "G"
+		case "BADSIG":
+			status = "B"
+		case "EXPSIG":
+			status = "X"
+		case "EXPKEYSIG":
+			status = "Y"
+		case "REVKEYSIG":
+			status = "R"
+		case "ERRSIG":
+			status = "E"
+		case "TRUST_UNDEFINED", "TRUST_NEVER":
+			untrusted = true
+		}
+		if status == "" || len(fields) < 2 {
+			continue
+		}
+		sig.Status, sig.KeyID = status, fields[1]
+		if status != "E" && len(fields) > 2 {
+			sig.Signer = strings.Join(fields[2:], " ")
+		}
+	}
+	// Like git, a good signature by a key of unknown validity is reported as U
+	if sig.Status == "G" && untrusted {
+		sig.Status = "U"
+	}
+	return sig
+}
@@ -0,0 +1,138 @@
+// Package git provides functionality for interacting with git repositories.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileSize holds the size of a single file in a commit's tree
+type FileSize struct {
+	Path string
+	Size int64
+}
+
+// GetCommitTreeSizes returns the blob size of every file in a commit,
+// sorted by size in descending order. Submodule entries are reported as 0 bytes.
+func (r *Repository) GetCommitTreeSizes(ctx context.Context, hash string) ([]FileSize, error) {
+	return r.treeSizes(ctx, hash)
+}
+
+// GetBinaryChangeBytes returns the total blob size of the binary files a
+// commit adds or modifies. Deleted binaries do not count.
+func (r *Repository) GetBinaryChangeBytes(ctx context.Context, hash string) (int64, error) {
+	binaryFiles, err := r.listBinaryFiles(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+	if len(binaryFiles) == 0 {
+		return 0, nil
+	}
+
+	paths := make([]string, 0, len(binaryFiles))
+	for path := range binaryFiles {
+		paths = append(paths, path)
+	}
+	sizes, err := r.treeSizes(ctx, hash, paths...)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, fs := range sizes {
+		if binaryFiles[fs.Path] {
+			total += fs.Size
+		}
+	}
+	return total, nil
+}
+
+// treeSizes lists the blob sizes of a commit's tree, limited to paths
+// when any are given
+func (r *Repository) treeSizes(ctx context.Context, hash string, paths ...string) ([]FileSize, error) {
+	args := []string{"--literal-pathspecs", "ls-tree", "-r", "-l", "-z", hash}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	cmd, cancel := r.gitCommand(ctx, args...)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tree sizes: %w", err)
+	}
+
+	var sizes []FileSize
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	scanner.Split(splitNull)
+	for scanner.Scan() {
+		entry := scanner.Text()
+		if entry == "" {
+			continue
+		}
+
+		// Format: <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) < 4 {
+			continue
+		}
+
+		// Submodules (mode 160000) have no blob and report "-" as size
+		var size int64
+		if fields[0] != "160000" {
+			size, _ = strconv.ParseInt(fields[3], 10, 64)
+		}
+		sizes = append(sizes, FileSize{Path: path, Size: size})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse ls-tree output: %w", err)
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool {
+		if sizes[i].Size != sizes[j].Size {
+			return sizes[i].Size > sizes[j].Size
+		}
+		return sizes[i].Path < sizes[j].Path
+	})
+	return sizes, nil
+}
+
+// WriteSizesFile writes a sizes.txt file listing each path and its size in
+// bytes, separated by a tab
+func WriteSizesFile(destPath string, sizes []FileSize) error {
+	var buf bytes.Buffer
+	for _, fs := range sizes {
+		fmt.Fprintf(&buf, "%s\t%d\n", fs.Path, fs.Size)
+	}
+	if err := os.WriteFile(filepath.Join(destPath, "sizes.txt"), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write sizes file: %w", err)
+	}
+	return nil
+}
+
+// splitNull is a bufio.SplitFunc that splits NUL-terminated records
+func splitNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -5,12 +5,27 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// GetDiffStat returns the git show --stat summary of a commit: one line per
+// changed file followed by the totals line
+func (r *Repository) GetDiffStat(ctx context.Context, hash string) (string, error) {
+	cmd, cancel := r.gitCommand(ctx, "show", "--stat", "--format=", "--no-color", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff stat: %w", err)
+	}
+	return string(output), nil
+}
+
 // CommitStats holds statistics about changes in a commit
 type CommitStats struct {
 	FilesChanged int
@@ -20,14 +35,19 @@ type CommitStats struct {
 
 // GetCommitStats returns statistics about the changes in a commit
 func (r *Repository) GetCommitStats(ctx context.Context, hash string) (CommitStats, error) {
-	cmd := exec.CommandContext(ctx, "git", "show", "--numstat", "--format=", hash)
-	cmd.Dir = r.Path
+	cmd, cancel := r.gitCommand(ctx, "show", "--numstat", "--format=", hash)
+	defer cancel()
 
-	output, err := cmd.Output()
+	output, err := r.output(cmd)
 	if err != nil {
 		return CommitStats{}, fmt.Errorf("failed to get commit stats: %w", err)
 	}
 
+	return parseNumstat(output), nil
+}
+
+// parseNumstat sums the lines of --numstat output
+func parseNumstat(output []byte) CommitStats {
 	stats := CommitStats{}
 	scanner := bufio.NewScanner(bytes.NewReader(output))
 
@@ -56,7 +76,66 @@ func (r *Repository) GetCommitStats(ctx context.Context, hash string) (CommitSta
 		stats.Deletions += deleted
 	}
 
-	return stats, nil
+	return stats
+}
+
+// CommitMetadata holds the full message and change statistics of a commit
+type CommitMetadata struct {
+	FullMessage string
+	Stats       CommitStats
+}
+
+// GetCommitsMetadata returns the same full message and statistics as
+// GetCommitFullMessage and GetCommitStats for many commits at once, from a
+// single git log over exactly those commits. Hashes missing from the result
+// should be fetched one by one.
+func (r *Repository) GetCommitsMetadata(ctx context.Context, hashes []string) (map[string]CommitMetadata, error) {
+	// --cc and --root match what git show reports for merges and root commits
+	cmd, cancel := r.gitCommand(ctx, "log", "--no-walk=unsorted", "--stdin",
+		"--cc", "--root", "--numstat", "--format=%x01%H%x00%B%x00")
+	defer cancel()
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commits metadata: %w", err)
+	}
+
+	metadata := make(map[string]CommitMetadata, len(hashes))
+	for _, record := range bytes.Split(output, []byte{0x01})[1:] {
+		// A message containing the record separator would split a record
+		fields := bytes.SplitN(record, []byte{0}, 3)
+		if len(fields) != 3 || !slices.Contains(hashes, string(fields[0])) {
+			return nil, fmt.Errorf("failed to parse commits metadata: unexpected record %q", record)
+		}
+		metadata[string(fields[0])] = CommitMetadata{
+			FullMessage: strings.TrimSpace(string(fields[1])),
+			Stats:       parseNumstat(fields[2]),
+		}
+	}
+	return metadata, nil
+}
+
+// TreeMetrics holds structural metrics about a commit's tree
+type TreeMetrics struct {
+	FileCount int // Total number of files in the tree
+	MaxDepth  int // Maximum directory depth (files at the root have depth 0)
+}
+
+// GetTreeMetrics returns the file count and maximum directory depth of a commit's tree
+func (r *Repository) GetTreeMetrics(ctx context.Context, hash string) (TreeMetrics, error) {
+	files, err := r.listFiles(ctx, hash)
+	if err != nil {
+		return TreeMetrics{}, fmt.Errorf("failed to get tree metrics: %w", err)
+	}
+
+	metrics := TreeMetrics{FileCount: len(files)}
+	for _, file := range files {
+		if depth := strings.Count(file, "/"); depth > metrics.MaxDepth {
+			metrics.MaxDepth = depth
+		}
+	}
+	return metrics, nil
 }
 
 // GetCommitFullMessage retrieves the full commit message
@@ -76,11 +155,87 @@ func (r *Repository) GetCommitParents(ctx context.Context, hash string) ([]strin
 	return strings.Fields(parentStr), nil
 }
 
-// GetFileDiff returns the diff for a specific commit
-func (r *Repository) GetFileDiff(ctx context.Context, hash string) (string, error) {
-	output, err := r.runGitCommand(ctx, "diff-tree", "-p", "--root", hash)
+// GetFileDiff returns the patch of a commit against its first parent,
+// reading at most maxLines lines of git's output (0 or less = no limit)
+// and reporting whether more were left out. The output is streamed, so a huge
+// diff costs no more memory than the lines kept.
+func (r *Repository) GetFileDiff(ctx context.Context, hash string, maxLines int) (diff string, truncated bool, err error) {
+	cmd, cancel := r.gitCommand(ctx, "diff-tree", "-p", "--root", "--no-commit-id", "--no-color", "--no-ext-diff", hash)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return "", false, fmt.Errorf("git diff-tree failed: %w", err)
+	}
+
+	var b strings.Builder
+	var lines int
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	for scanner.Scan() {
+		if maxLines > 0 && lines == maxLines {
+			truncated = true
+			break
+		}
+		b.WriteString(scanner.Text())
+		b.WriteByte('\n')
+		lines++
+	}
+	// A line longer than the buffer ends the diff like the line cap does
+	scanErr := scanner.Err()
+	if errors.Is(scanErr, bufio.ErrTooLong) {
+		truncated, scanErr = true, nil
+	}
+
+	// Stop git once the cap is reached so Wait does not block on it
+	if truncated || scanErr != nil {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	r.logCommand(cmd, start, waitErr)
+
+	switch {
+	case scanErr != nil:
+		return "", false, fmt.Errorf("failed to read diff of %s: %w", hash, scanErr)
+	case waitErr != nil && !truncated:
+		return "", false, fmt.Errorf("failed to diff %s: %s", hash, commandError(&stderr, r.timeoutError(start, waitErr)))
+	}
+	return b.String(), truncated, nil
+}
+
+// Empty tree object IDs for SHA-1 and SHA-256 repositories
+const (
+	emptyTreeSHA1   = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	emptyTreeSHA256 = "6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321"
+)
+
+// IsEmptyCommit reports whether a commit's tree is identical to its first
+// parent's tree, or is the empty tree for a root commit
+func (r *Repository) IsEmptyCommit(ctx context.Context, hash string) (bool, error) {
+	output, err := r.runGitCommand(ctx, "log", "-1", "--format=%T %P", hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit tree: %w", err)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return false, fmt.Errorf("failed to get commit tree: no output for %s", hash)
+	}
+	tree := fields[0]
+	if len(fields) == 1 {
+		return tree == emptyTreeSHA1 || tree == emptyTreeSHA256, nil
+	}
+
+	parentTree, err := r.runGitCommand(ctx, "rev-parse", fields[1]+"^{tree}")
 	if err != nil {
-		return "", err
+		return false, fmt.Errorf("failed to get parent tree: %w", err)
 	}
-	return output, nil
+	return tree == parentTree, nil
 }
@@ -0,0 +1,172 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Submodule is a gitlink entry (mode 160000) of a commit's tree
+type Submodule struct {
+	Name   string // Name in .gitmodules, defaulting to Path
+	Path   string // Slash-separated path in the superproject
+	Commit string // Commit of the submodule recorded by the gitlink
+	URL    string // URL in .gitmodules, if any
+}
+
+// ListSubmodules returns the submodules referenced by a commit's tree
+func (r *Repository) ListSubmodules(ctx context.Context, hash string) ([]Submodule, error) {
+	cmd, cancel := r.gitCommand(ctx, "ls-tree", "-r", "-z", hash)
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submodules: %w", err)
+	}
+
+	var submodules []Submodule
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	scanner.Split(splitNull)
+	for scanner.Scan() {
+		// Format: <mode> SP <type> SP <object> TAB <path>
+		meta, p, ok := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) < 3 || fields[0] != "160000" {
+			continue
+		}
+		submodules = append(submodules, Submodule{Name: p, Path: p, Commit: fields[2]})
+	}
+	if len(submodules) == 0 {
+		return nil, nil
+	}
+
+	// Names and URLs come from the commit's own .gitmodules, when present
+	names, urls := r.readGitmodules(ctx, hash)
+	for i, sm := range submodules {
+		if name, ok := names[sm.Path]; ok {
+			submodules[i].Name = name
+			submodules[i].URL = urls[name]
+		}
+	}
+	return submodules, nil
+}
+
+// readGitmodules returns the submodule names by path and URLs by name
+// recorded in a commit's .gitmodules
+func (r *Repository) readGitmodules(ctx context.Context, hash string) (names, urls map[string]string) {
+	names, urls = make(map[string]string), make(map[string]string)
+	output, err := r.runGitCommand(ctx, "config", "--blob", hash+":.gitmodules", "--get-regexp", `^submodule\.`)
+	if err != nil {
+		return names, urls
+	}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		key = strings.TrimPrefix(key, "submodule.")
+		if name, ok := strings.CutSuffix(key, ".path"); ok {
+			names[value] = name
+		} else if name, ok := strings.CutSuffix(key, ".url"); ok {
+			urls[name] = value
+		}
+	}
+	return names, urls
+}
+
+// ExtractSubmodules extracts the tree of each submodule of a commit into
+// destPath/<submodule path>, recursing into nested submodules. Submodule
+// commits are looked up in the superproject's .git/modules, in a checked
+// out submodule and in a local submodule URL. It returns the paths of the
+// submodules whose commit is not available locally.
+func (r *Repository) ExtractSubmodules(ctx context.Context, hash, destPath string) ([]string, error) {
+	submodules, err := r.ListSubmodules(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []string
+	for _, sm := range submodules {
+		sub := r.openSubmodule(ctx, sm)
+		if sub == nil {
+			unresolved = append(unresolved, sm.Path)
+			continue
+		}
+
+		dest := filepath.Join(destPath, filepath.FromSlash(sm.Path))
+		err := sub.ExtractCommit(ctx, sm.Commit, dest)
+		var nested []string
+		if err == nil {
+			nested, err = sub.ExtractSubmodules(ctx, sm.Commit, dest)
+		}
+		_ = sub.Close()
+		if err != nil {
+			return unresolved, fmt.Errorf("failed to extract submodule %s: %w", sm.Path, err)
+		}
+		for _, p := range nested {
+			unresolved = append(unresolved, path.Join(sm.Path, p))
+		}
+	}
+	return unresolved, nil
+}
+
+// openSubmodule opens the first local repository holding the submodule's
+// commit, or returns nil when there is none
+func (r *Repository) openSubmodule(ctx context.Context, sm Submodule) *Repository {
+	candidates := []string{filepath.Join(r.CommonDir, "modules", filepath.FromSlash(sm.Name))}
+	if !r.IsBare(ctx) {
+		candidates = append(candidates, filepath.Join(r.Path, filepath.FromSlash(sm.Path)))
+	}
+	if dir := localURL(r.Path, sm.URL); dir != "" {
+		candidates = append(candidates, dir)
+	}
+
+	for _, dir := range candidates {
+		// Open would otherwise find the enclosing repository of an
+		// uninitialized submodule directory
+		if !isRepoRoot(dir) {
+			continue
+		}
+		sub, err := Open(dir)
+		if err != nil {
+			continue
+		}
+		if _, err := sub.runGitCommand(ctx, "cat-file", "-e", sm.Commit+"^{commit}"); err != nil {
+			continue
+		}
+		sub.BufferSize = r.BufferSize
+		sub.PreservePerms = r.PreservePerms
+		sub.PreserveSymlinks = r.PreserveSymlinks
+		sub.RespectExportIgnore = r.RespectExportIgnore
+		sub.Logger = r.Logger
+		return sub
+	}
+	return nil
+}
+
+// isRepoRoot reports whether dir is a working tree root or a git directory
+func isRepoRoot(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	_, headErr := os.Stat(filepath.Join(dir, "HEAD"))
+	_, objectsErr := os.Stat(filepath.Join(dir, "objects"))
+	return headErr == nil && objectsErr == nil
+}
+
+// localURL returns the directory a submodule URL points to when it is a
+// local path, resolving relative URLs against the superproject at base
+func localURL(base, url string) string {
+	switch {
+	case strings.HasPrefix(url, "file://"):
+		return strings.TrimPrefix(url, "file://")
+	case filepath.IsAbs(url):
+		return url
+	case strings.HasPrefix(url, "./"), strings.HasPrefix(url, "../"):
+		return filepath.Join(base, filepath.FromSlash(url))
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ListTags returns the commit of every tag matching pattern (all tags when
+// empty), in tag name order, each with its Tag set. Annotated tags are
+// peeled to their commit; tags of trees or blobs are left out. A commit
+// carrying several tags is listed once per tag.
+func (r *Repository) ListTags(ctx context.Context, pattern string) ([]Commit, error) {
+	output, err := r.runGitCommand(ctx, "for-each-ref",
+		"--format=%(refname:strip=2)%00%(objecttype)%00%(objectname)%00%(*objecttype)%00%(*objectname)",
+		"refs/tags/"+pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	type tag struct{ name, hash string }
+	var tags []tag
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		hash := fields[2]
+		if fields[1] != "commit" {
+			if fields[3] != "commit" {
+				continue
+			}
+			hash = fields[4]
+		}
+		tags = append(tags, tag{name: fields[0], hash: hash})
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	commits, err := r.lookupCommits(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	tagged := make([]Commit, 0, len(tags))
+	for _, t := range tags {
+		commit, ok := commits[t.hash]
+		if !ok {
+			return nil, fmt.Errorf("failed to read commit %s of tag %s", t.hash, t.name)
+		}
+		commit.Tag = t.name
+		tagged = append(tagged, commit)
+	}
+	return tagged, nil
+}
+
+// GetTagSignature returns the signature details of a tag, verified with git
+// verify-tag (git tag -v). Lightweight and unsigned tags yield Status N;
+// signatures that cannot be checked, as when gpg is missing, yield Status E.
+func (r *Repository) GetTagSignature(ctx context.Context, tag string) (SignatureInfo, error) {
+	ref := "refs/tags/" + tag
+	signature, err := r.runGitCommand(ctx, "for-each-ref", "--format=%(contents:signature)", ref)
+	if err != nil {
+		return SignatureInfo{}, fmt.Errorf("failed to read signature of tag %s: %w", tag, err)
+	}
+	if signature == "" {
+		return SignatureInfo{Status: "N"}, nil
+	}
+
+	cmd, cancel := r.gitCommand(ctx, "verify-tag", "--raw", ref)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// verify-tag exits non-zero on bad or unverifiable signatures, which its
+	// status lines describe; a command killed by a cancellation or by
+	// r.Timeout is an error
+	if err := r.run(cmd); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() < 0 || ctx.Err() != nil {
+			return SignatureInfo{}, fmt.Errorf("failed to verify tag %s: %w", tag, err)
+		}
+	}
+
+	sig := parseGPGStatus(stderr.String())
+	if sig.Status == "" {
+		sig.Status = "E"
+	}
+	return sig, nil
+}
+
+// lookupCommits reads the given commits with a single git call, keyed by hash
+func (r *Repository) lookupCommits(ctx context.Context, hashes []string) (map[string]Commit, error) {
+	cmd, cancel := r.gitCommand(ctx, "log", "--no-walk=unsorted", "--stdin", "--format="+logFormat(false))
+	defer cancel()
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commits: %w", err)
+	}
+
+	commits := make(map[string]Commit, len(hashes))
+	scanner := r.logScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if commit, err := parseCommitLine(scanner.Text()); err == nil {
+			commits[commit.Hash] = commit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, r.logScanError(err)
+	}
+	return commits, nil
+}
@@ -0,0 +1,51 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// trailerLine matches a "Token: value" trailer line
+var trailerLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*)\s*:\s*(.*)$`)
+
+// ParseTrailers returns the trailers (Co-authored-by, Signed-off-by, ...)
+// of a commit message, keyed by token in the order they appear. Like git
+// interpret-trailers, it reads the final paragraph, which must be made
+// entirely of trailers; indented continuation lines are joined to the
+// trailer above them. It runs no git process, so it is cheap per commit.
+func ParseTrailers(message string) map[string][]string {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		// The subject alone is never a trailer block
+		return nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		switch {
+		case trailerLine.MatchString(line):
+			lines = append(lines, line)
+		case len(lines) > 0 && strings.TrimLeft(line, " \t") != line:
+			lines[len(lines)-1] += " " + strings.TrimSpace(line)
+		default:
+			return nil
+		}
+	}
+	return collectTrailers(lines)
+}
+
+// collectTrailers groups "Token: value" lines by token
+func collectTrailers(lines []string) map[string][]string {
+	var trailers map[string][]string
+	for _, line := range lines {
+		m := trailerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if trailers == nil {
+			trailers = make(map[string][]string)
+		}
+		trailers[m[1]] = append(trailers[m[1]], strings.TrimSpace(m[2]))
+	}
+	return trailers
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Trust classifications recorded in Commit.Trust by an allowed-keys check
+const (
+	TrustTrusted  = "TRUSTED"
+	TrustUnsigned = "UNSIGNED"
+)
+
+// minKeyIDLength is the length of a long key ID, the shortest accepted in
+// an allowlist: short 8-character IDs are easily forged with a key of the
+// same suffix
+const minKeyIDLength = 16
+
+// KeyAllowlist holds the GPG key IDs or fingerprints accepted as signers
+type KeyAllowlist []string
+
+// LoadKeyAllowlist reads an allowed-keys file with one long key ID or
+// fingerprint per line. Blank lines and lines starting with # are ignored.
+func LoadKeyAllowlist(path string) (KeyAllowlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open allowed keys file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	allowlist := KeyAllowlist{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Fingerprints are often written in space-separated groups
+		key := strings.ToUpper(strings.ReplaceAll(line, " ", ""))
+		if len(key) < minKeyIDLength || strings.Trim(key, "0123456789ABCDEF") != "" {
+			return nil, fmt.Errorf("invalid key %q in allowed keys file: use a 16-character key ID or a full fingerprint", line)
+		}
+		allowlist = append(allowlist, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read allowed keys file: %w", err)
+	}
+	return allowlist, nil
+}
+
+// Allows reports whether the signing keyID is in the allowlist: equal to
+// an allowed key, or the long key ID ending an allowed fingerprint
+func (a KeyAllowlist) Allows(keyID string) bool {
+	keyID = strings.ToUpper(keyID)
+	if len(keyID) < minKeyIDLength {
+		return false
+	}
+	for _, allowed := range a {
+		if strings.HasSuffix(allowed, keyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify returns the trust classification of a commit whose signature
+// info has been populated
+func (a KeyAllowlist) Classify(c Commit) string {
+	return a.classify(c.GPGSignature, c.GPGKeyID)
+}
+
+// ClassifyTag returns the trust classification of a tag signature, as
+// returned by GetTagSignature
+func (a KeyAllowlist) ClassifyTag(sig SignatureInfo) string {
+	return a.classify(sig.Status, sig.KeyID)
+}
+
+// classify returns the trust classification of a signature with the %G?
+// status and key ID
+func (a KeyAllowlist) classify(status, keyID string) string {
+	switch {
+	case status == "" || status == "N":
+		return TrustUnsigned
+	case status == "B":
+		return "UNTRUSTED (bad signature)"
+	case status == "R":
+		return "UNTRUSTED (revoked key)"
+	case status == "E":
+		return "UNTRUSTED (signature cannot be verified)"
+	case keyID == "":
+		return "UNTRUSTED (unknown key)"
+	case !a.Allows(keyID):
+		return fmt.Sprintf("UNTRUSTED (key %s not in allowlist)", keyID)
+	default:
+		return TrustTrusted
+	}
+}
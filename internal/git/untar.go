@@ -0,0 +1,150 @@
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pipeArchiveToDir runs archiveCmd and unpacks the tar archive it writes
+// into destPath with the built-in tar reader, for systems without tar. With
+// exactModes the archived modes are applied without the process umask, as
+// tar -p does; like tar, root always applies them.
+func pipeArchiveToDir(archiveCmd *exec.Cmd, destPath string, exactModes bool) error {
+	pipe, err := archiveCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	var stderr bytes.Buffer
+	archiveCmd.Stderr = &stderr
+
+	if err := archiveCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git archive: %w", err)
+	}
+
+	untarErr := untar(pipe, destPath, exactModes || os.Geteuid() == 0)
+	if untarErr != nil {
+		_ = archiveCmd.Process.Kill()
+	} else {
+		// Drain the padding after the end of the archive so git archive
+		// is not left blocked writing it
+		_, _ = io.Copy(io.Discard, pipe)
+	}
+	archiveErr := archiveCmd.Wait()
+
+	// A git archive failure truncates the stream, so it explains a read
+	// error better than the reader does
+	if archiveErr != nil && (untarErr == nil || stderr.Len() > 0) {
+		return &ExtractionError{Stderr: strings.TrimSpace(stderr.String()), Err: fmt.Errorf("git archive failed: %s", commandError(&stderr, archiveErr))}
+	}
+	if untarErr != nil {
+		return &ExtractionError{Err: fmt.Errorf("tar extraction failed: %w", untarErr)}
+	}
+	return nil
+}
+
+// untar unpacks the directories, regular files and symlinks of a tar archive
+// into dest, which no entry can leave, restoring their modification times
+// like tar does
+func untar(rd io.Reader, dest string, exactModes bool) error {
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dest, err)
+	}
+	defer root.Close()
+
+	type dirTime struct {
+		name    string
+		modTime time.Time
+	}
+	var dirs []dirTime
+
+	tr := tar.NewReader(rd)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			// git archive stores the commit hash in a global pax header
+			continue
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path %q in archive", hdr.Name)
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, mode); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", hdr.Name, err)
+			}
+			if exactModes {
+				if err := root.Chmod(name, mode); err != nil {
+					return fmt.Errorf("failed to set mode of %s: %w", hdr.Name, err)
+				}
+			}
+			dirs = append(dirs, dirTime{name, hdr.ModTime})
+		case tar.TypeReg:
+			if err := writeTarFile(root, name, tr, mode, exactModes); err != nil {
+				return fmt.Errorf("failed to write %s: %w", hdr.Name, err)
+			}
+			if err := root.Chtimes(name, hdr.ModTime, hdr.ModTime); err != nil {
+				return fmt.Errorf("failed to set times of %s: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", hdr.Name, err)
+			}
+			if err := root.Symlink(hdr.Linkname, name); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", hdr.Name, err)
+			}
+		}
+	}
+
+	// Writing into a directory changes its time, so these are set last,
+	// deepest first
+	for _, d := range slices.Backward(dirs) {
+		if err := root.Chtimes(d.name, d.modTime, d.modTime); err != nil {
+			return fmt.Errorf("failed to set times of %s: %w", d.name, err)
+		}
+	}
+	return nil
+}
+
+// writeTarFile writes the content of the current archive entry to name
+// under root with mode
+func writeTarFile(root *os.Root, name string, rd io.Reader, mode fs.FileMode, exactModes bool) error {
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rd); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if exactModes {
+		if err := f.Chmod(mode); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andpalmier/repopsy/internal/config"
+)
+
+// commandOutput runs a command and returns its standard output; tests
+// replace it to simulate other installations
+var commandOutput = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// CheckTools verifies that git is installed and at least
+// config.MinGitVersion
+func CheckTools() error {
+	out, err := commandOutput("git", "--version")
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w: install git %s or later", ErrGitNotFound, config.MinGitVersion)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run git --version: %w", err)
+	}
+	version, err := parseGitVersion(string(out))
+	if err != nil {
+		return err
+	}
+	minimum, _ := parseVersion(config.MinGitVersion)
+	if slices.Compare(version[:], minimum[:]) < 0 {
+		return fmt.Errorf("git %s is too old: repopsy needs git %s or later", formatVersion(version), config.MinGitVersion)
+	}
+	return nil
+}
+
+// TarAvailable reports whether a working tar is in PATH; without it commit
+// trees are unpacked by the built-in tar reader
+func TarAvailable() bool {
+	_, err := commandOutput("tar", "--version")
+	return err == nil
+}
+
+// parseGitVersion parses the output of git --version, such as
+// "git version 2.39.5" or "git version 2.45.1.windows.1"
+func parseGitVersion(out string) ([3]int, error) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return [3]int{}, fmt.Errorf("unexpected git --version output %q", strings.TrimSpace(out))
+	}
+	version, err := parseVersion(fields[2])
+	if err != nil {
+		return [3]int{}, fmt.Errorf("unexpected git --version output %q", strings.TrimSpace(out))
+	}
+	return version, nil
+}
+
+// parseVersion parses the leading major.minor[.patch] numbers of a version,
+// ignoring any suffix such as .windows.1 or -rc0
+func parseVersion(s string) ([3]int, error) {
+	var version [3]int
+	parts := strings.SplitN(s, ".", 4)
+	for i := 0; i < len(parts) && i < 3; i++ {
+		digits := parts[i]
+		if end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			digits = digits[:end]
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			if i < 2 {
+				return [3]int{}, fmt.Errorf("invalid version %q", s)
+			}
+			break
+		}
+		version[i] = n
+	}
+	if len(parts) < 2 {
+		return [3]int{}, fmt.Errorf("invalid version %q", s)
+	}
+	return version, nil
+}
+
+// formatVersion formats a parsed version as major.minor.patch
+func formatVersion(v [3]int) string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
@@ -0,0 +1,161 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
+)
+
+// worktreeInfoTemplate is the COMMIT_INFO.txt written for working tree snapshots
+const worktreeInfoTemplate = `COMMIT INFORMATION
+===========================
+
+Hash:           WORKING
+Base Commit:    %s
+
+NOTE: This is a snapshot of the uncommitted working tree, not a commit.
+It holds the tracked files as they are on disk plus the untracked files
+not excluded by .gitignore.
+
+Captured:       %s
+Files:          %d
+`
+
+// SnapshotWorktree copies the working tree into destPath: tracked files as
+// they are on disk plus untracked files not ignored by .gitignore, together
+// with a COMMIT_INFO.txt marking the snapshot as uncommitted. Files under
+// skipDir, the output directory when it lies inside the working tree, are
+// left out so that the snapshot does not copy the extraction into itself.
+// It returns the number of files copied.
+func (r *Repository) SnapshotWorktree(ctx context.Context, destPath, skipDir string) (int, error) {
+	if r.IsBare(ctx) {
+		return 0, errors.New("bare repositories have no working tree")
+	}
+
+	files, err := r.listWorktreeFiles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if rel, err := filepath.Rel(r.Path, skipDir); skipDir != "" && err == nil && filepath.IsLocal(rel) {
+		prefix := filepath.ToSlash(rel) + "/"
+		files = slices.DeleteFunc(files, func(file string) bool { return strings.HasPrefix(file, prefix) })
+	}
+
+	if err := os.MkdirAll(destPath, config.OutputDirPerms); err != nil {
+		return 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var copied int
+	for _, file := range files {
+		ok, err := copyWorktreeFile(filepath.Join(r.Path, file), filepath.Join(destPath, file))
+		if err != nil {
+			return copied, err
+		}
+		if ok {
+			copied++
+		}
+	}
+
+	if !r.PreserveSymlinks {
+		if err := flattenSymlinks(destPath); err != nil {
+			return copied, err
+		}
+	}
+
+	base, err := r.runGitCommand(ctx, "rev-parse", "--verify", "-q", "HEAD")
+	if err != nil {
+		base = "(no commits)"
+	}
+	info := fmt.Sprintf(worktreeInfoTemplate, base, time.Now().Format(time.RFC3339), copied)
+	if err := os.WriteFile(filepath.Join(destPath, "COMMIT_INFO.txt"), []byte(info), 0o644); err != nil {
+		return copied, fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	return copied, nil
+}
+
+// listWorktreeFiles returns the tracked and the non-ignored untracked files
+// of the working tree
+func (r *Repository) listWorktreeFiles(ctx context.Context) ([]string, error) {
+	cmd, cancel := r.gitCommand(ctx, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	defer cancel()
+
+	output, err := r.output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list working tree files: %w", err)
+	}
+
+	// Tracked files with unmerged stages are listed once per stage
+	seen := make(map[string]bool)
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), r.GetBufferSize())
+	scanner.Split(splitNull)
+	for scanner.Scan() {
+		if file := scanner.Text(); file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// copyWorktreeFile copies a regular file or symlink from src to dst. It
+// reports false for paths deleted from the working tree and for
+// directories, such as submodules.
+func copyWorktreeFile(src, dst string) (bool, error) {
+	info, err := os.Lstat(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+	if info.IsDir() {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), config.OutputDirPerms); err != nil {
+		return false, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return false, fmt.Errorf("failed to read symlink: %w", err)
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return false, fmt.Errorf("failed to create symlink: %w", err)
+		}
+		return true, nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return false, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		return false, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return true, nil
+}
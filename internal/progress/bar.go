@@ -0,0 +1,128 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+
+	"github.com/andpalmier/repopsy/internal/colors"
+)
+
+// barReporter renders progress as a terminal progress bar, or as a spinner
+// when the total is unknown.
+type barReporter struct {
+	mu      sync.Mutex
+	bar     *progressbar.ProgressBar
+	verbose bool
+	writer  io.Writer
+	label   string
+	done    int
+	total   int // 0 when unknown
+	lines   *orderedSink
+	colored bool
+}
+
+// newBarReporter creates a progress bar reporter; a total of 0 or less
+// renders an indeterminate spinner.
+func newBarReporter(total, firstIndex int, verbose bool, writer io.Writer) *barReporter {
+	colored := colors.Enabled()
+	theme := progressbar.Theme{
+		Saucer:        "=",
+		SaucerHead:    ">",
+		SaucerPadding: " ",
+		BarStart:      "[",
+		BarEnd:        "]",
+	}
+	if colored {
+		theme.Saucer, theme.SaucerHead = "[green]=[reset]", "[green]>[reset]"
+	}
+
+	r := &barReporter{
+		verbose: verbose,
+		writer:  writer,
+		total:   max(0, total),
+		lines:   newOrderedSink(firstIndex),
+		colored: colored,
+	}
+
+	barTotal := total
+	if r.total == 0 {
+		// progressbar draws a spinner instead of a bar for an unknown length
+		barTotal = -1
+	}
+	r.bar = progressbar.NewOptions(barTotal,
+		progressbar.OptionSetWriter(writer),
+		progressbar.OptionEnableColorCodes(colored),
+		progressbar.OptionShowBytes(false),
+		progressbar.OptionSetWidth(30),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetDescription(r.description()),
+		progressbar.OptionSetTheme(theme),
+		progressbar.OptionOnCompletion(func() {
+			_, _ = fmt.Fprint(writer, "\n")
+		}),
+	)
+	return r
+}
+
+// Start begins progress tracking.
+func (r *barReporter) Start() {}
+
+// Increment advances the progress by one item.
+func (r *barReporter) Increment(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.label = event.Branch
+	r.done++
+	r.bar.Describe(r.description())
+	if r.verbose {
+		r.printLines(r.lines.add(event.Branch, event.Index, verboseLine(event)))
+	}
+	_ = r.bar.Add(1)
+}
+
+// printLines prints verbose lines above the bar
+func (r *barReporter) printLines(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	_ = r.bar.Clear()
+	for _, line := range lines {
+		_, _ = fmt.Fprint(r.writer, line)
+	}
+}
+
+// description returns the bar description for the current branch label and
+// count, e.g. "Extracting main 42/100 (42%)", or "Extracting main 42" when
+// the total is unknown
+func (r *barReporter) description() string {
+	desc := "Extracting"
+	if r.colored {
+		desc = "[cyan]Extracting[reset]"
+	}
+	if r.label != "" {
+		desc += " " + r.label
+	}
+	if r.total == 0 {
+		return fmt.Sprintf("%s %d", desc, r.done)
+	}
+	return fmt.Sprintf("%s %d/%d (%d%%)", desc, r.done, r.total, r.done*100/r.total)
+}
+
+// Finish completes progress tracking.
+func (r *barReporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.printLines(r.lines.drain())
+	_ = r.bar.Finish()
+}
+
+// Error reports an error during processing.
+func (r *barReporter) Error(message string) {
+	_ = r.bar.Clear()
+	_, _ = fmt.Fprintf(r.writer, "✗ Error: %s\n", message)
+}
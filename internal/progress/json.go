@@ -0,0 +1,73 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// jsonEvent is a single NDJSON progress line
+type jsonEvent struct {
+	Done   int    `json:"done"`
+	Total  int    `json:"total"`
+	Branch string `json:"branch,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Status string `json:"status"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+// jsonReporter emits progress as newline-delimited JSON for tooling.
+type jsonReporter struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	done  int
+	total int
+}
+
+// newJSONReporter creates an NDJSON progress reporter.
+func newJSONReporter(total int, writer io.Writer) *jsonReporter {
+	return &jsonReporter{
+		enc:   json.NewEncoder(writer),
+		total: total,
+	}
+}
+
+// Start begins progress tracking.
+func (r *jsonReporter) Start() {}
+
+// Increment emits one JSON object for the processed item.
+func (r *jsonReporter) Increment(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done++
+	line := jsonEvent{
+		Done:   r.done,
+		Total:  r.total,
+		Branch: event.Branch,
+		Hash:   event.Hash,
+		Status: "ok",
+		Msg:    event.Message,
+	}
+	if event.Err != nil {
+		line.Status = "error"
+		line.Msg = event.Err.Error()
+	}
+	_ = r.enc.Encode(line)
+}
+
+// Finish completes progress tracking.
+func (r *jsonReporter) Finish() {}
+
+// Error emits an error object not tied to a single item.
+func (r *jsonReporter) Error(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_ = r.enc.Encode(jsonEvent{
+		Done:   r.done,
+		Total:  r.total,
+		Status: "error",
+		Msg:    message,
+	})
+}
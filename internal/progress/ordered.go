@@ -0,0 +1,76 @@
+package progress
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// orderedSink buffers the verbose line of each item and releases lines in
+// index order, per branch, as soon as every earlier item of that branch has
+// completed. Workers finish out of order; without it their lines interleave
+// arbitrarily. It is not safe for concurrent use; reporters guard it with
+// their own lock.
+type orderedSink struct {
+	first   int
+	next    map[string]int
+	pending map[string]map[int]string
+}
+
+// newOrderedSink creates an empty ordered sink whose branches start at
+// index first
+func newOrderedSink(first int) *orderedSink {
+	return &orderedSink{
+		first:   first,
+		next:    make(map[string]int),
+		pending: make(map[string]map[int]string),
+	}
+}
+
+// add records the line of the item at index in branch and returns the lines
+// now ready to be printed, in order
+func (s *orderedSink) add(branch string, index int, line string) []string {
+	pending := s.pending[branch]
+	if pending == nil {
+		pending = make(map[int]string)
+		s.pending[branch] = pending
+	}
+	pending[index] = line
+
+	var ready []string
+	for {
+		next, ok := s.next[branch]
+		if !ok {
+			next = s.first
+		}
+		line, ok := pending[next]
+		if !ok {
+			return ready
+		}
+		ready = append(ready, line)
+		delete(pending, next)
+		s.next[branch] = next + 1
+	}
+}
+
+// drain returns the lines still held back because an earlier item never
+// completed, for instance after an interrupt, ordered by branch and index
+func (s *orderedSink) drain() []string {
+	var ready []string
+	for _, branch := range slices.Sorted(maps.Keys(s.pending)) {
+		pending := s.pending[branch]
+		for _, index := range slices.Sorted(maps.Keys(pending)) {
+			ready = append(ready, pending[index])
+		}
+		delete(s.pending, branch)
+	}
+	return ready
+}
+
+// verboseLine formats the verbose line of a processed item
+func verboseLine(event Event) string {
+	if event.Err != nil {
+		return fmt.Sprintf("✗ %s: %v\n", event.Hash, event.Err)
+	}
+	return fmt.Sprintf("✓ %s → %s\n", event.Hash, event.Message)
+}
@@ -0,0 +1,71 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// plainSteps is the number of progress lines printed over a run
+const plainSteps = 10
+
+// plainReporter prints periodic plain-text progress lines, for writers that
+// are not terminals such as log files and CI output.
+type plainReporter struct {
+	mu      sync.Mutex
+	writer  io.Writer
+	verbose bool
+	done    int
+	total   int
+	step    int
+	lines   *orderedSink
+}
+
+// newPlainReporter creates a plain-text progress reporter.
+func newPlainReporter(total, firstIndex int, verbose bool, writer io.Writer) *plainReporter {
+	return &plainReporter{
+		writer:  writer,
+		verbose: verbose,
+		total:   total,
+		step:    max(1, total/plainSteps),
+		lines:   newOrderedSink(firstIndex),
+	}
+}
+
+// Start begins progress tracking.
+func (r *plainReporter) Start() {}
+
+// Increment advances the progress by one item, printing a progress line
+// every tenth of the total.
+func (r *plainReporter) Increment(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done++
+	if r.verbose {
+		for _, line := range r.lines.add(event.Branch, event.Index, verboseLine(event)) {
+			_, _ = fmt.Fprint(r.writer, line)
+		}
+	}
+	if r.done%r.step == 0 || r.done == r.total {
+		_, _ = fmt.Fprintf(r.writer, "Extracted %d/%d\n", r.done, r.total)
+	}
+}
+
+// Finish completes progress tracking.
+func (r *plainReporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, line := range r.lines.drain() {
+		_, _ = fmt.Fprint(r.writer, line)
+	}
+}
+
+// Error reports an error during processing.
+func (r *plainReporter) Error(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, _ = fmt.Fprintf(r.writer, "✗ Error: %s\n", message)
+}
@@ -6,74 +6,98 @@ import (
 	"io"
 	"os"
 
-	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
 )
 
-// Reporter handles progress reporting to the terminal.
-type Reporter struct {
-	bar     *progressbar.ProgressBar
-	verbose bool
-	writer  io.Writer
+// Progress output modes
+const (
+	// ModeBar renders an interactive progress bar on stderr, or periodic
+	// plain-text lines when stderr is not a terminal
+	ModeBar = "bar"
+
+	// ModeJSON emits one JSON object per processed item on stdout
+	ModeJSON = "json"
+)
+
+// Reporter handles progress reporting.
+type Reporter interface {
+	// Start begins progress tracking.
+	Start()
+	// Increment advances the progress by one item.
+	Increment(event Event)
+	// Finish completes progress tracking.
+	Finish()
+	// Error reports an error during processing.
+	Error(message string)
+}
+
+// Event describes the outcome of a single processed item.
+type Event struct {
+	Branch  string // Branch the commit belongs to, used as the progress label
+	Hash    string // Short hash of the processed commit
+	Index   int    // Position of the commit in its branch, orders verbose output
+	Err     error  // Non-nil if processing failed
+	Message string // Human-readable detail (e.g., output folder)
 }
 
 // Config configures the progress reporter.
 type Config struct {
-	Total   int
+	Total   int // Grand total of items across all branches
 	Verbose bool
-	Writer  io.Writer
+	Quiet   bool      // Suppress the progress bar (ModeJSON is unaffected)
+	Mode    string    // ModeBar (default) or ModeJSON
+	Writer  io.Writer // Default: stderr for ModeBar, stdout for ModeJSON
+
+	// FirstIndex is the Event.Index of the first item of each branch, so
+	// that verbose lines are released in order as soon as it completes
+	FirstIndex int
 }
 
-// New creates a new progress reporter.
-func New(cfg Config) *Reporter {
+// New creates a new progress reporter for the configured mode.
+func New(cfg Config) Reporter {
+	if cfg.Mode == ModeJSON {
+		writer := cfg.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		return newJSONReporter(cfg.Total, writer)
+	}
+
+	if cfg.Quiet {
+		return nopReporter{}
+	}
+
 	writer := cfg.Writer
 	if writer == nil {
 		writer = os.Stderr
 	}
-
-	bar := progressbar.NewOptions(cfg.Total,
-		progressbar.OptionSetWriter(writer),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowBytes(false),
-		progressbar.OptionSetWidth(30),
-		progressbar.OptionSetDescription("[cyan]Extracting[reset]"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-		progressbar.OptionOnCompletion(func() {
-			_, _ = fmt.Fprint(writer, "\n")
-		}),
-	)
-
-	return &Reporter{
-		bar:     bar,
-		verbose: cfg.Verbose,
-		writer:  writer,
+	// Bar redraws would garble logs and CI output
+	if !isTerminal(writer) {
+		return newPlainReporter(cfg.Total, cfg.FirstIndex, cfg.Verbose, writer)
 	}
+	return newBarReporter(cfg.Total, cfg.FirstIndex, cfg.Verbose, writer)
 }
 
-// Start begins progress tracking.
-func (r *Reporter) Start() {}
-
-// Increment advances the progress by one item.
-func (r *Reporter) Increment(message string) {
-	if r.verbose && message != "" {
-		_ = r.bar.Clear()
-		_, _ = fmt.Fprintf(r.writer, "%s\n", message)
-	}
-	_ = r.bar.Add(1)
+// isTerminal reports whether w writes to a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	return ok && term.IsTerminal(int(f.Fd()))
 }
 
-// Finish completes progress tracking.
-func (r *Reporter) Finish() {
-	_ = r.bar.Finish()
-}
+// nopReporter discards all progress.
+type nopReporter struct{}
 
-// Error reports an error during processing.
-func (r *Reporter) Error(message string) {
-	_ = r.bar.Clear()
-	_, _ = fmt.Fprintf(r.writer, "✗ Error: %s\n", message)
+func (nopReporter) Start()          {}
+func (nopReporter) Increment(Event) {}
+func (nopReporter) Finish()         {}
+func (nopReporter) Error(string)    {}
+
+// ValidateMode returns an error if mode is not a supported progress mode.
+func ValidateMode(mode string) error {
+	switch mode {
+	case "", ModeBar, ModeJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid progress mode %q (expected %q or %q)", mode, ModeBar, ModeJSON)
+	}
 }
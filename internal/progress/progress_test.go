@@ -0,0 +1,171 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONReporter(t *testing.T) {
+	var buf bytes.Buffer
+	events := []Event{
+		{Hash: "abc1234", Message: "20231205_143022_abc1234"},
+		{Hash: "def5678", Err: errors.New("git archive failed")},
+		{Hash: "0123abc", Message: "20231205_150000_0123abc"},
+	}
+
+	r := New(Config{Total: len(events), Mode: ModeJSON, Writer: &buf})
+	r.Start()
+	for _, ev := range events {
+		r.Increment(ev)
+	}
+	r.Finish()
+
+	var lines []jsonEvent
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line jsonEvent
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != len(events) {
+		t.Fatalf("expected %d lines, got %d", len(events), len(lines))
+	}
+	for i, line := range lines {
+		if line.Done != i+1 || line.Total != len(events) || line.Hash != events[i].Hash {
+			t.Errorf("line %d: unexpected progress %+v", i, line)
+		}
+	}
+	if lines[1].Status != "error" || lines[1].Msg != "git archive failed" {
+		t.Errorf("expected error status for failed item, got %+v", lines[1])
+	}
+	if lines[0].Status != "ok" {
+		t.Errorf("expected ok status, got %+v", lines[0])
+	}
+}
+
+func TestBarReporterNotTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(Config{Total: 20, Verbose: true, Writer: &buf})
+	r.Start()
+	for i := 0; i < 20; i++ {
+		r.Increment(Event{Branch: "main", Hash: "abc1234", Index: i, Message: "20231205_143022_abc1234"})
+	}
+	r.Finish()
+
+	out := buf.String()
+	if strings.Contains(out, "\x1b[") || strings.Contains(out, "\r") {
+		t.Errorf("expected no control sequences, got %q", out)
+	}
+	if !strings.Contains(out, "Extracted 10/20\n") || !strings.HasSuffix(out, "Extracted 20/20\n") {
+		t.Errorf("expected periodic progress lines, got:\n%s", out)
+	}
+	if !strings.Contains(out, "✓ abc1234 → 20231205_143022_abc1234\n") {
+		t.Errorf("expected verbose per-commit lines, got:\n%s", out)
+	}
+}
+
+func TestVerboseOutputOrdered(t *testing.T) {
+	const items = 50
+	var buf bytes.Buffer
+	r := New(Config{Total: items, Verbose: true, Writer: &buf})
+	r.Start()
+
+	// Workers pick items in order but finish them in any order
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				time.Sleep(time.Duration((i*7)%5) * time.Millisecond)
+				r.Increment(Event{Branch: "main", Hash: fmt.Sprintf("c%03d", i), Index: i, Message: "done"})
+			}
+		}()
+	}
+	for i := 0; i < items; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	r.Finish()
+
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "✓ ") {
+			got = append(got, strings.Fields(line)[1])
+		}
+	}
+	if len(got) != items {
+		t.Fatalf("expected %d verbose lines, got %d:\n%s", items, len(got), buf.String())
+	}
+	for i, hash := range got {
+		if want := fmt.Sprintf("c%03d", i); hash != want {
+			t.Fatalf("line %d: expected %s, got %s", i, want, hash)
+		}
+	}
+}
+
+func TestOrderedSinkDrain(t *testing.T) {
+	s := newOrderedSink(0)
+	if ready := s.add("main", 1, "b"); len(ready) != 0 {
+		t.Fatalf("expected index 1 to wait for index 0, got %q", ready)
+	}
+	if ready := s.add("dev", 0, "x"); len(ready) != 1 {
+		t.Fatalf("expected branches to be ordered independently, got %q", ready)
+	}
+	s.add("main", 3, "d")
+	if ready := s.add("main", 0, "a"); strings.Join(ready, "") != "ab" {
+		t.Errorf("expected a and b to be released, got %q", ready)
+	}
+	if rest := s.drain(); strings.Join(rest, "") != "d" {
+		t.Errorf("expected drain to release d, got %q", rest)
+	}
+
+	// A later run continues the numbering of an earlier one
+	s = newOrderedSink(5)
+	if ready := s.add("main", 5, "f"); strings.Join(ready, "") != "f" {
+		t.Errorf("expected the first index to be released at once, got %q", ready)
+	}
+}
+
+func TestBarReporterDescription(t *testing.T) {
+	var buf bytes.Buffer
+	r := newBarReporter(4, 0, false, &buf)
+	r.colored = false
+	r.Increment(Event{Branch: "main", Hash: "abc1234"})
+	if got, want := r.description(), "Extracting main 1/4 (25%)"; got != want {
+		t.Errorf("expected description %q, got %q", want, got)
+	}
+	r.Increment(Event{Branch: "main", Hash: "def5678"})
+	if got, want := r.description(), "Extracting main 2/4 (50%)"; got != want {
+		t.Errorf("expected description %q, got %q", want, got)
+	}
+	if !strings.Contains(buf.String(), "main 2/4 (50%)") {
+		t.Errorf("expected the bar to show the updated description, got %q", buf.String())
+	}
+
+	// An unknown total renders a spinner counting items
+	buf.Reset()
+	r = newBarReporter(0, 0, false, &buf)
+	r.colored = false
+	for range 3 {
+		r.Increment(Event{Branch: "dev", Hash: "abc1234"})
+	}
+	if got, want := r.description(), "Extracting dev 3"; got != want {
+		t.Errorf("expected spinner description %q, got %q", want, got)
+	}
+	if strings.Contains(buf.String(), "%") {
+		t.Errorf("expected a spinner without percentage, got %q", buf.String())
+	}
+}
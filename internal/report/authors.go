@@ -0,0 +1,174 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// Author statistics files written to the output root
+const (
+	AuthorsCSVFileName  = "authors.csv"
+	AuthorsTextFileName = "authors.txt"
+)
+
+// authorsHeader lists the columns written by WriteAuthorsCSV
+var authorsHeader = []string{
+	"author",
+	"author_email",
+	"commits",
+	"insertions",
+	"deletions",
+	"first_commit",
+	"last_commit",
+}
+
+// AuthorStats aggregates the commits of one author
+type AuthorStats struct {
+	Name       string
+	Email      string
+	Commits    int
+	Insertions int
+	Deletions  int
+	First      time.Time // Earliest author date
+	Last       time.Time // Latest author date
+}
+
+// Authors aggregates successful results per author, busiest first.
+// Authors are told apart by name and case-insensitive email as recorded in
+// the commits, so identities merged by .mailmap (with --mailmap) count as
+// one. Commits reachable from several branches are counted once.
+func Authors(results []extractor.Result) []AuthorStats {
+	seen := make(map[string]bool)
+	byIdentity := make(map[string]*AuthorStats)
+	for _, r := range results {
+		if r.Error != nil || seen[r.Commit.Hash] {
+			continue
+		}
+		seen[r.Commit.Hash] = true
+
+		c := r.Commit
+		key := c.Author + " <" + strings.ToLower(c.AuthorEmail) + ">"
+		a, ok := byIdentity[key]
+		if !ok {
+			a = &AuthorStats{Name: c.Author, Email: c.AuthorEmail, First: c.AuthorDate, Last: c.AuthorDate}
+			byIdentity[key] = a
+		}
+		a.Commits++
+		a.Insertions += c.Insertions
+		a.Deletions += c.Deletions
+		if c.AuthorDate.Before(a.First) {
+			a.First = c.AuthorDate
+		}
+		if c.AuthorDate.After(a.Last) {
+			a.Last = c.AuthorDate
+		}
+	}
+
+	authors := make([]AuthorStats, 0, len(byIdentity))
+	for _, a := range byIdentity {
+		authors = append(authors, *a)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Commits != authors[j].Commits {
+			return authors[i].Commits > authors[j].Commits
+		}
+		if authors[i].Name != authors[j].Name {
+			return authors[i].Name < authors[j].Name
+		}
+		return authors[i].Email < authors[j].Email
+	})
+	return authors
+}
+
+// WriteAuthorsCSV writes one row per author, preceded by a header row
+func WriteAuthorsCSV(w io.Writer, authors []AuthorStats) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(authorsHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, a := range authors {
+		record := []string{
+			a.Name,
+			a.Email,
+			strconv.Itoa(a.Commits),
+			strconv.Itoa(a.Insertions),
+			strconv.Itoa(a.Deletions),
+			a.First.Format(time.RFC3339),
+			a.Last.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteAuthorsTable writes an aligned plain-text table of the authors
+func WriteAuthorsTable(w io.Writer, authors []AuthorStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AUTHOR\tCOMMITS\tINSERTIONS\tDELETIONS\tFIRST\tLAST")
+	for _, a := range authors {
+		fmt.Fprintf(tw, "%s <%s>\t%d\t%d\t%d\t%s\t%s\n",
+			a.Name,
+			a.Email,
+			a.Commits,
+			a.Insertions,
+			a.Deletions,
+			a.First.Format(displayDateFormat),
+			a.Last.Format(displayDateFormat),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
+
+// WriteAuthorsFiles writes authors.csv and authors.txt to outDir
+func WriteAuthorsFiles(outDir string, authors []AuthorStats) error {
+	files := []struct {
+		name  string
+		write func(io.Writer, []AuthorStats) error
+	}{
+		{AuthorsCSVFileName, WriteAuthorsCSV},
+		{AuthorsTextFileName, WriteAuthorsTable},
+	}
+	for _, file := range files {
+		if err := writeAuthorsFile(filepath.Join(outDir, file.name), authors, file.write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAuthorsFile creates the file at path and fills it with write
+func writeAuthorsFile(path string, authors []AuthorStats, write func(io.Writer, []AuthorStats) error) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close %s: %w", filepath.Base(path), closeErr)
+		}
+	}()
+
+	return write(f, authors)
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// ChurnFileName is the churn series written to the output root
+const ChurnFileName = "churn.csv"
+
+// churnHeader lists the columns written by WriteChurnCSV
+var churnHeader = []string{
+	"date",
+	"commits",
+	"files_changed",
+	"insertions",
+	"deletions",
+	"net",
+	"cumulative_files_changed",
+	"cumulative_insertions",
+	"cumulative_deletions",
+	"cumulative_net",
+}
+
+// ChurnDay aggregates the line changes of all commits authored on one day
+type ChurnDay struct {
+	Date         string // Author date, YYYY-MM-DD
+	Commits      int
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+// Net returns the net number of lines added on the day
+func (d ChurnDay) Net() int {
+	return d.Insertions - d.Deletions
+}
+
+// Lines returns the total number of lines touched on the day
+func (d ChurnDay) Lines() int {
+	return d.Insertions + d.Deletions
+}
+
+// Churn aggregates successful results into a chronological daily series.
+// Commits reachable from several branches are counted once.
+func Churn(results []extractor.Result) []ChurnDay {
+	seen := make(map[string]bool)
+	byDate := make(map[string]*ChurnDay)
+	for _, r := range results {
+		if r.Error != nil || seen[r.Commit.Hash] {
+			continue
+		}
+		seen[r.Commit.Hash] = true
+
+		date := r.Commit.AuthorDate.Format("2006-01-02")
+		day, ok := byDate[date]
+		if !ok {
+			day = &ChurnDay{Date: date}
+			byDate[date] = day
+		}
+		day.Commits++
+		day.FilesChanged += r.Commit.FilesChanged
+		day.Insertions += r.Commit.Insertions
+		day.Deletions += r.Commit.Deletions
+	}
+
+	days := make([]ChurnDay, 0, len(byDate))
+	for _, day := range byDate {
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// BusiestDay returns the day with the most lines touched
+func BusiestDay(days []ChurnDay) (ChurnDay, bool) {
+	var busiest ChurnDay
+	for _, day := range days {
+		if day.Lines() > busiest.Lines() {
+			busiest = day
+		}
+	}
+	return busiest, busiest.Date != ""
+}
+
+// WriteChurnCSV writes one row per day with daily and cumulative totals
+func WriteChurnCSV(w io.Writer, days []ChurnDay) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(churnHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	var total ChurnDay
+	for _, day := range days {
+		total.FilesChanged += day.FilesChanged
+		total.Insertions += day.Insertions
+		total.Deletions += day.Deletions
+
+		record := []string{
+			day.Date,
+			strconv.Itoa(day.Commits),
+			strconv.Itoa(day.FilesChanged),
+			strconv.Itoa(day.Insertions),
+			strconv.Itoa(day.Deletions),
+			strconv.Itoa(day.Net()),
+			strconv.Itoa(total.FilesChanged),
+			strconv.Itoa(total.Insertions),
+			strconv.Itoa(total.Deletions),
+			strconv.Itoa(total.Net()),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteChurnFile writes the churn series to churn.csv in outDir
+func WriteChurnFile(outDir string, days []ChurnDay) (err error) {
+	f, err := os.Create(filepath.Join(outDir, ChurnFileName))
+	if err != nil {
+		return fmt.Errorf("failed to create churn file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close churn file: %w", closeErr)
+		}
+	}()
+
+	return WriteChurnCSV(f, days)
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// csvHeader lists the columns written by WriteCSV
+var csvHeader = []string{
+	"index",
+	"branch",
+	"short_hash",
+	"hash",
+	"author",
+	"author_email",
+	"author_date",
+	"committer",
+	"commit_date",
+	"subject",
+	"files_changed",
+	"insertions",
+	"deletions",
+	"folder",
+	"error",
+}
+
+// WriteCSV writes one row per extracted commit to w, preceded by a header row
+func WriteCSV(w io.Writer, results []extractor.Result) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, r := range sortedResults(results) {
+		var errMsg string
+		if r.Error != nil {
+			errMsg = r.Error.Error()
+		}
+
+		record := []string{
+			strconv.Itoa(r.Index),
+			r.Branch,
+			r.Commit.ShortHash,
+			r.Commit.Hash,
+			r.Commit.Author,
+			r.Commit.AuthorEmail,
+			r.Commit.AuthorDate.Format(time.RFC3339),
+			r.Commit.Committer,
+			r.Commit.CommitDate.Format(time.RFC3339),
+			r.Commit.Subject,
+			strconv.Itoa(r.Commit.FilesChanged),
+			strconv.Itoa(r.Commit.Insertions),
+			strconv.Itoa(r.Commit.Deletions),
+			r.OutputPath,
+			errMsg,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteCSVFile writes the CSV summary to the file at path
+func WriteCSVFile(path string, results []extractor.Result) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close CSV file: %w", closeErr)
+		}
+	}()
+
+	return WriteCSV(f, results)
+}
@@ -0,0 +1,99 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// DuplicateTreesFileName is the duplicate tree report written to the output root
+const DuplicateTreesFileName = "duplicate_trees.txt"
+
+// TreeGroup is a tree shared by several distinct commits, as happens when
+// history is rewritten or a change is reverted
+type TreeGroup struct {
+	Tree    string
+	Commits []extractor.Result // First result of each commit
+}
+
+// DuplicateTrees groups the successfully extracted commits by tree, given
+// trees mapping commit hashes to tree hashes, and returns the groups of
+// two or more commits in the order of their first commit
+func DuplicateTrees(results []extractor.Result, trees map[string]string) []TreeGroup {
+	seen := make(map[string]bool)
+	byTree := make(map[string]*TreeGroup)
+	var order []string
+	for _, r := range sortedResults(results) {
+		tree, ok := trees[r.Commit.Hash]
+		if r.Error != nil || r.Skipped || !ok || seen[r.Commit.Hash] {
+			continue
+		}
+		seen[r.Commit.Hash] = true
+
+		group := byTree[tree]
+		if group == nil {
+			group = &TreeGroup{Tree: tree}
+			byTree[tree] = group
+			order = append(order, tree)
+		}
+		group.Commits = append(group.Commits, r)
+	}
+
+	var groups []TreeGroup
+	for _, tree := range order {
+		if group := byTree[tree]; len(group.Commits) > 1 {
+			groups = append(groups, *group)
+		}
+	}
+	return groups
+}
+
+// WriteDuplicateTrees writes each group as its tree hash followed by one
+// line per commit with its folder relative to outDir
+func WriteDuplicateTrees(w io.Writer, outDir string, groups []TreeGroup) error {
+	if len(groups) == 0 {
+		if _, err := fmt.Fprintln(w, "No distinct commits share a tree."); err != nil {
+			return fmt.Errorf("failed to write duplicate trees: %w", err)
+		}
+		return nil
+	}
+
+	for i, group := range groups {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return fmt.Errorf("failed to write duplicate trees: %w", err)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "tree %s (%d commits)\n", group.Tree, len(group.Commits)); err != nil {
+			return fmt.Errorf("failed to write duplicate trees: %w", err)
+		}
+		for _, r := range group.Commits {
+			folder := r.OutputPath
+			if rel, err := filepath.Rel(outDir, r.OutputPath); err == nil {
+				folder = rel
+			}
+			if _, err := fmt.Fprintf(w, "  %s %s  %s\n", r.Commit.ShortHash, folder, r.Commit.Subject); err != nil {
+				return fmt.Errorf("failed to write duplicate trees: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteDuplicateTreesFile writes the duplicate tree report to
+// duplicate_trees.txt in outDir
+func WriteDuplicateTreesFile(outDir string, groups []TreeGroup) (err error) {
+	f, err := os.Create(filepath.Join(outDir, DuplicateTreesFileName))
+	if err != nil {
+		return fmt.Errorf("failed to create duplicate trees file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close duplicate trees file: %w", closeErr)
+		}
+	}()
+	return WriteDuplicateTrees(f, outDir, groups)
+}
@@ -0,0 +1,242 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// graphNode is one distinct commit of the graph with the folders it went to
+type graphNode struct {
+	commit  git.Commit
+	folders []string
+	order   int
+}
+
+// WriteGraph writes an ASCII commit graph of results to w, newest first,
+// in the style of git log --graph --oneline. Each line is annotated with
+// the folder the commit was extracted to, relative to outDir. Edges only
+// connect commits present in results.
+func WriteGraph(w io.Writer, outDir string, results []extractor.Result) error {
+	nodes := graphNodes(outDir, results)
+
+	var lanes []string
+	for _, n := range topoOrder(nodes) {
+		c := n.commit
+
+		// Lanes waiting for the same commit join its leftmost lane, moving
+		// one column per line
+		col := slices.Index(lanes, c.Hash)
+		for j := lastLane(lanes, c.Hash); j > col; j = lastLane(lanes, c.Hash) {
+			straight := slices.Clone(lanes)
+			edge := byte('/')
+			switch lanes[j-1] {
+			case c.Hash:
+				lanes[j] = ""
+				straight[j] = ""
+			case "":
+				lanes[j-1], lanes[j] = c.Hash, ""
+				straight[j-1], straight[j] = "", ""
+			default:
+				lanes[j-1], lanes[j] = c.Hash, lanes[j-1]
+				straight[j-1], straight[j] = "", ""
+				edge = 'X'
+			}
+			if err := writeGraphLine(w, straight, map[int]byte{2*j - 1: edge}); err != nil {
+				return err
+			}
+		}
+		lanes = trimLanes(lanes)
+
+		if col < 0 {
+			if col = slices.Index(lanes, ""); col < 0 {
+				col = len(lanes)
+				lanes = append(lanes, "")
+			}
+			lanes[col] = c.Hash
+		}
+
+		var first string
+		var extra []string
+		for i, p := range c.ParentHashes {
+			switch {
+			case nodes[p] == nil:
+			case i == 0:
+				first = p
+			default:
+				extra = append(extra, p)
+			}
+		}
+
+		label := c.ShortHash + " " + c.Subject
+		if len(n.folders) > 0 {
+			label += " (" + strings.Join(n.folders, ", ") + ")"
+		}
+		// Merges leave room for the lanes they are about to open
+		width := 2*(len(lanes)+len(extra)) - 1
+		if _, err := fmt.Fprintf(w, "%-*s %s\n", width, laneCells(lanes, col), label); err != nil {
+			return fmt.Errorf("failed to write graph: %w", err)
+		}
+
+		lanes[col] = first
+
+		// Further parents open lanes right of the node, pushing the lanes
+		// already there one column to the right
+		for i := len(extra) - 1; i >= 0; i-- {
+			edges := map[int]byte{2*col + 1: '\\'}
+			var straight []string
+			if col+1 < len(lanes) && lanes[col+1] == "" {
+				lanes[col+1] = extra[i]
+				straight = slices.Clone(lanes)
+				straight[col+1] = ""
+			} else {
+				lanes = slices.Insert(lanes, col+1, extra[i])
+				for k := col + 2; k < len(lanes); k++ {
+					if lanes[k] != "" {
+						edges[2*k-1] = '\\'
+					}
+				}
+				straight = lanes[:col+1]
+			}
+			if err := writeGraphLine(w, straight, edges); err != nil {
+				return err
+			}
+		}
+		lanes = trimLanes(lanes)
+	}
+	return nil
+}
+
+// graphNodes collects the distinct commits of results, keyed by hash
+func graphNodes(outDir string, results []extractor.Result) map[string]*graphNode {
+	nodes := make(map[string]*graphNode)
+	for i, r := range sortedResults(results) {
+		n := nodes[r.Commit.Hash]
+		if n == nil {
+			n = &graphNode{commit: r.Commit, order: i}
+			nodes[r.Commit.Hash] = n
+		}
+		switch {
+		case r.Error != nil:
+			n.folders = append(n.folders, "failed")
+		case r.OutputPath != "":
+			folder := r.OutputPath
+			if rel, err := filepath.Rel(outDir, r.OutputPath); err == nil {
+				folder = rel
+			}
+			n.folders = append(n.folders, folder)
+		case r.Skipped:
+			n.folders = append(n.folders, "skipped")
+		}
+	}
+	return nodes
+}
+
+// topoOrder orders nodes so that every commit comes before its parents,
+// picking the most recently committed one whenever there is a choice
+func topoOrder(nodes map[string]*graphNode) []*graphNode {
+	children := make(map[string]int)
+	for _, n := range nodes {
+		for _, p := range n.commit.ParentHashes {
+			if nodes[p] != nil {
+				children[p]++
+			}
+		}
+	}
+
+	var ready, ordered []*graphNode
+	for hash, n := range nodes {
+		if children[hash] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		best := 0
+		for i, n := range ready {
+			if newerNode(n, ready[best]) {
+				best = i
+			}
+		}
+		n := ready[best]
+		ready = append(ready[:best], ready[best+1:]...)
+		ordered = append(ordered, n)
+
+		for _, p := range n.commit.ParentHashes {
+			if nodes[p] == nil {
+				continue
+			}
+			if children[p]--; children[p] == 0 {
+				ready = append(ready, nodes[p])
+			}
+		}
+	}
+	return ordered
+}
+
+// newerNode reports whether a should be drawn above b
+func newerNode(a, b *graphNode) bool {
+	if !a.commit.CommitDate.Equal(b.commit.CommitDate) {
+		return a.commit.CommitDate.After(b.commit.CommitDate)
+	}
+	return a.order > b.order
+}
+
+// laneCells draws one cell per lane, marking the node's lane with *
+func laneCells(lanes []string, col int) string {
+	cells := make([]string, len(lanes))
+	for i, lane := range lanes {
+		switch {
+		case i == col:
+			cells[i] = "*"
+		case lane != "":
+			cells[i] = "|"
+		default:
+			cells[i] = " "
+		}
+	}
+	return strings.Join(cells, " ")
+}
+
+// writeGraphLine writes a connector line: the lanes in straight are drawn
+// as | and the edges are placed at their character positions
+func writeGraphLine(w io.Writer, straight []string, edges map[int]byte) error {
+	line := []byte(strings.Repeat(" ", 2*len(straight)))
+	for i, lane := range straight {
+		if lane != "" {
+			line[2*i] = '|'
+		}
+	}
+	for pos, edge := range edges {
+		for len(line) <= pos {
+			line = append(line, ' ')
+		}
+		line[pos] = edge
+	}
+	if _, err := fmt.Fprintln(w, strings.TrimRight(string(line), " ")); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// trimLanes drops the free lanes at the right edge
+func trimLanes(lanes []string) []string {
+	for len(lanes) > 0 && lanes[len(lanes)-1] == "" {
+		lanes = lanes[:len(lanes)-1]
+	}
+	return lanes
+}
+
+// lastLane returns the last lane waiting for hash, or -1
+func lastLane(lanes []string, hash string) int {
+	for i := len(lanes) - 1; i >= 0; i-- {
+		if lanes[i] == hash {
+			return i
+		}
+	}
+	return -1
+}
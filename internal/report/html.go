@@ -0,0 +1,169 @@
+// Package report renders summaries of an extraction run.
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// htmlTemplateStr is the template for the index.html report
+const htmlTemplateStr = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>repopsy report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+td.num { text-align: right; font-family: monospace; }
+tr.failed { background: #fdd; }
+.ins { color: #080; }
+.del { color: #a00; }
+</style>
+</head>
+<body>
+<h1>repopsy report</h1>
+<p>{{.Total}} commits extracted</p>
+{{range .Groups}}
+{{if .Branch}}<h2>{{.Branch}}</h2>{{end}}
+<table>
+<thead>
+<tr><th>Date</th><th>Commit</th><th>Author</th><th>Subject</th><th>Files</th><th>+/-</th></tr>
+</thead>
+<tbody>
+{{range .Rows}}<tr class="commit{{if .Error}} failed{{end}}">
+<td>{{.Date}}</td>
+<td>{{if .Link}}<a href="{{.Link}}">{{.ShortHash}}</a>{{else}}{{.ShortHash}}{{end}}</td>
+<td>{{.Author}}</td>
+<td>{{.Subject}}{{if .Error}}<br><em>{{.Error}}</em>{{end}}</td>
+<td class="num">{{.FilesChanged}}</td>
+<td class="num"><span class="ins">+{{.Insertions}}</span> <span class="del">-{{.Deletions}}</span></td>
+</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+</body>
+</html>
+`
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlTemplateStr))
+
+// htmlRow is a single commit row in the HTML report
+type htmlRow struct {
+	Date         string
+	ShortHash    string
+	Link         string
+	Author       string
+	Subject      string
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+	Error        string
+}
+
+// htmlGroup holds the rows belonging to a single branch
+type htmlGroup struct {
+	Branch string
+	Rows   []htmlRow
+}
+
+// WriteHTML writes an index.html file to outDir summarizing the extracted commits
+func WriteHTML(outDir string, results []extractor.Result) (err error) {
+	if err := os.MkdirAll(outDir, config.OutputDirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close HTML report: %w", closeErr)
+		}
+	}()
+
+	data := struct {
+		Total  int
+		Groups []htmlGroup
+	}{
+		Total:  len(results),
+		Groups: groupByBranch(outDir, results),
+	}
+
+	if execErr := htmlTemplate.Execute(f, data); execErr != nil {
+		return fmt.Errorf("failed to execute HTML template: %w", execErr)
+	}
+	return nil
+}
+
+// groupByBranch groups results by branch, keeping branches in order of first
+// appearance and commits in extraction order
+func groupByBranch(outDir string, results []extractor.Result) []htmlGroup {
+	sorted := sortedResults(results)
+
+	var groups []htmlGroup
+	groupIndex := make(map[string]int)
+	for _, r := range sorted {
+		idx, ok := groupIndex[r.Branch]
+		if !ok {
+			idx = len(groups)
+			groupIndex[r.Branch] = idx
+			groups = append(groups, htmlGroup{Branch: r.Branch})
+		}
+		groups[idx].Rows = append(groups[idx].Rows, newHTMLRow(outDir, r))
+	}
+	return groups
+}
+
+// newHTMLRow converts a result to a report row
+func newHTMLRow(outDir string, r extractor.Result) htmlRow {
+	row := htmlRow{
+		Date:         r.Commit.AuthorDate.Format(displayDateFormat),
+		ShortHash:    r.Commit.ShortHash,
+		Author:       r.Commit.Author,
+		Subject:      r.Commit.Subject,
+		FilesChanged: r.Commit.FilesChanged,
+		Insertions:   r.Commit.Insertions,
+		Deletions:    r.Commit.Deletions,
+	}
+	if r.Error != nil {
+		row.Error = r.Error.Error()
+	} else if rel, err := filepath.Rel(outDir, r.OutputPath); err == nil {
+		row.Link = filepath.ToSlash(rel)
+		if info, err := os.Stat(r.OutputPath); err != nil || info.IsDir() {
+			row.Link += "/"
+		}
+	}
+	return row
+}
+
+// sortedResults returns a copy of results ordered by branch appearance and index
+func sortedResults(results []extractor.Result) []extractor.Result {
+	sorted := make([]extractor.Result, len(results))
+	copy(sorted, results)
+
+	branchOrder := make(map[string]int)
+	for _, r := range results {
+		if _, ok := branchOrder[r.Branch]; !ok {
+			branchOrder[r.Branch] = len(branchOrder)
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		bi, bj := branchOrder[sorted[i].Branch], branchOrder[sorted[j].Branch]
+		if bi != bj {
+			return bi < bj
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
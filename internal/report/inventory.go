@@ -0,0 +1,115 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/config"
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// InventoryFileName is the metadata inventory written to the output root
+const InventoryFileName = "inventory.csv"
+
+// inventoryHeader lists the columns written by WriteInventoryCSV
+var inventoryHeader = []string{
+	"index",
+	"branch",
+	"hash",
+	"short_hash",
+	"parents",
+	"author",
+	"author_email",
+	"author_date",
+	"committer",
+	"committer_email",
+	"commit_date",
+	"files_changed",
+	"insertions",
+	"deletions",
+	"empty",
+	"signature",
+	"key_id",
+	"signer",
+	"trust",
+	"subject",
+	"message",
+	"error",
+}
+
+// WriteInventoryCSV writes one row of metadata per commit to w, preceded by
+// a header row. Parents are space-separated, and signature is the %G? status.
+func WriteInventoryCSV(w io.Writer, results []extractor.Result) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(inventoryHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, r := range sortedResults(results) {
+		var errMsg string
+		if r.Error != nil {
+			errMsg = r.Error.Error()
+		}
+
+		c := r.Commit
+		record := []string{
+			strconv.Itoa(r.Index),
+			r.Branch,
+			c.Hash,
+			c.ShortHash,
+			strings.Join(c.ParentHashes, " "),
+			c.Author,
+			c.AuthorEmail,
+			c.AuthorDate.Format(time.RFC3339),
+			c.Committer,
+			c.CommitterEmail,
+			c.CommitDate.Format(time.RFC3339),
+			strconv.Itoa(c.FilesChanged),
+			strconv.Itoa(c.Insertions),
+			strconv.Itoa(c.Deletions),
+			strconv.FormatBool(r.Empty),
+			c.GPGSignature,
+			c.GPGKeyID,
+			c.GPGSigner,
+			c.Trust,
+			c.Subject,
+			c.FullMessage,
+			errMsg,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteInventoryFile writes the inventory to inventory.csv in outDir,
+// creating outDir, which holds no commit folders in inventory mode
+func WriteInventoryFile(outDir string, results []extractor.Result) (err error) {
+	if err := os.MkdirAll(outDir, config.OutputDirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	f, err := os.Create(filepath.Join(outDir, InventoryFileName))
+	if err != nil {
+		return fmt.Errorf("failed to create inventory file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close inventory file: %w", closeErr)
+		}
+	}()
+
+	return WriteInventoryCSV(f, results)
+}
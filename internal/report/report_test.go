@@ -0,0 +1,290 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// sampleResults returns a small set of extraction results for report tests
+func sampleResults(outDir string) []extractor.Result {
+	date := time.Date(2023, 12, 5, 14, 30, 22, 0, time.UTC)
+	return []extractor.Result{
+		{
+			Commit: git.Commit{
+				Hash:         "abc1234000000000000000000000000000000000",
+				ShortHash:    "abc1234",
+				Author:       "Alice Dev",
+				AuthorEmail:  "alice@example.com",
+				AuthorDate:   date,
+				CommitDate:   date,
+				Subject:      "Initial commit",
+				FilesChanged: 2,
+				Insertions:   10,
+			},
+			Branch:     "main",
+			Index:      0,
+			OutputPath: filepath.Join(outDir, "main", "20231205_143022_abc1234"),
+		},
+		{
+			Commit: git.Commit{
+				Hash:         "def5678000000000000000000000000000000000",
+				ShortHash:    "def5678",
+				Author:       "Mallory <evil>",
+				AuthorEmail:  "mallory@example.com",
+				AuthorDate:   date.Add(time.Hour),
+				CommitDate:   date.Add(time.Hour),
+				Subject:      "<script>alert(1)</script>, with comma\nand newline",
+				FilesChanged: 1,
+				Insertions:   3,
+				Deletions:    1,
+			},
+			Branch:     "main",
+			Index:      1,
+			OutputPath: filepath.Join(outDir, "main", "20231205_153022_def5678"),
+		},
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	outDir := t.TempDir()
+	results := sampleResults(outDir)
+
+	if err := WriteHTML(outDir, results); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatalf("failed to read index.html: %v", err)
+	}
+	html := string(data)
+
+	if rows := strings.Count(html, `<tr class="commit`); rows != len(results) {
+		t.Errorf("expected %d commit rows, got %d", len(results), rows)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("expected <script> in subject to be escaped")
+	}
+	if !strings.Contains(html, "&lt;script&gt;") {
+		t.Error("expected escaped subject in report")
+	}
+	if !strings.Contains(html, `href="main/20231205_143022_abc1234/"`) {
+		t.Error("expected link to commit folder relative to output directory")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	outDir := t.TempDir()
+	results := sampleResults(outDir)
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV back: %v", err)
+	}
+
+	if len(records) != len(results)+1 {
+		t.Fatalf("expected %d records (header + rows), got %d", len(results)+1, len(records))
+	}
+
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[name] = i
+	}
+
+	row := records[2]
+	want := map[string]string{
+		"index":         "1",
+		"branch":        "main",
+		"short_hash":    "def5678",
+		"author":        "Mallory <evil>",
+		"subject":       results[1].Commit.Subject,
+		"files_changed": "1",
+		"insertions":    "3",
+		"deletions":     "1",
+		"folder":        results[1].OutputPath,
+		"error":         "",
+	}
+	for name, value := range want {
+		if got := row[column[name]]; got != value {
+			t.Errorf("column %s: expected %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestWriteChurnCSV(t *testing.T) {
+	outDir := t.TempDir()
+	results := sampleResults(outDir)
+
+	// A second day with more deletions than insertions, plus the same
+	// commit seen on another branch
+	later := results[1]
+	later.Commit.Hash = "fff0000000000000000000000000000000000000"
+	later.Commit.AuthorDate = later.Commit.AuthorDate.AddDate(0, 0, 2)
+	later.Commit.FilesChanged, later.Commit.Insertions, later.Commit.Deletions = 4, 1, 20
+	dup := results[0]
+	dup.Branch = "feature"
+	results = append(results, later, dup)
+
+	days := Churn(results)
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d: %+v", len(days), days)
+	}
+	if days[0].Commits != 2 || days[0].Insertions != 13 {
+		t.Errorf("expected duplicate commit to be counted once, got %+v", days[0])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChurnCSV(&buf, days); err != nil {
+		t.Fatalf("WriteChurnCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV back: %v", err)
+	}
+	if len(records) != len(days)+1 {
+		t.Fatalf("expected %d records (header + rows), got %d", len(days)+1, len(records))
+	}
+
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[name] = i
+	}
+
+	// Cumulative files, insertions and deletions never decrease; net may
+	previous := make(map[string]int)
+	for _, row := range records[1:] {
+		for _, name := range []string{"cumulative_files_changed", "cumulative_insertions", "cumulative_deletions"} {
+			value, err := strconv.Atoi(row[column[name]])
+			if err != nil {
+				t.Fatalf("column %s: %v", name, err)
+			}
+			if value < previous[name] {
+				t.Errorf("column %s decreased from %d to %d", name, previous[name], value)
+			}
+			previous[name] = value
+		}
+	}
+
+	last := records[len(records)-1]
+	if last[column["net"]] != "-19" || last[column["cumulative_net"]] != "-7" {
+		t.Errorf("unexpected net values in last row: %v", last)
+	}
+
+	if busiest, ok := BusiestDay(days); !ok || busiest.Date != days[1].Date {
+		t.Errorf("expected busiest day %s, got %+v", days[1].Date, busiest)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	outDir := t.TempDir()
+	results := sampleResults(outDir)
+
+	// A later commit by the first author under a differently cased email,
+	// a failed one, and the first commit again on another branch
+	later := results[1]
+	later.Commit.Hash = "fff0000000000000000000000000000000000000"
+	later.Commit.Author, later.Commit.AuthorEmail = "Alice Dev", "Alice@Example.com"
+	later.Commit.AuthorDate = later.Commit.AuthorDate.AddDate(0, 0, 3)
+	later.Commit.Insertions, later.Commit.Deletions = 5, 7
+	failed := later
+	failed.Commit.Hash = "eee0000000000000000000000000000000000000"
+	failed.Error = os.ErrPermission
+	dup := results[0]
+	dup.Branch = "feature"
+	results = append(results, later, failed, dup)
+
+	authors := Authors(results)
+	if len(authors) != 2 {
+		t.Fatalf("expected 2 authors, got %d: %+v", len(authors), authors)
+	}
+	alice, mallory := authors[0], authors[1]
+	if alice.Name != "Alice Dev" || alice.Commits != 2 || alice.Insertions != 15 || alice.Deletions != 7 {
+		t.Errorf("unexpected totals for the first author: %+v", alice)
+	}
+	if !alice.First.Equal(results[0].Commit.AuthorDate) || !alice.Last.Equal(later.Commit.AuthorDate) {
+		t.Errorf("unexpected date range for the first author: %s to %s", alice.First, alice.Last)
+	}
+	if mallory.Commits != 1 || mallory.Insertions != 3 || mallory.Deletions != 1 {
+		t.Errorf("unexpected totals for the second author: %+v", mallory)
+	}
+
+	if err := WriteAuthorsFiles(outDir, authors); err != nil {
+		t.Fatalf("WriteAuthorsFiles failed: %v", err)
+	}
+	f, err := os.Open(filepath.Join(outDir, AuthorsCSVFileName))
+	if err != nil {
+		t.Fatalf("failed to open authors CSV: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV back: %v", err)
+	}
+	if len(records) != 3 || records[1][2] != "2" || records[2][0] != "Mallory <evil>" {
+		t.Errorf("unexpected authors CSV: %v", records)
+	}
+	text, err := os.ReadFile(filepath.Join(outDir, AuthorsTextFileName))
+	if err != nil {
+		t.Fatalf("failed to read authors table: %v", err)
+	}
+	if !strings.Contains(string(text), "Alice Dev <alice@example.com>") {
+		t.Errorf("expected the authors table to list the first author, got:\n%s", text)
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	outDir := t.TempDir()
+	date := time.Date(2023, 12, 5, 14, 30, 22, 0, time.UTC)
+	commit := func(short string, hours int, parents ...string) extractor.Result {
+		return extractor.Result{
+			Commit: git.Commit{
+				Hash:         short + "000",
+				ShortHash:    short,
+				CommitDate:   date.Add(time.Duration(hours) * time.Hour),
+				Subject:      "commit " + short,
+				ParentHashes: parents,
+			},
+			Branch:     "main",
+			Index:      hours,
+			OutputPath: filepath.Join(outDir, "main", short),
+		}
+	}
+	results := []extractor.Result{
+		commit("aaaaaaa", 0),
+		commit("bbbbbbb", 1, "aaaaaaa000"),
+		commit("ccccccc", 2, "aaaaaaa000"),
+		commit("ddddddd", 3, "bbbbbbb000", "ccccccc000"),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGraph(&buf, outDir, results); err != nil {
+		t.Fatalf("WriteGraph failed: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"*   ddddddd commit ddddddd (main/ddddddd)",
+		`|\`,
+		"| * ccccccc commit ccccccc (main/ccccccc)",
+		"* | bbbbbbb commit bbbbbbb (main/bbbbbbb)",
+		"|/",
+		"* aaaaaaa commit aaaaaaa (main/aaaaaaa)",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected graph:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// SummaryFileName is the run summary written to the output root
+const SummaryFileName = "summary.json"
+
+// Summary holds the totals of an extraction run, written as summary.json
+// so that CI jobs have a single artifact to parse
+type Summary struct {
+	Repository     string    `json:"repository"`
+	Remote         string    `json:"remote,omitempty"`
+	OutputDir      string    `json:"output_dir"`
+	Branches       []string  `json:"branches"`
+	Workers        int       `json:"workers"`
+	Commits        int       `json:"commits"`
+	Succeeded      int       `json:"succeeded"`
+	Failed         int       `json:"failed"`
+	Skipped        int       `json:"skipped"`
+	BytesWritten   int64     `json:"bytes_written"`
+	StartedAt      time.Time `json:"started_at"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+}
+
+// NewSummary counts the outcomes of results. Skipped commits (see
+// extractor.Config.SkipEmpty) are counted as succeeded too. The caller
+// fills in the run details.
+func NewSummary(results []extractor.Result) Summary {
+	s := Summary{Commits: len(results), Branches: []string{}}
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if !seen[r.Branch] && r.Branch != "" {
+			seen[r.Branch] = true
+			s.Branches = append(s.Branches, r.Branch)
+		}
+		switch {
+		case r.Error != nil:
+			s.Failed++
+		case r.Skipped:
+			s.Skipped++
+			s.Succeeded++
+		default:
+			s.Succeeded++
+		}
+	}
+	return s
+}
+
+// DirSize returns the total size of the regular files under dir
+func DirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure output size: %w", err)
+	}
+	return total, nil
+}
+
+// WriteSummary writes s as indented JSON to summary.json in outDir
+func WriteSummary(outDir string, s Summary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, SummaryFileName), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
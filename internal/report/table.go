@@ -0,0 +1,32 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/andpalmier/repopsy/internal/extractor"
+)
+
+// displayDateFormat is the date format shared by the text table and HTML report
+const displayDateFormat = "2006-01-02 15:04:05"
+
+// WriteTable writes an aligned plain-text table of the commits in results,
+// one line per commit after a header line
+func WriteTable(w io.Writer, results []extractor.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BRANCH\tCOMMIT\tDATE\tAUTHOR\tSUBJECT")
+	for _, r := range sortedResults(results) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			r.Branch,
+			r.Commit.ShortHash,
+			r.Commit.AuthorDate.Format(displayDateFormat),
+			r.Commit.Author,
+			r.Commit.Subject,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
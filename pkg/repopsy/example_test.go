@@ -0,0 +1,66 @@
+package repopsy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/andpalmier/repopsy/pkg/repopsy"
+)
+
+func ExampleExtract() {
+	dir, err := os.MkdirTemp("", "repopsy-example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A repository with two commits on main
+	repoPath := filepath.Join(dir, "repo")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main", repoPath},
+		{"-C", repoPath, "commit", "-q", "--allow-empty", "-m", "First commit"},
+		{"-C", repoPath, "commit", "-q", "--allow-empty", "-m", "Second commit"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Example", "-c", "user.email=example@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	report, err := repopsy.Extract(context.Background(), repopsy.Config{
+		RepoPath:  repoPath,
+		OutputDir: filepath.Join(dir, "out"),
+		Branch:    "main",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, result := range report.Results {
+		fmt.Println(result.Commit.Subject)
+	}
+	fmt.Printf("%d extracted, %d failed\n", report.Succeeded, report.Failed)
+	// Output:
+	// First commit
+	// Second commit
+	// 2 extracted, 0 failed
+}
+
+func ExampleExtract_notARepository() {
+	dir, err := os.MkdirTemp("", "repopsy-example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = repopsy.Extract(context.Background(), repopsy.Config{
+		RepoPath:  dir,
+		OutputDir: filepath.Join(dir, "out"),
+	})
+	fmt.Println(errors.Is(err, repopsy.ErrNotARepository))
+	// Output: true
+}
@@ -0,0 +1,46 @@
+// Package repopsy extracts the commits of a git repository into separate
+// folders from Go programs, as the repopsy command does
+package repopsy
+
+import (
+	"context"
+
+	"github.com/andpalmier/repopsy/internal/app"
+	"github.com/andpalmier/repopsy/internal/extractor"
+	"github.com/andpalmier/repopsy/internal/git"
+)
+
+// Config describes an extraction; its fields mirror the command line flags
+type Config = app.Config
+
+// Report is the structured outcome of Extract
+type Report = app.Report
+
+// BranchSummary breaks the counts of a Report down for one branch
+type BranchSummary = app.BranchSummary
+
+// Result is the outcome of extracting one commit
+type Result = extractor.Result
+
+// ExtractionError reports a commit whose tree could not be extracted, with
+// the standard error of the failed command
+type ExtractionError = git.ExtractionError
+
+var (
+	// ErrNoCommits is returned for a repository without any commit
+	ErrNoCommits = app.ErrNoCommits
+
+	// ErrNotARepository is returned when the path is not inside a git repository
+	ErrNotARepository = git.ErrNotARepository
+
+	// ErrGitNotFound is returned when the git executable is not in PATH
+	ErrGitNotFound = git.ErrGitNotFound
+)
+
+// Extract runs the extraction described by cfg without any terminal output
+// and returns a structured report. When some commits fail, both the report
+// and an error describing the failures are returned; the failures of single
+// commits can be inspected with errors.As and *ExtractionError.
+func Extract(ctx context.Context, cfg Config) (*Report, error) {
+	return app.Extract(ctx, cfg)
+}